@@ -20,26 +20,164 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+const (
+	// ManagedLabelsAnnotation is set on every Namespace the operator writes labels
+	// to. Its value is a JSON object mapping each managed label key to the name of
+	// the NamespaceLabel that owns it, to InheritedOwner, or to the
+	// RequirementOwnerPrefix and the name of a NamespaceLabelRequirement.
+	ManagedLabelsAnnotation = "danateam.namespacelabel.io/managed-labels"
+
+	// DesiredStateAnnotation is set next to ManagedLabelsAnnotation. Its value
+	// hashes the managed labels, their owners and the policy revision that were
+	// last written, so that a reconcile with the same desired state can skip
+	// the write.
+	DesiredStateAnnotation = "danateam.namespacelabel.io/desired-state"
+
+	// ImmutableLabelsAnnotation is set on a Namespace once a NamespaceLabel
+	// applies an immutable label to it. Its value is a JSON object mapping
+	// each locked label key to its value. Keys stay locked after the
+	// NamespaceLabel is deleted, until they are listed in the
+	// UnlockImmutableLabelsAnnotation.
+	ImmutableLabelsAnnotation = "danateam.namespacelabel.io/immutable-labels"
+
+	// UnlockImmutableLabelsAnnotation is set on a Namespace by an administrator
+	// to allow changing immutable labels. Its value is a comma-separated list of
+	// the label keys that may change again.
+	UnlockImmutableLabelsAnnotation = "danateam.namespacelabel.io/unlock-immutable-labels"
+
+	// InheritedOwner is the owner recorded in the ManagedLabelsAnnotation for
+	// the labels a Namespace inherits from its parent namespaces. It is not a
+	// valid NamespaceLabel name, so it never collides with one.
+	InheritedOwner = "(inherited)"
+
+	// RequirementOwnerPrefix, followed by the name of a
+	// NamespaceLabelRequirement, is the owner recorded in the
+	// ManagedLabelsAnnotation for the labels the requirement filled in. It
+	// contains a slash, so it never collides with a NamespaceLabel name.
+	RequirementOwnerPrefix = "requirement/"
+
+	// Finalizer is added to every NamespaceLabel so the labels it owns can be
+	// removed from the Namespace before the object goes away.
+	Finalizer = "danateam.namespacelabel.io/finalizer"
+)
+
+// Condition types and reasons reported in NamespaceLabelStatus.Conditions.
+const (
+	// ConditionReady is true when every label in the spec is applied to the Namespace.
+	ConditionReady = "Ready"
+
+	ReasonApplied      = "Applied"
+	ReasonConflict     = "Conflict"
+	ReasonPolicyDenied = "PolicyDenied"
+	ReasonImmutable    = "Immutable"
+	ReasonInvalidLabel = "InvalidLabel"
+	ReasonError        = "Error"
+)
+
+// Condition type and reasons describing how the name rules of a
+// NamespaceLabel or NamespaceLabelRequirement matched namespace names. The
+// condition is only set when there are name rules.
+const (
+	// ConditionNameMatched is true when the names of the Namespaces match
+	// one of the name rules.
+	ConditionNameMatched = "NameMatched"
+
+	ReasonNameMatched  = "Matched"
+	ReasonNoMatch      = "NoMatch"
+	ReasonInvalidValue = "InvalidValue"
+)
 
 // NamespaceLabelSpec defines the desired state of NamespaceLabel
 type NamespaceLabelSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Labels are applied to the Namespace this NamespaceLabel is created in.
+	// A key already owned by another NamespaceLabel in the same Namespace is
+	// reported as a conflict and left untouched.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Immutable lists keys of Labels whose value cannot change once applied.
+	// Changing or removing such a label, even after removing it from this
+	// list, needs the UnlockImmutableLabelsAnnotation on the Namespace.
+	// +optional
+	// +listType=set
+	Immutable []string `json:"immutable,omitempty"`
 
-	// Foo is an example field of NamespaceLabel. Edit namespacelabel_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+	// NameRules derive labels from the name of the Namespace. The first rule
+	// whose pattern matches the name applies. Labels take precedence over
+	// derived labels with the same key.
+	// +optional
+	NameRules []NameRule `json:"nameRules,omitempty"`
+}
+
+// NameRule derives labels from the name of a Namespace.
+type NameRule struct {
+	// Pattern is a regular expression with named capture groups, such as
+	// (?P<team>[a-z0-9]+)-(?P<app>[a-z0-9]+)-(?P<env>[a-z]+), that must
+	// match the whole namespace name.
+	// +kubebuilder:validation:MinLength=1
+	Pattern string `json:"pattern"`
+
+	// Labels are the labels derived from the capture groups of Pattern.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=key
+	Labels []DerivedLabel `json:"labels"`
+}
+
+// DerivedLabel is a label whose value is captured from the namespace name.
+type DerivedLabel struct {
+	// Key is the label key.
+	Key string `json:"key"`
+
+	// Group is the name of the capture group holding the value.
+	Group string `json:"group"`
+
+	// Values translates captured values into label values, such as prd into
+	// production. Captured values it does not list are used as they are.
+	// +optional
+	Values map[string]string `json:"values,omitempty"`
 }
 
 // NamespaceLabelStatus defines the observed state of NamespaceLabel
 type NamespaceLabelStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// AppliedLabels are the labels this NamespaceLabel currently owns on the Namespace.
+	// +optional
+	AppliedLabels map[string]string `json:"appliedLabels,omitempty"`
+
+	// ImmutableLabels are the keys of AppliedLabels that were applied as
+	// immutable. They stay immutable until unlocked.
+	// +optional
+	// +listType=set
+	ImmutableLabels []string `json:"immutableLabels,omitempty"`
+
+	// DerivedLabels are the labels the name rules derive from the name of
+	// the Namespace.
+	// +optional
+	DerivedLabels map[string]string `json:"derivedLabels,omitempty"`
+
+	// ObservedGeneration is the generation of the spec that was last reconciled.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// PolicyRevision is the resourceVersion of the policy ConfigMap in effect
+	// during the last reconcile. It is empty when the policy of the manager's
+	// configuration file was used.
+	// +optional
+	PolicyRevision string `json:"policyRevision,omitempty"`
+
+	// Conditions describe the current state of the NamespaceLabel.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// +genclient
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // NamespaceLabel is the Schema for the namespacelabels API
 type NamespaceLabel struct {
@@ -58,7 +196,3 @@ type NamespaceLabelList struct {
 	metav1.ListMeta `json:"metadata,omitempty"`
 	Items           []NamespaceLabel `json:"items"`
 }
-
-func init() {
-	SchemeBuilder.Register(&NamespaceLabel{}, &NamespaceLabelList{})
-}
@@ -0,0 +1,162 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Condition types and reasons reported in NamespaceLabelRequirementStatus.Conditions.
+const (
+	// ConditionCompliant is true when every Namespace selected by a
+	// NamespaceLabelRequirement carries its required labels.
+	ConditionCompliant = "Compliant"
+
+	ReasonCompliant    = "Compliant"
+	ReasonNonCompliant = "NonCompliant"
+	ReasonInvalidSpec  = "InvalidSpec"
+)
+
+// MaxNonCompliantListed is the number of non-compliant Namespaces listed in
+// NamespaceLabelRequirementStatus.NonCompliant, which keeps the object small
+// on large clusters.
+const MaxNonCompliantListed = 100
+
+// NamespaceLabelRequirementSpec defines the desired state of NamespaceLabelRequirement
+// +kubebuilder:validation:XValidation:rule="has(self.labels) || has(self.nameRules)",message="labels or nameRules must be set"
+type NamespaceLabelRequirementSpec struct {
+	// NamespaceSelector selects the Namespaces that must carry the labels.
+	// Every Namespace is selected when it is empty.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Labels are the labels every selected Namespace must carry.
+	// +optional
+	// +listType=map
+	// +listMapKey=key
+	Labels []RequiredLabel `json:"labels,omitempty"`
+
+	// NameRules derive labels from the names of the selected Namespaces.
+	// The first rule whose pattern matches a name applies, and a derived
+	// value takes precedence over the default of a required label.
+	// +optional
+	NameRules []NameRule `json:"nameRules,omitempty"`
+
+	// AutoFill writes the default value of a required label, and the
+	// derived labels, to the selected Namespaces that do not carry them.
+	// Labels denied by the policy, and Namespaces excluded by it, are left
+	// alone.
+	// +optional
+	AutoFill bool `json:"autoFill,omitempty"`
+}
+
+// RequiredLabel is a label key a Namespace must carry.
+type RequiredLabel struct {
+	// Key is the label key.
+	Key string `json:"key"`
+
+	// Pattern is a regular expression the whole value must match. Any value
+	// is accepted when it is empty.
+	// +optional
+	Pattern string `json:"pattern,omitempty"`
+
+	// Default is written when the label is missing and AutoFill is set. It
+	// must match Pattern.
+	// +optional
+	Default string `json:"default,omitempty"`
+}
+
+// NamespaceLabelRequirementStatus defines the observed state of NamespaceLabelRequirement
+type NamespaceLabelRequirementStatus struct {
+	// ObservedGeneration is the generation of the spec that was last evaluated.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// MatchedNamespaces is the number of Namespaces selected.
+	// +optional
+	MatchedNamespaces int32 `json:"matchedNamespaces"`
+
+	// NonCompliantNamespaces is the number of selected Namespaces that miss a
+	// required label or carry a value not matching its pattern.
+	// +optional
+	NonCompliantNamespaces int32 `json:"nonCompliantNamespaces"`
+
+	// NonCompliant lists the first MaxNonCompliantListed non-compliant
+	// Namespaces by name.
+	// +optional
+	// +listType=map
+	// +listMapKey=namespace
+	NonCompliant []NonCompliantNamespace `json:"nonCompliant,omitempty"`
+
+	// UnmatchedNamespaces is the number of selected Namespaces whose name
+	// matches none of the name rules, or yields an invalid label value.
+	// +optional
+	UnmatchedNamespaces int32 `json:"unmatchedNamespaces,omitempty"`
+
+	// Unmatched lists the first MaxNonCompliantListed unmatched Namespaces.
+	// +optional
+	// +listType=set
+	Unmatched []string `json:"unmatched,omitempty"`
+
+	// Conditions describe the current state of the NamespaceLabelRequirement.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// NonCompliantNamespace is a Namespace that does not carry the required labels.
+type NonCompliantNamespace struct {
+	Namespace string `json:"namespace"`
+
+	// Missing are the required keys the Namespace does not carry.
+	// +optional
+	Missing []string `json:"missing,omitempty"`
+
+	// Invalid are the required keys whose value does not match the pattern.
+	// +optional
+	Invalid []string `json:"invalid,omitempty"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Matched",type=integer,JSONPath=`.status.matchedNamespaces`
+// +kubebuilder:printcolumn:name="Non-compliant",type=integer,JSONPath=`.status.nonCompliantNamespaces`
+// +kubebuilder:printcolumn:name="Compliant",type=string,JSONPath=`.status.conditions[?(@.type=="Compliant")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NamespaceLabelRequirement declares labels that the Namespaces matched by a
+// selector must carry, and reports the Namespaces that do not.
+type NamespaceLabelRequirement struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NamespaceLabelRequirementSpec   `json:"spec,omitempty"`
+	Status NamespaceLabelRequirementStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespaceLabelRequirementList contains a list of NamespaceLabelRequirement
+type NamespaceLabelRequirementList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceLabelRequirement `json:"items"`
+}
@@ -21,16 +21,61 @@ limitations under the License.
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DerivedLabel) DeepCopyInto(out *DerivedLabel) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DerivedLabel.
+func (in *DerivedLabel) DeepCopy() *DerivedLabel {
+	if in == nil {
+		return nil
+	}
+	out := new(DerivedLabel)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NameRule) DeepCopyInto(out *NameRule) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make([]DerivedLabel, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NameRule.
+func (in *NameRule) DeepCopy() *NameRule {
+	if in == nil {
+		return nil
+	}
+	out := new(NameRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NamespaceLabel) DeepCopyInto(out *NamespaceLabel) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceLabel.
@@ -83,9 +128,153 @@ func (in *NamespaceLabelList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceLabelRequirement) DeepCopyInto(out *NamespaceLabelRequirement) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceLabelRequirement.
+func (in *NamespaceLabelRequirement) DeepCopy() *NamespaceLabelRequirement {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceLabelRequirement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespaceLabelRequirement) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceLabelRequirementList) DeepCopyInto(out *NamespaceLabelRequirementList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NamespaceLabelRequirement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceLabelRequirementList.
+func (in *NamespaceLabelRequirementList) DeepCopy() *NamespaceLabelRequirementList {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceLabelRequirementList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NamespaceLabelRequirementList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceLabelRequirementSpec) DeepCopyInto(out *NamespaceLabelRequirementSpec) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make([]RequiredLabel, len(*in))
+		copy(*out, *in)
+	}
+	if in.NameRules != nil {
+		in, out := &in.NameRules, &out.NameRules
+		*out = make([]NameRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceLabelRequirementSpec.
+func (in *NamespaceLabelRequirementSpec) DeepCopy() *NamespaceLabelRequirementSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceLabelRequirementSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceLabelRequirementStatus) DeepCopyInto(out *NamespaceLabelRequirementStatus) {
+	*out = *in
+	if in.NonCompliant != nil {
+		in, out := &in.NonCompliant, &out.NonCompliant
+		*out = make([]NonCompliantNamespace, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Unmatched != nil {
+		in, out := &in.Unmatched, &out.Unmatched
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceLabelRequirementStatus.
+func (in *NamespaceLabelRequirementStatus) DeepCopy() *NamespaceLabelRequirementStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceLabelRequirementStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NamespaceLabelSpec) DeepCopyInto(out *NamespaceLabelSpec) {
 	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Immutable != nil {
+		in, out := &in.Immutable, &out.Immutable
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NameRules != nil {
+		in, out := &in.NameRules, &out.NameRules
+		*out = make([]NameRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceLabelSpec.
@@ -101,6 +290,32 @@ func (in *NamespaceLabelSpec) DeepCopy() *NamespaceLabelSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NamespaceLabelStatus) DeepCopyInto(out *NamespaceLabelStatus) {
 	*out = *in
+	if in.AppliedLabels != nil {
+		in, out := &in.AppliedLabels, &out.AppliedLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ImmutableLabels != nil {
+		in, out := &in.ImmutableLabels, &out.ImmutableLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DerivedLabels != nil {
+		in, out := &in.DerivedLabels, &out.DerivedLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceLabelStatus.
@@ -112,3 +327,43 @@ func (in *NamespaceLabelStatus) DeepCopy() *NamespaceLabelStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NonCompliantNamespace) DeepCopyInto(out *NonCompliantNamespace) {
+	*out = *in
+	if in.Missing != nil {
+		in, out := &in.Missing, &out.Missing
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Invalid != nil {
+		in, out := &in.Invalid, &out.Invalid
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NonCompliantNamespace.
+func (in *NonCompliantNamespace) DeepCopy() *NonCompliantNamespace {
+	if in == nil {
+		return nil
+	}
+	out := new(NonCompliantNamespace)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequiredLabel) DeepCopyInto(out *RequiredLabel) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequiredLabel.
+func (in *RequiredLabel) DeepCopy() *RequiredLabel {
+	if in == nil {
+		return nil
+	}
+	out := new(RequiredLabel)
+	in.DeepCopyInto(out)
+	return out
+}
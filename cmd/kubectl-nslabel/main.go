@@ -0,0 +1,541 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command kubectl-nslabel is a kubectl plugin for the labels managed by
+// NamespaceLabels. Install it on the PATH and run it as kubectl nslabel.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlconfig "sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/internal/config"
+	"github.com/matanamar10/namesapcelabel/internal/lint"
+	"github.com/matanamar10/namesapcelabel/internal/nslabel"
+	"github.com/matanamar10/namesapcelabel/internal/snapshot"
+)
+
+const usage = `Inspect and manage namespace labels through NamespaceLabels.
+
+Usage:
+  kubectl nslabel get <namespace>                       List labels with the NamespaceLabel owning each key
+  kubectl nslabel why <namespace> <key>                 Explain who set a label
+  kubectl nslabel set [--name NAME] <namespace> <key>=<value>...
+                                                        Request labels through a NamespaceLabel
+  kubectl nslabel unset [--name NAME] <namespace> <key>...
+                                                        Stop requesting labels
+  kubectl nslabel diff <namespace>                      Compare requested and live labels
+  kubectl nslabel export [--name NAME] [--prefix P,...] [--output-dir DIR [--kustomize]] [<namespace>...]
+                                                        Generate NamespaceLabels from live labels
+  kubectl nslabel lint [--output text|json|sarif] [--protected-prefixes P,...] [<file>|<dir>|-]...
+                                                        Check NamespaceLabel manifests without a cluster
+  kubectl nslabel snapshot [-l SELECTOR] [--file FILE|--configmap NS/NAME] [<namespace>...]
+                                                        Save the labels and annotations of namespaces
+  kubectl nslabel restore (--file FILE|--configmap NS/NAME) [--scope managed|all] [--dry-run] [--output text|json]
+                                                        Restore labels and annotations from a snapshot
+
+set adds each key to the NamespaceLabel already requesting it, and others to
+--name (default "` + nslabel.DefaultName + `"). unset removes keys from every
+NamespaceLabel requesting them, or only from --name. diff exits with 1 when
+there are differences. why, set and diff resolve the NamespaceLabels with the
+policy of the manager: that of its configuration file, given with --config or
+read from the ` + nslabel.ManagerConfigKey + ` key of
+--manager-configmap, or the default policy, overridden by the runtime policy
+read from --policy-configmap (default "` + defaultPolicyConfigMap + `").
+NamespaceLabels in namespaces the policy excludes are reported as not applied.
+
+export writes a NamespaceLabel named --name for every namespace with labels
+not managed yet, skipping labels under kubernetes.io/ and k8s.io/ and, with
+--prefix, keys without one of the prefixes. It prints the manifests, or writes
+a file per namespace to --output-dir, or a kustomize tree with --kustomize.
+Enable policy.adoptExisting before applying them so that the operator takes
+the labels over without rewriting them.
+
+lint reads the given files, the YAML files under the given directories, or
+stdin, and checks NamespaceLabels against their schema, the label syntax of
+the API server and the policy. A ManagerConfig or policy ConfigMap among the
+inputs sets the policy; --protected-prefixes overrides its prefixes. Keys
+requested by several NamespaceLabels of a namespace are reported as
+conflicts. lint exits with 1 when there are errors.
+
+snapshot saves the labels and annotations of the given namespaces, or of those
+matching -l, to --file, to the snapshot.yaml key of --configmap, or to stdout.
+restore sets the values of the snapshot again: with --scope managed (the
+default) only the labels the operator managed and its annotations, with
+--scope all every label and annotation. Keys added since the snapshot are left
+alone. --dry-run prints the changes without making them.
+
+Every command but lint accepts --kubeconfig.
+`
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "lint":
+		os.Exit(runLint(os.Args[2:]))
+	case "snapshot":
+		os.Exit(runSnapshot(os.Args[2:]))
+	case "restore":
+		os.Exit(runRestore(os.Args[2:]))
+	}
+	os.Exit(run(os.Args[1], os.Args[2:]))
+}
+
+// run runs command with args and returns the exit code.
+func run(command string, args []string) int {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	name := fs.String("name", "", "The NamespaceLabel to change or generate (set, unset and export only).")
+	prefixes := fs.String("prefix", "", "Comma-separated key prefixes to export (export only).")
+	outputDir := fs.String("output-dir", "", "The directory to write the manifests to (export only).")
+	kustomize := fs.Bool("kustomize", false, "Write a kustomize tree to --output-dir (export only).")
+	configFile := fs.String("config", "",
+		"The configuration file of the manager, whose policy the policy ConfigMap overrides (why, set and diff only).")
+	managerConfigMap := fs.String("manager-configmap", "",
+		"The ConfigMap holding the configuration file of the manager, as namespace/name (why, set and diff only).")
+	policyConfigMap := fs.String("policy-configmap", defaultPolicyConfigMap,
+		"The policy ConfigMap of the manager, as namespace/name (why, set and diff only).")
+	ctrlconfig.RegisterFlags(fs)
+	_ = fs.Parse(args)
+	args = fs.Args()
+	ctrl.SetLogger(zap.New())
+
+	want := map[string]func(int) bool{
+		"get":    func(n int) bool { return n == 1 },
+		"why":    func(n int) bool { return n == 2 },
+		"set":    func(n int) bool { return n >= 2 },
+		"unset":  func(n int) bool { return n >= 2 },
+		"diff":   func(n int) bool { return n == 1 },
+		"export": func(n int) bool { return true },
+	}
+	if valid, ok := want[command]; !ok || !valid(len(args)) {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	if *name != "" && command != "set" && command != "unset" && command != "export" {
+		fmt.Fprintf(os.Stderr, "--name is only supported by set, unset and export\n")
+		return 2
+	}
+	if (*prefixes != "" || *outputDir != "" || *kustomize) && command != "export" {
+		fmt.Fprintf(os.Stderr, "--prefix, --output-dir and --kustomize are only supported by export\n")
+		return 2
+	}
+	if (*configFile != "" || *managerConfigMap != "") && command != "why" && command != "set" && command != "diff" {
+		fmt.Fprintf(os.Stderr, "--config and --manager-configmap are only supported by why, set and diff\n")
+		return 2
+	}
+	if *configFile != "" && *managerConfigMap != "" {
+		fmt.Fprintf(os.Stderr, "--config and --manager-configmap are mutually exclusive\n")
+		return 2
+	}
+	if *kustomize && *outputDir == "" {
+		fmt.Fprintf(os.Stderr, "--kustomize requires --output-dir\n")
+		return 2
+	}
+
+	c, err := newClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	p := &nslabel.Plugin{Client: c, Out: os.Stdout, Policy: config.Default().Policy}
+	if command == "why" || command == "set" || command == "diff" {
+		cfg := config.Default()
+		switch {
+		case *configFile != "":
+			if cfg, err = config.LoadFile(*configFile); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				return 1
+			}
+		case *managerConfigMap != "":
+			namespace, name, ok := strings.Cut(*managerConfigMap, "/")
+			if !ok || namespace == "" || name == "" {
+				fmt.Fprintf(os.Stderr, "invalid --manager-configmap %q, expected namespace/name\n", *managerConfigMap)
+				return 2
+			}
+			key := client.ObjectKey{Namespace: namespace, Name: name}
+			if cfg, err = nslabel.LoadManagerConfig(ctx, c, key); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				return 1
+			}
+		}
+		p.Policy = cfg.Policy
+		if *policyConfigMap != "" {
+			namespace, name, ok := strings.Cut(*policyConfigMap, "/")
+			if !ok || namespace == "" || name == "" {
+				fmt.Fprintf(os.Stderr, "invalid --policy-configmap %q, expected namespace/name\n", *policyConfigMap)
+				return 2
+			}
+			key := client.ObjectKey{Namespace: namespace, Name: name}
+			if p.Policy, err = nslabel.LoadPolicy(ctx, c, key, cfg.Policy); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %v; using the policy of the manager configuration\n", err)
+			}
+		}
+	}
+	if command == "export" {
+		err = export(ctx, p, *name, *prefixes, *outputDir, *kustomize, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	namespace := args[0]
+	switch command {
+	case "get":
+		err = p.Get(ctx, namespace)
+	case "why":
+		err = p.Why(ctx, namespace, args[1])
+	case "set":
+		labels := map[string]string{}
+		for _, arg := range args[1:] {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok || key == "" {
+				fmt.Fprintf(os.Stderr, "invalid label %q, expected <key>=<value>\n", arg)
+				return 2
+			}
+			labels[key] = value
+		}
+		target := *name
+		if target == "" {
+			target = nslabel.DefaultName
+		}
+		err = p.Set(ctx, namespace, target, labels)
+	case "unset":
+		err = p.Unset(ctx, namespace, *name, args[1:])
+	case "diff":
+		var differs bool
+		differs, err = p.Diff(ctx, namespace)
+		if err == nil && differs {
+			return 1
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// defaultPolicyConfigMap is the policy ConfigMap of a manager deployed with
+// config/default.
+const defaultPolicyConfigMap = "namespacelabel-system/namespacelabel-policy"
+
+// newClient returns a client for the cluster of the kubeconfig.
+func newClient() (client.Client, error) {
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load kubeconfig: %w", err)
+	}
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(danateamv1.AddToScheme(scheme))
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create client: %w", err)
+	}
+	return c, nil
+}
+
+// export writes NamespaceLabels generated from the live labels of namespaces,
+// or of every namespace when empty.
+func export(ctx context.Context, p *nslabel.Plugin, name, prefixes, outputDir string, kustomize bool, namespaces []string) error {
+	opts := nslabel.ExportOptions{Name: name, Namespaces: namespaces}
+	if opts.Name == "" {
+		opts.Name = nslabel.DefaultName
+	}
+	for _, prefix := range strings.Split(prefixes, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			opts.Prefixes = append(opts.Prefixes, prefix)
+		}
+	}
+	manifests, err := p.Export(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if outputDir == "" {
+		return nslabel.WriteManifests(p.Out, manifests)
+	}
+	if err := nslabel.WriteFiles(outputDir, manifests, kustomize); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.Out, "wrote %d NamespaceLabels to %s\n", len(manifests), outputDir)
+	return nil
+}
+
+// runLint runs the lint command, which needs no cluster, with args and
+// returns the exit code.
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	output := fs.String("output", "text", "The output format, text, json or sarif.")
+	prefixes := fs.String("protected-prefixes", "", "Comma-separated label key prefixes that are never written.")
+	_ = fs.Parse(args)
+
+	if *output != "text" && *output != "json" && *output != "sarif" {
+		fmt.Fprintf(os.Stderr, "unsupported output format %q, use text, json or sarif\n", *output)
+		return 2
+	}
+	l := &lint.Linter{Policy: config.Default().Policy}
+	if *prefixes != "" {
+		l.ProtectedPrefixes = []string{}
+		for _, prefix := range strings.Split(*prefixes, ",") {
+			if prefix = strings.TrimSpace(prefix); prefix != "" {
+				l.ProtectedPrefixes = append(l.ProtectedPrefixes, prefix)
+			}
+		}
+	}
+	inputs, err := lintInputs(fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	report := l.Run(inputs)
+	switch *output {
+	case "json":
+		err = report.WriteJSON(os.Stdout)
+	case "sarif":
+		err = report.WriteSARIF(os.Stdout, "kubectl-nslabel")
+	default:
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write report: %v\n", err)
+		return 1
+	}
+	if report.Errors() > 0 {
+		return 1
+	}
+	return 0
+}
+
+// lintInputs reads paths, walking directories for YAML files. Stdin is read
+// for "-" or when paths is empty.
+func lintInputs(paths []string) ([]lint.Input, error) {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	var inputs []lint.Input
+	for _, path := range paths {
+		if path == "-" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, err
+			}
+			inputs = append(inputs, lint.Input{Name: "stdin", Data: data})
+			continue
+		}
+		err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := filepath.Ext(file)
+			if d.IsDir() || (file != path && ext != ".yaml" && ext != ".yml") {
+				return nil
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			inputs = append(inputs, lint.Input{Name: file, Data: data})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return inputs, nil
+}
+
+// runSnapshot runs the snapshot command with args and returns the exit code.
+func runSnapshot(args []string) int {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	selector := fs.String("selector", "", "Snapshot the namespaces matching this label selector.")
+	fs.StringVar(selector, "l", "", "Shorthand for --selector.")
+	file := fs.String("file", "", "The file to write the snapshot to.")
+	configMap := fs.String("configmap", "", "The ConfigMap, as namespace/name, to store the snapshot in.")
+	ctrlconfig.RegisterFlags(fs)
+	_ = fs.Parse(args)
+	ctrl.SetLogger(zap.New())
+
+	sel, err := labels.Parse(*selector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid selector: %v\n", err)
+		return 2
+	}
+	if *selector != "" && fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "namespaces and --selector are mutually exclusive\n")
+		return 2
+	}
+	key, err := configMapKey(*configMap, *file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	c, err := newClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	snap, err := snapshot.Take(ctx, c, sel, fs.Args())
+	if err == nil {
+		switch {
+		case key != nil:
+			err = snapshot.Save(ctx, c, *key, snap)
+		case *file != "":
+			var data []byte
+			if data, err = snap.Encode(); err == nil {
+				err = os.WriteFile(*file, data, 0o600)
+			}
+		default:
+			var data []byte
+			if data, err = snap.Encode(); err == nil {
+				_, err = os.Stdout.Write(data)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if key != nil || *file != "" {
+		fmt.Fprintf(os.Stderr, "saved the labels of %d namespaces\n", len(snap.Namespaces))
+	}
+	return 0
+}
+
+// runRestore runs the restore command with args and returns the exit code.
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	file := fs.String("file", "", "The file to read the snapshot from, or - for stdin.")
+	configMap := fs.String("configmap", "", "The ConfigMap, as namespace/name, to read the snapshot from.")
+	scope := fs.String("scope", string(snapshot.ScopeManaged), "What to restore, managed or all.")
+	dryRun := fs.Bool("dry-run", false, "Print the changes without making them.")
+	output := fs.String("output", "text", "The output format, text or json.")
+	ctrlconfig.RegisterFlags(fs)
+	_ = fs.Parse(args)
+	ctrl.SetLogger(zap.New())
+
+	if fs.NArg() > 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	if *scope != string(snapshot.ScopeManaged) && *scope != string(snapshot.ScopeAll) {
+		fmt.Fprintf(os.Stderr, "unsupported scope %q, use managed or all\n", *scope)
+		return 2
+	}
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "unsupported output format %q, use text or json\n", *output)
+		return 2
+	}
+	key, err := configMapKey(*configMap, *file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+	if key == nil && *file == "" {
+		fmt.Fprintf(os.Stderr, "one of --file and --configmap is required\n")
+		return 2
+	}
+	c, err := newClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	var snap *snapshot.Snapshot
+	if key != nil {
+		snap, err = snapshot.Load(ctx, c, *key)
+	} else {
+		var data []byte
+		if *file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(*file)
+		}
+		if err == nil {
+			snap, err = snapshot.Decode(data)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to read snapshot: %v\n", err)
+		return 1
+	}
+
+	report, restoreErr := snapshot.Restore(ctx, c, snap, snapshot.Scope(*scope), *dryRun)
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = report.WriteDiff(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write report: %v\n", err)
+		return 1
+	}
+	if restoreErr != nil {
+		fmt.Fprintf(os.Stderr, "restore failed: %v\n", restoreErr)
+		return 1
+	}
+	return 0
+}
+
+// configMapKey parses the --configmap flag, which excludes --file.
+func configMapKey(configMap, file string) (*client.ObjectKey, error) {
+	if configMap == "" {
+		return nil, nil
+	}
+	if file != "" {
+		return nil, fmt.Errorf("--file and --configmap are mutually exclusive")
+	}
+	namespace, name, ok := strings.Cut(configMap, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, fmt.Errorf("invalid --configmap %q, expected namespace/name", configMap)
+	}
+	return &client.ObjectKey{Namespace: namespace, Name: name}, nil
+}
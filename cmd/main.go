@@ -17,29 +17,61 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlconfig "sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/internal/audit"
+	"github.com/matanamar10/namesapcelabel/internal/cachetrim"
+	"github.com/matanamar10/namesapcelabel/internal/cleanup"
+	"github.com/matanamar10/namesapcelabel/internal/config"
 	"github.com/matanamar10/namesapcelabel/internal/controller"
+	"github.com/matanamar10/namesapcelabel/internal/health"
+	"github.com/matanamar10/namesapcelabel/internal/metrics"
+	"github.com/matanamar10/namesapcelabel/internal/ratelimit"
+	"github.com/matanamar10/namesapcelabel/internal/sharding"
+	"github.com/matanamar10/namesapcelabel/internal/tracing"
+	webhookdanateamv1 "github.com/matanamar10/namesapcelabel/internal/webhook/v1"
 	// +kubebuilder:scaffold:imports
 )
 
+// requirementLeaderElectionPrefix prefixes the leader election ID of the replica that evaluates
+// NamespaceLabelRequirements when sharding replaces leader election.
+const requirementLeaderElectionPrefix = "requirements."
+
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
@@ -53,22 +85,12 @@ func init() {
 }
 
 func main() {
-	var metricsAddr string
-	var enableLeaderElection bool
-	var probeAddr string
-	var secureMetrics bool
-	var enableHTTP2 bool
+	if len(os.Args) > 1 && os.Args[1] == "cleanup" {
+		os.Exit(runCleanup(os.Args[2:]))
+	}
+
 	var tlsOpts []func(*tls.Config)
-	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
-		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
-	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
-	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
-		"Enable leader election for controller manager. "+
-			"Enabling this will ensure there is only one active controller manager.")
-	flag.BoolVar(&secureMetrics, "metrics-secure", true,
-		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
-	flag.BoolVar(&enableHTTP2, "enable-http2", false,
-		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	loader := config.NewLoader(flag.CommandLine)
 	opts := zap.Options{
 		Development: true,
 	}
@@ -77,6 +99,17 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	cfg, err := loader.Load()
+	if err != nil {
+		setupLog.Error(err, "unable to load configuration")
+		os.Exit(1)
+	}
+	if err := cfg.Validate(); err != nil {
+		setupLog.Error(err, "invalid configuration")
+		os.Exit(1)
+	}
+	setupLog.Info("effective configuration", "config", cfg)
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -88,31 +121,67 @@ func main() {
 		c.NextProtos = []string{"http/1.1"}
 	}
 
-	if !enableHTTP2 {
+	if !cfg.EnableHTTP2 {
 		tlsOpts = append(tlsOpts, disableHTTP2)
 	}
 
+	// Create watchers for metrics and webhooks certificates. The watchers reload the key pair whenever the
+	// files change, so certificates rotated in a mounted Secret are served without restarting the manager.
+	var metricsCertWatcher, webhookCertWatcher *certwatcher.CertWatcher
+
+	// Initial webhook TLS options
+	webhookTLSOpts := tlsOpts
+
+	webhookCertFile := filepath.Join(cfg.Webhook.CertPath, cfg.Webhook.CertName)
+	webhookKeyFile := filepath.Join(cfg.Webhook.CertPath, cfg.Webhook.CertKey)
+	// The webhook certificate is only watched, and checked for readiness, when the webhooks are served.
+	if cfg.Webhook.Enabled && len(cfg.Webhook.CertPath) > 0 {
+		setupLog.Info("Initializing webhook certificate watcher using provided certificates",
+			"webhook-cert-path", cfg.Webhook.CertPath, "webhook-cert-name", cfg.Webhook.CertName,
+			"webhook-cert-key", cfg.Webhook.CertKey)
+
+		webhookCertWatcher, err = certwatcher.New(webhookCertFile, webhookKeyFile)
+		if err != nil {
+			setupLog.Error(err, "Failed to initialize webhook certificate watcher")
+			os.Exit(1)
+		}
+
+		webhookTLSOpts = append(webhookTLSOpts, func(config *tls.Config) {
+			config.GetCertificate = webhookCertWatcher.GetCertificate
+		})
+	}
+
 	webhookServer := webhook.NewServer(webhook.Options{
-		TLSOpts: tlsOpts,
+		Port:    cfg.Webhook.Port,
+		TLSOpts: webhookTLSOpts,
 	})
 
+	shutdownTracing := func(context.Context) error { return nil }
+	if cfg.Tracing.OTLPEndpoint != "" {
+		shutdownTracing, err = tracing.Setup(context.Background(), tracing.Options{
+			Endpoint:    cfg.Tracing.OTLPEndpoint,
+			Insecure:    cfg.Tracing.Insecure,
+			SampleRatio: cfg.Tracing.SampleRatio,
+			ServiceName: "namespacelabel-controller-manager",
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			os.Exit(1)
+		}
+		webhookServer = tracing.WrapWebhookServer(webhookServer)
+	}
+
 	// Metrics endpoint is enabled in 'config/default/kustomization.yaml'. The Metrics options configure the server.
 	// More info:
 	// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.0/pkg/metrics/server
 	// - https://book.kubebuilder.io/reference/metrics.html
 	metricsServerOptions := metricsserver.Options{
-		BindAddress:   metricsAddr,
-		SecureServing: secureMetrics,
-		// TODO(user): TLSOpts is used to allow configuring the TLS config used for the server. If certificates are
-		// not provided, self-signed certificates will be generated by default. This option is not recommended for
-		// production environments as self-signed certificates do not offer the same level of trust and security
-		// as certificates issued by a trusted Certificate Authority (CA). The primary risk is potentially allowing
-		// unauthorized access to sensitive metrics data. Consider replacing with CertDir, CertName, and KeyName
-		// to provide certificates, ensuring the server communicates using trusted and secure certificates.
-		TLSOpts: tlsOpts,
-	}
-
-	if secureMetrics {
+		BindAddress:   cfg.Metrics.BindAddress,
+		SecureServing: cfg.Metrics.Secure,
+		TLSOpts:       tlsOpts,
+	}
+
+	if cfg.Metrics.Secure {
 		// FilterProvider is used to protect the metrics endpoint with authn/authz.
 		// These configurations ensure that only authorized users and service accounts
 		// can access the metrics endpoint. The RBAC are configured in 'config/rbac/kustomization.yaml'. More info:
@@ -120,13 +189,83 @@ func main() {
 		metricsServerOptions.FilterProvider = filters.WithAuthenticationAndAuthorization
 	}
 
+	// If the certificate is not specified, controller-runtime will automatically
+	// generate self-signed certificates for the metrics server. While convenient for development and testing,
+	// this setup is not recommended for production: self-signed certificates do not offer the same level of
+	// trust as certificates issued by a trusted Certificate Authority (CA), and potentially allow unauthorized
+	// access to sensitive metrics data. Use --metrics-cert-path to serve certificates mounted from a Secret
+	// (for example one managed by cert-manager, see 'config/default/cert_metrics_manager_patch.yaml').
+	metricsCertFile := filepath.Join(cfg.Metrics.CertPath, cfg.Metrics.CertName)
+	metricsKeyFile := filepath.Join(cfg.Metrics.CertPath, cfg.Metrics.CertKey)
+	if len(cfg.Metrics.CertPath) > 0 {
+		setupLog.Info("Initializing metrics certificate watcher using provided certificates",
+			"metrics-cert-path", cfg.Metrics.CertPath, "metrics-cert-name", cfg.Metrics.CertName,
+			"metrics-cert-key", cfg.Metrics.CertKey)
+
+		metricsCertWatcher, err = certwatcher.New(metricsCertFile, metricsKeyFile)
+		if err != nil {
+			setupLog.Error(err, "Failed to initialize metrics certificate watcher")
+			os.Exit(1)
+		}
+
+		metricsServerOptions.TLSOpts = append(metricsServerOptions.TLSOpts, func(config *tls.Config) {
+			config.GetCertificate = metricsCertWatcher.GetCertificate
+		})
+	}
+
+	// Namespaces are only cached as metadata and cachetrim drops managedFields and the
+	// last-applied-configuration annotation from every cached object. The policy ConfigMap
+	// is the only ConfigMap the manager reads, so the cache is limited to it.
+	var policyConfigMap types.NamespacedName
+	cacheOptions := cache.Options{ByObject: map[client.Object]cache.ByObject{}}
+	var clientOptions client.Options
+	if cfg.PolicyConfigMap.Name != "" {
+		policyConfigMap = types.NamespacedName{Namespace: cfg.PolicyConfigMap.Namespace, Name: cfg.PolicyConfigMap.Name}
+		if policyConfigMap.Namespace == "" {
+			policyConfigMap.Namespace = os.Getenv("POD_NAMESPACE")
+		}
+		if policyConfigMap.Namespace == "" {
+			setupLog.Error(nil, "the namespace of the policy ConfigMap is unknown, "+
+				"set --policy-configmap-namespace or the POD_NAMESPACE environment variable")
+			os.Exit(1)
+		}
+		cacheOptions.ByObject[&corev1.ConfigMap{}] = cache.ByObject{
+			Namespaces: map[string]cache.Config{policyConfigMap.Namespace: {}},
+			Field:      fields.OneTermEqualSelector("metadata.name", policyConfigMap.Name),
+		}
+	}
+
+	// The scope limits the cache to the managed namespaces. With a static list, Namespaces cannot be
+	// watched, so their metadata is read from the API server whenever a NamespaceLabel is reconciled.
+	var namespaceSelector labels.Selector
+	if len(cfg.Scope.Namespaces) > 0 {
+		cacheOptions.DefaultNamespaces = map[string]cache.Config{}
+		for _, ns := range cfg.Scope.Namespaces {
+			cacheOptions.DefaultNamespaces[ns] = cache.Config{}
+		}
+		clientOptions.Cache = &client.CacheOptions{DisableFor: []client.Object{&corev1.Namespace{}}}
+	}
+	if cfg.Scope.NamespaceSelector != "" {
+		// Validated with the configuration.
+		namespaceSelector, _ = labels.Parse(cfg.Scope.NamespaceSelector)
+		namespaces := &metav1.PartialObjectMetadata{}
+		namespaces.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+		cacheOptions.ByObject[namespaces] = cache.ByObject{Label: namespaceSelector}
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		Metrics:                metricsServerOptions,
-		WebhookServer:          webhookServer,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "4d82f92d.namespacelabel.io",
+		Scheme:                  scheme,
+		Cache:                   cachetrim.Options(cacheOptions),
+		Client:                  clientOptions,
+		Metrics:                 metricsServerOptions,
+		WebhookServer:           webhookServer,
+		HealthProbeBindAddress:  cfg.Health.ProbeBindAddress,
+		LeaderElection:          cfg.LeaderElection.Enabled,
+		LeaderElectionID:        cfg.LeaderElection.ID,
+		LeaderElectionNamespace: cfg.LeaderElection.Namespace,
+		LeaseDuration:           &cfg.LeaderElection.LeaseDuration.Duration,
+		RenewDeadline:           &cfg.LeaderElection.RenewDeadline.Duration,
+		RetryPeriod:             &cfg.LeaderElection.RetryPeriod.Duration,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -144,27 +283,306 @@ func main() {
 		os.Exit(1)
 	}
 
+	var auditSinks []audit.Sink
+	if cfg.Audit.Stdout {
+		auditSinks = append(auditSinks, audit.NewWriterSink(os.Stdout))
+	}
+	if cfg.Audit.File != "" {
+		fileSink, err := audit.NewFileSink(cfg.Audit.File, int64(cfg.Audit.FileMaxSizeMB)*1024*1024,
+			cfg.Audit.FileMaxBackups)
+		if err != nil {
+			setupLog.Error(err, "unable to open audit file", "path", cfg.Audit.File)
+			os.Exit(1)
+		}
+		auditSinks = append(auditSinks, fileSink)
+	}
+	if cfg.Audit.WebhookURL != "" {
+		auditSinks = append(auditSinks, audit.NewWebhookSink(cfg.Audit.WebhookURL, cfg.Audit.WebhookTimeout.Duration))
+	}
+	var auditor *audit.Auditor
+	if len(auditSinks) > 0 {
+		key, err := audit.LoadKey(cfg.Audit.KeyFile)
+		if err != nil {
+			setupLog.Error(err, "unable to load audit key")
+			os.Exit(1)
+		}
+		auditor = audit.New(key, cfg.Audit.QueueSize, auditSinks...)
+	}
+
+	policyStore := config.NewPolicyStore(cfg.Policy)
+	var policyUpdates chan event.GenericEvent
+	if cfg.PolicyConfigMap.Name != "" {
+		policyUpdates = make(chan event.GenericEvent, 1024)
+		policyReconciler := &controller.PolicyReconciler{
+			Client:    mgr.GetClient(),
+			Recorder:  mgr.GetEventRecorderFor("namespacelabel-policy"),
+			ConfigMap: policyConfigMap,
+			Base:      cfg.Policy,
+			Store:     policyStore,
+			Updates:   policyUpdates,
+		}
+		if err := policyReconciler.Preload(context.Background(), mgr.GetAPIReader()); err != nil {
+			setupLog.Error(err, "unable to load the policy ConfigMap, using the configured policy",
+				"configmap", policyConfigMap)
+		}
+		if err := policyReconciler.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Policy")
+			os.Exit(1)
+		}
+	}
+
+	// With a shard index, this replica owns a single shard. Otherwise the replicas share the shards
+	// through Leases, and the NamespaceLabels of the shards a replica takes over are reconciled again.
+	var shard sharding.Filter
+	var shardUpdates chan event.GenericEvent
+	if cfg.Sharding.Shards > 0 && cfg.Sharding.Index >= 0 {
+		shard = sharding.Static{Index: cfg.Sharding.Index, Shards: cfg.Sharding.Shards}
+		metrics.OwnedShards.Set(1)
+	} else if cfg.Sharding.Shards > 0 {
+		leaseNamespace := cfg.Sharding.LeaseNamespace
+		if leaseNamespace == "" {
+			leaseNamespace = os.Getenv("POD_NAMESPACE")
+		}
+		if leaseNamespace == "" {
+			setupLog.Error(nil, "the namespace of the shard Leases is unknown, "+
+				"set --shard-lease-namespace or the POD_NAMESPACE environment variable")
+			os.Exit(1)
+		}
+		hostname, err := os.Hostname()
+		if err != nil {
+			setupLog.Error(err, "unable to get hostname")
+			os.Exit(1)
+		}
+		// Leases are read directly, since a stale cache could let two replicas own a shard.
+		leaseClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+		if err != nil {
+			setupLog.Error(err, "unable to create shard lease client")
+			os.Exit(1)
+		}
+		shardUpdates = make(chan event.GenericEvent, 1024)
+		coordinator := &sharding.Coordinator{
+			Client:        leaseClient,
+			Namespace:     leaseNamespace,
+			Name:          cfg.Sharding.LeaseName,
+			Identity:      hostname + "_" + string(uuid.NewUUID()),
+			Shards:        cfg.Sharding.Shards,
+			LeaseDuration: cfg.Sharding.LeaseDuration.Duration,
+			RenewPeriod:   cfg.Sharding.RenewPeriod.Duration,
+			SafetyMargin:  cfg.Sharding.LeaseSafetyMargin.Duration,
+			OnAcquire:     controller.RequeueShards(mgr.GetClient(), cfg.Sharding.Shards, shardUpdates),
+			OnRelease:     controller.ForgetShards(cfg.Sharding.Shards),
+		}
+		if err := mgr.Add(coordinator); err != nil {
+			setupLog.Error(err, "unable to add shard coordinator to manager")
+			os.Exit(1)
+		}
+		shard = coordinator
+	}
+
+	// Writes wait for the write budget inside their trace spans, so throttling shows up in traces.
+	writeClient := tracing.WrapClient(
+		ratelimit.WrapClient(mgr.GetClient(), cfg.Controller.WriteQPS, cfg.Controller.WriteBurst))
+	rateLimiter := ratelimit.NewControllerRateLimiter(cfg.Controller.BaseBackoff.Duration,
+		cfg.Controller.MaxBackoff.Duration, cfg.Controller.QPS, cfg.Controller.Burst)
+	reconcileTracker := &health.ReconcileTracker{QueueDepth: health.WorkqueueDepth(controller.ControllerName)}
 	if err = (&controller.NamespaceLabelReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                  writeClient,
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("namespacelabel-controller"),
+		Audit:                   auditor,
+		Health:                  reconcileTracker,
+		Policy:                  policyStore,
+		PolicyUpdates:           policyUpdates,
+		MaxConcurrentReconciles: cfg.Controller.MaxConcurrentReconciles,
+		RateLimiter:             rateLimiter,
+		Namespaces:              cfg.Scope.Namespaces,
+		NamespaceSelector:       namespaceSelector,
+		Shard:                   shard,
+		ShardUpdates:            shardUpdates,
+		Inheritance:             cfg.Inheritance,
+		QueueTimer:              &tracing.QueueTimer{},
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NamespaceLabel")
 		os.Exit(1)
 	}
+	// Requirements are evaluated against every Namespace, which a static namespace list cannot watch.
+	requirements := len(cfg.Scope.Namespaces) == 0
+	if requirements {
+		// Sharded replicas do not elect a leader, so a second manager elects the single replica
+		// that evaluates requirements, independently of the shards it owns.
+		requirementMgr, requirementClient := mgr, writeClient
+		if cfg.Sharding.Shards > 0 {
+			electionNamespace := cfg.LeaderElection.Namespace
+			if electionNamespace == "" {
+				electionNamespace = os.Getenv("POD_NAMESPACE")
+			}
+			requirementMgr, err = ctrl.NewManager(mgr.GetConfig(), ctrl.Options{
+				Scheme:                        scheme,
+				Cache:                         cachetrim.Options(cacheOptions),
+				Client:                        clientOptions,
+				Metrics:                       metricsserver.Options{BindAddress: "0"},
+				HealthProbeBindAddress:        "0",
+				LeaderElection:                true,
+				LeaderElectionID:              requirementLeaderElectionPrefix + cfg.LeaderElection.ID,
+				LeaderElectionNamespace:       electionNamespace,
+				LeaseDuration:                 &cfg.LeaderElection.LeaseDuration.Duration,
+				RenewDeadline:                 &cfg.LeaderElection.RenewDeadline.Duration,
+				RetryPeriod:                   &cfg.LeaderElection.RetryPeriod.Duration,
+				LeaderElectionReleaseOnCancel: true,
+			})
+			if err != nil {
+				setupLog.Error(err, "unable to create the NamespaceLabelRequirement manager")
+				os.Exit(1)
+			}
+			requirementClient = tracing.WrapClient(ratelimit.WrapClient(requirementMgr.GetClient(),
+				cfg.Controller.WriteQPS, cfg.Controller.WriteBurst))
+			// Added as a plain function, since the main manager would otherwise treat it as a cache.
+			if err := mgr.Add(manager.RunnableFunc(requirementMgr.Start)); err != nil {
+				setupLog.Error(err, "unable to add the NamespaceLabelRequirement manager to manager")
+				os.Exit(1)
+			}
+		}
+		if err = (&controller.NamespaceLabelRequirementReconciler{
+			Client:     requirementClient,
+			Recorder:   requirementMgr.GetEventRecorderFor("namespacelabelrequirement-controller"),
+			Audit:      auditor,
+			Policy:     policyStore,
+			QueueTimer: &tracing.QueueTimer{},
+		}).SetupWithManager(requirementMgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NamespaceLabelRequirement")
+			os.Exit(1)
+		}
+	} else {
+		setupLog.Info("NamespaceLabelRequirements are not evaluated with a static namespace scope")
+	}
+	if cfg.Webhook.Enabled {
+		if err = webhookdanateamv1.SetupNamespaceLabelWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "NamespaceLabel")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
-	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up health check")
-		os.Exit(1)
+	if metricsCertWatcher != nil {
+		setupLog.Info("Adding metrics certificate watcher to manager")
+		if err := mgr.Add(metricsCertWatcher); err != nil {
+			setupLog.Error(err, "unable to add metrics certificate watcher to manager")
+			os.Exit(1)
+		}
+		if err := mgr.AddReadyzCheck("metrics-cert",
+			health.CertificateChecker(metricsCertWatcher, metricsCertFile, metricsKeyFile)); err != nil {
+			setupLog.Error(err, "unable to set up metrics certificate ready check")
+			os.Exit(1)
+		}
+	}
+
+	if webhookCertWatcher != nil {
+		setupLog.Info("Adding webhook certificate watcher to manager")
+		if err := mgr.Add(webhookCertWatcher); err != nil {
+			setupLog.Error(err, "unable to add webhook certificate watcher to manager")
+			os.Exit(1)
+		}
+		if err := mgr.AddReadyzCheck("webhook-cert",
+			health.CertificateChecker(webhookCertWatcher, webhookCertFile, webhookKeyFile)); err != nil {
+			setupLog.Error(err, "unable to set up webhook certificate ready check")
+			os.Exit(1)
+		}
+	}
+
+	if cfg.Webhook.Enabled {
+		// Only check the webhook server when the webhooks are served: asking the manager for it adds it to
+		// the runnables, and it cannot start without certificates.
+		if err := mgr.AddReadyzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
+			setupLog.Error(err, "unable to set up webhook ready check")
+			os.Exit(1)
+		}
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up ready check")
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create discovery client")
 		os.Exit(1)
 	}
+	healthChecks := map[string]healthz.Checker{
+		"healthz":   healthz.Ping,
+		"workqueue": reconcileTracker.LiveChecker(),
+	}
+	readyChecks := map[string]healthz.Checker{
+		"readyz":     healthz.Ping,
+		"crd":        health.CRDChecker(discoveryClient, danateamv1.GroupVersion, "namespacelabels"),
+		"cache-sync": health.CacheSyncChecker(mgr.GetCache()),
+		"reconcile":  reconcileTracker.ReadyChecker(),
+	}
+	if requirements {
+		readyChecks["requirement-crd"] = health.CRDChecker(discoveryClient, danateamv1.GroupVersion,
+			"namespacelabelrequirements")
+	}
+	for name, check := range healthChecks {
+		if err := mgr.AddHealthzCheck(name, check); err != nil {
+			setupLog.Error(err, "unable to set up health check", "check", name)
+			os.Exit(1)
+		}
+	}
+	for name, check := range readyChecks {
+		if err := mgr.AddReadyzCheck(name, check); err != nil {
+			setupLog.Error(err, "unable to set up ready check", "check", name)
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+	if err := auditor.Close(); err != nil {
+		setupLog.Error(err, "unable to close audit sinks")
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		setupLog.Error(err, "unable to flush traces")
+	}
+}
+
+// runCleanup implements the cleanup subcommand, which removes the labels managed by the operator from
+// every Namespace and the finalizers of all NamespaceLabels, so that the operator can be uninstalled
+// without leaving labels behind. It returns the exit code.
+func runCleanup(args []string) int {
+	fs := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Report what would be removed without changing anything.")
+	output := fs.String("output", "text", "The output format, text or json.")
+	ctrlconfig.RegisterFlags(fs)
+	opts := zap.Options{}
+	opts.BindFlags(fs)
+	_ = fs.Parse(args)
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "unsupported output format %q, use text or json\n", *output)
+		return 2
+	}
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create client: %v\n", err)
+		return 1
+	}
+
+	report, runErr := cleanup.Run(ctrl.SetupSignalHandler(), c, *dryRun)
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write report: %v\n", err)
+		return 1
+	}
+	if runErr != nil {
+		fmt.Fprintf(os.Stderr, "cleanup failed: %v\n", runErr)
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,357 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit writes a trail of the label mutations the operator makes to
+// Namespaces in which edited, reordered and removed records can be detected.
+//
+// Every mutation is written as one JSON line. Records carry a sequence number
+// and are chained: each record's hash is an HMAC-SHA256, keyed with a secret
+// the manager reads at startup, over its own content and the hash of the
+// record before it. Without the key a record cannot be forged, so editing,
+// reordering or removing a line breaks the chain. The first record written by
+// a manager starts a new chain at sequence 1 and is marked as such; Verify
+// only accepts a chain restart that carries this authenticated mark. Records
+// dropped from the end of a trail, or whole chains between two restarts,
+// cannot be detected from the trail alone.
+//
+// Records are handed to the sinks by a background writer with a bounded
+// queue, so a slow sink does not hold up reconciles. Records that do not fit
+// into the queue are dropped and counted, as are failed sink writes. Dropped
+// records do not advance the chain; the next record that fits is preceded by
+// a chained ReasonRecordsDropped record stating how many were lost, so gaps
+// are visible in the trail itself.
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/matanamar10/namesapcelabel/internal/metrics"
+)
+
+// Reason describes why a Namespace's labels were changed.
+type Reason string
+
+const (
+	// ReasonSpecChange is used when labels change because a NamespaceLabel spec changed.
+	ReasonSpecChange Reason = "spec-change"
+	// ReasonDrift is used when managed labels changed outside the operator are restored.
+	ReasonDrift Reason = "drift"
+	// ReasonCleanup is used when labels are removed because their NamespaceLabel is deleted.
+	ReasonCleanup Reason = "cleanup"
+	// ReasonRequirementDefault is used when a NamespaceLabelRequirement fills
+	// in the default value of a missing label.
+	ReasonRequirementDefault Reason = "requirement-default"
+	// ReasonInherited is used when labels inherited from the parent
+	// namespaces of a Namespace change.
+	ReasonInherited Reason = "inherited"
+	// ReasonRecordsDropped marks the place of records dropped because the
+	// queue was full. Dropped holds their number.
+	ReasonRecordsDropped Reason = "records-dropped"
+)
+
+// Record is a single audited mutation. Mutations made by a
+// NamespaceLabelRequirement name it in Requirement instead of a NamespaceLabel,
+// and inherited labels name neither.
+type Record struct {
+	Sequence       uint64            `json:"sequence"`
+	Timestamp      time.Time         `json:"timestamp"`
+	Namespace      string            `json:"namespace"`
+	NamespaceLabel string            `json:"namespaceLabel"`
+	Requirement    string            `json:"requirement,omitempty"`
+	Generation     int64             `json:"generation"`
+	Reason         Reason            `json:"reason"`
+	Before         map[string]string `json:"before"`
+	After          map[string]string `json:"after"`
+	// Dropped is the number of records dropped before a
+	// ReasonRecordsDropped record.
+	Dropped uint64 `json:"dropped,omitempty"`
+	// ChainStart marks the first record of a chain, written when the
+	// manager starts.
+	ChainStart   bool   `json:"chainStart,omitempty"`
+	PreviousHash string `json:"previousHash,omitempty"`
+	Hash         string `json:"hash"`
+}
+
+// Sink receives encoded records, one JSON document per call.
+type Sink interface {
+	Write(ctx context.Context, line []byte) error
+	Close() error
+}
+
+// MinKeySize is the minimum length in bytes of the key records are
+// authenticated with.
+const MinKeySize = 32
+
+// DefaultQueueSize is the default number of records waiting to be written to
+// the sinks.
+const DefaultQueueSize = 1024
+
+// ErrQueueFull is returned by Record when the record was dropped because the
+// sinks do not keep up.
+var ErrQueueFull = errors.New("audit queue is full, record dropped")
+
+// Auditor stamps records with their position in the chain and queues them for
+// every sink. It is safe for concurrent use.
+type Auditor struct {
+	mu       sync.Mutex
+	key      []byte
+	sinks    []Sink
+	sequence uint64
+	lastHash string
+	// dropped counts the records dropped since the last one queued.
+	dropped uint64
+	closed  bool
+	now     func() time.Time
+
+	queue chan []byte
+	done  chan struct{}
+}
+
+// New returns an Auditor authenticating records with key and writing them to
+// sinks through a queue of queueSize records. Close must be called to flush
+// the queue.
+func New(key []byte, queueSize int, sinks ...Sink) *Auditor {
+	a := &Auditor{
+		key:   key,
+		sinks: sinks,
+		now:   time.Now,
+		queue: make(chan []byte, queueSize),
+		done:  make(chan struct{}),
+	}
+	go a.write()
+	return a
+}
+
+// LoadKey reads the key records are authenticated with from path, usually a
+// mounted Secret. Surrounding whitespace is ignored.
+func LoadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading audit key: %w", err)
+	}
+	key := []byte(strings.TrimSpace(string(data)))
+	if len(key) < MinKeySize {
+		return nil, fmt.Errorf("audit key in %s must be at least %d bytes", path, MinKeySize)
+	}
+	return key, nil
+}
+
+// Record queues r for every sink. Sequence, Timestamp, ChainStart,
+// PreviousHash and Hash are filled in by the Auditor. When the queue is full
+// the record is dropped without advancing the chain and ErrQueueFull is
+// returned; the next record queued is preceded by a ReasonRecordsDropped
+// record. Failed sink writes are counted and logged by the background writer.
+func (a *Auditor) Record(_ context.Context, r Record) error {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return errors.New("audit trail is closed")
+	}
+
+	if a.dropped > 0 {
+		queued, err := a.enqueue(Record{Reason: ReasonRecordsDropped, Dropped: a.dropped}, false)
+		if err != nil {
+			return err
+		}
+		if !queued {
+			a.drop()
+			return ErrQueueFull
+		}
+		a.dropped = 0
+	}
+	queued, err := a.enqueue(r, false)
+	if err != nil {
+		return err
+	}
+	if !queued {
+		a.drop()
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// enqueue stamps r as the next record of the chain and queues it, waiting
+// for room when block is set. The chain only advances when r was queued.
+// a.mu must be held.
+func (a *Auditor) enqueue(r Record, block bool) (bool, error) {
+	r.Sequence = a.sequence + 1
+	r.Timestamp = a.now().UTC()
+	r.ChainStart = r.Sequence == 1
+	r.PreviousHash = a.lastHash
+	hash, err := hashOf(a.key, r)
+	if err != nil {
+		return false, err
+	}
+	r.Hash = hash
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return false, err
+	}
+	line = append(line, '\n')
+
+	if block {
+		a.queue <- line
+	} else {
+		select {
+		case a.queue <- line:
+		default:
+			return false, nil
+		}
+	}
+	a.sequence = r.Sequence
+	a.lastHash = hash
+	return true, nil
+}
+
+// drop counts a record dropped because the queue is full. a.mu must be held.
+func (a *Auditor) drop() {
+	a.dropped++
+	metrics.AuditRecordsDropped.Inc()
+}
+
+// write hands queued records to every sink until the queue is closed.
+// Writing continues with the remaining sinks when one fails.
+func (a *Auditor) write() {
+	defer close(a.done)
+	logger := log.Log.WithName("audit")
+	for line := range a.queue {
+		for _, sink := range a.sinks {
+			if err := sink.Write(context.Background(), line); err != nil {
+				metrics.AuditSinkErrors.WithLabelValues(sinkName(sink)).Inc()
+				logger.Error(err, "unable to write audit record", "sink", sinkName(sink))
+			}
+		}
+	}
+}
+
+// Close writes the queued records, and a ReasonRecordsDropped record for
+// records dropped since the last one queued, then closes every sink.
+func (a *Auditor) Close() error {
+	if a == nil {
+		return nil
+	}
+	var errs []error
+	a.mu.Lock()
+	if !a.closed {
+		if a.dropped > 0 {
+			// The writer drains the queue, so the last records dropped are
+			// marked at the end of the trail.
+			if _, err := a.enqueue(Record{Reason: ReasonRecordsDropped, Dropped: a.dropped}, true); err != nil {
+				errs = append(errs, err)
+			}
+			a.dropped = 0
+		}
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+	<-a.done
+
+	for _, sink := range a.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Verify reads a trail written by an Auditor using key and returns an error
+// describing the first record whose hash does not authenticate its content,
+// or that does not follow the record before it. A record follows the one
+// before it when it has the next sequence number and chains to its hash, or
+// when it is marked as the start of a new chain.
+//
+// The first record must follow the record whose hash is after. An empty after
+// requires the trail to begin with the start of a chain; to verify a rotated
+// file that continues an earlier one, pass the hash of the last record of
+// that file, or verify the files together, oldest first.
+func Verify(r io.Reader, key []byte, after string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	previous := &Record{Hash: after}
+	for line := 1; scanner.Scan(); line++ {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		hash, err := hashOf(key, rec)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if !hmac.Equal([]byte(hash), []byte(rec.Hash)) {
+			return fmt.Errorf("line %d: record %d was modified or not written with this key", line, rec.Sequence)
+		}
+		switch {
+		case rec.ChainStart:
+			if rec.Sequence != 1 || rec.PreviousHash != "" {
+				return fmt.Errorf("line %d: record %d is not a valid chain start", line, rec.Sequence)
+			}
+		case previous.Hash == "":
+			return fmt.Errorf("line %d: record %d does not start a chain", line, rec.Sequence)
+		case rec.PreviousHash != previous.Hash ||
+			(previous.Sequence != 0 && rec.Sequence != previous.Sequence+1):
+			return fmt.Errorf("line %d: record %d does not follow record %d", line, rec.Sequence, previous.Sequence)
+		}
+		previous = &rec
+	}
+	return scanner.Err()
+}
+
+// hashOf returns the hex encoded HMAC-SHA256 of r without its Hash field.
+func hashOf(key []byte, r Record) (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// sinkName returns the name failed writes to sink are counted under.
+func sinkName(sink Sink) string {
+	switch sink.(type) {
+	case *WriterSink:
+		return "writer"
+	case *FileSink:
+		return "file"
+	case *WebhookSink:
+		return "webhook"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,366 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/matanamar10/namesapcelabel/internal/metrics"
+)
+
+func record(ns string) Record {
+	return Record{
+		Namespace:      ns,
+		NamespaceLabel: "labels",
+		Generation:     2,
+		Reason:         ReasonSpecChange,
+		Before:         map[string]string{"team": "a"},
+		After:          map[string]string{"team": "b"},
+	}
+}
+
+var key = []byte("0123456789abcdef0123456789abcdef")
+
+func TestAuditorChain(t *testing.T) {
+	var buf bytes.Buffer
+	a := New(key, DefaultQueueSize, NewWriterSink(&buf))
+	for _, ns := range []string{"one", "two", "three"} {
+		if err := a.Record(context.Background(), record(ns)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(bytes.NewReader(buf.Bytes()), key, ""); err != nil {
+		t.Fatalf("unmodified trail: %v", err)
+	}
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	var first Record
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if !first.ChainStart {
+		t.Error("first record should start a chain")
+	}
+
+	if err := Verify(bytes.NewReader(buf.Bytes()), []byte("another key of at least 32 bytes"), ""); err == nil {
+		t.Error("wrong key: expected error")
+	}
+
+	tampered := strings.Replace(buf.String(), `"team":"b"`, `"team":"c"`, 1)
+	if err := Verify(strings.NewReader(tampered), key, ""); err == nil {
+		t.Error("modified record: expected error")
+	}
+
+	removed := lines[0] + lines[2]
+	if err := Verify(strings.NewReader(removed), key, ""); err == nil {
+		t.Error("removed record: expected error")
+	}
+
+	if err := Verify(strings.NewReader(lines[1]+lines[2]), key, ""); err == nil {
+		t.Error("trail starting mid-chain without an anchor: expected error")
+	}
+	if err := Verify(strings.NewReader(lines[1]+lines[2]), key, first.Hash); err != nil {
+		t.Errorf("trail continuing the anchor: %v", err)
+	}
+
+	// A restart forged by rewriting a record to sequence 1 is rejected, even
+	// with its hash recomputed without the key.
+	var third Record
+	if err := json.Unmarshal([]byte(lines[2]), &third); err != nil {
+		t.Fatal(err)
+	}
+	third.Sequence, third.PreviousHash, third.ChainStart = 1, "", true
+	third.Hash, _ = hashOf([]byte("guessed key, not the audit key!!"), third)
+	forged, _ := json.Marshal(third)
+	if err := Verify(strings.NewReader(lines[0]+string(forged)+"\n"), key, ""); err == nil {
+		t.Error("forged restart: expected error")
+	}
+
+	// A genuine restart of the manager starts an authenticated new chain.
+	var restarted bytes.Buffer
+	b := New(key, DefaultQueueSize, NewWriterSink(&restarted))
+	if err := b.Record(context.Background(), record("four")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(strings.NewReader(buf.String()+restarted.String()), key, ""); err != nil {
+		t.Errorf("trail with a restart: %v", err)
+	}
+}
+
+// blockingSink blocks every write until release is closed.
+type blockingSink struct {
+	release chan struct{}
+}
+
+func (s *blockingSink) Write(context.Context, []byte) error {
+	<-s.release
+	return nil
+}
+
+func (s *blockingSink) Close() error { return nil }
+
+func TestAuditorQueueFull(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	var buf bytes.Buffer
+	a := New(key, 1, sink, NewWriterSink(&buf))
+
+	before := testutil.ToFloat64(metrics.AuditRecordsDropped)
+	var dropped int
+	for i := 0; i < 5; i++ {
+		if err := a.Record(context.Background(), record("ns")); errors.Is(err, ErrQueueFull) {
+			dropped++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(sink.release)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if dropped == 0 {
+		t.Fatal("expected records to be dropped while the sink is blocked")
+	}
+	if got := testutil.ToFloat64(metrics.AuditRecordsDropped) - before; got != float64(dropped) {
+		t.Errorf("dropped records metric = %v, want %d", got, dropped)
+	}
+	// Every drop is accounted for by a chained marker, the last one written
+	// when the Auditor is closed.
+	var written, marked int
+	var last Record
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if err := json.Unmarshal([]byte(line), &last); err != nil {
+			t.Fatal(err)
+		}
+		if last.Reason == ReasonRecordsDropped {
+			marked += int(last.Dropped)
+		} else {
+			written++
+		}
+	}
+	if written != 5-dropped || marked != dropped {
+		t.Errorf("written records = %d and marked as dropped = %d, want %d and %d", written, marked, 5-dropped, dropped)
+	}
+	if last.Reason != ReasonRecordsDropped {
+		t.Errorf("last record is %s, want the records dropped at the end to be marked", last.Reason)
+	}
+	if err := Verify(bytes.NewReader(buf.Bytes()), key, ""); err != nil {
+		t.Errorf("dropped records should not break the chain: %v", err)
+	}
+}
+
+func TestFileSinkRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(path, 300, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(key, DefaultQueueSize, sink)
+	for i := 0; i < 4; i++ {
+		if err := a.Record(context.Background(), record("ns")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var trail bytes.Buffer
+	for _, name := range []string{path + ".3", path + ".2", path + ".1", path} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trail.Write(data)
+	}
+	if err := Verify(&trail, key, ""); err != nil {
+		t.Errorf("rotated files, oldest first: %v", err)
+	}
+
+	oldest, err := os.ReadFile(path + ".3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last Record
+	if err := json.Unmarshal(bytes.TrimSpace(oldest), &last); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path + ".2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+	if err := Verify(f, key, last.Hash); err != nil {
+		t.Errorf("rotated file anchored to the one before it: %v", err)
+	}
+
+	path = filepath.Join(t.TempDir(), "audit.log")
+	sink, err = NewFileSink(path, 300, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a = New(key, DefaultQueueSize, sink)
+	for i := 0; i < 10; i++ {
+		if err := a.Record(context.Background(), record("ns")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Errorf("expected at most 1 backup, stat %s.2: %v", path, err)
+	}
+}
+
+func TestFileSinkKeepsWritingWhenRotationFails(t *testing.T) {
+	var buf bytes.Buffer
+	a := New(key, DefaultQueueSize, NewWriterSink(&buf))
+	for i := 0; i < 4; i++ {
+		if err := a.Record(context.Background(), record("ns")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(path, 300, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := sink.Write(ctx, []byte(lines[0])); err != nil {
+		t.Fatal(err)
+	}
+	openFile = func(string, int, os.FileMode) (*os.File, error) { return nil, errors.New("no space left") }
+	defer func() { openFile = os.OpenFile }()
+	for _, line := range lines[1:3] {
+		if err := sink.Write(ctx, []byte(line)); err == nil {
+			t.Fatal("expected the rotation to fail")
+		}
+	}
+	openFile = os.OpenFile
+	if err := sink.Write(ctx, []byte(lines[3])); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The records written while rotating failed went to the moved file, and
+	// the files hold the whole trail.
+	var trail bytes.Buffer
+	for _, name := range []string{path + ".1", path} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trail.Write(data)
+	}
+	if trail.String() != buf.String() {
+		t.Errorf("got trail\n%s\nwant\n%s", trail.String(), buf.String())
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Errorf("failed rotations should not shift backups again, stat %s.2: %v", path, err)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	received := make(chan Record, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var rec Record
+		if err := json.Unmarshal(body, &rec); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- rec
+	}))
+	defer srv.Close()
+
+	a := New(key, DefaultQueueSize, NewWebhookSink(srv.URL, time.Second))
+	if err := a.Record(context.Background(), record("payments")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if rec := <-received; rec.Namespace != "payments" || rec.Sequence != 1 || rec.Hash == "" {
+		t.Errorf("unexpected record %+v", rec)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	errorsBefore := testutil.ToFloat64(metrics.AuditSinkErrors.WithLabelValues("webhook"))
+	a = New(key, DefaultQueueSize, NewWebhookSink(failing.URL, time.Second))
+	if err := a.Record(context.Background(), record("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(metrics.AuditSinkErrors.WithLabelValues("webhook")) - errorsBefore; got != 1 {
+		t.Errorf("webhook sink errors = %v, want 1", got)
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "key")
+	if err := os.WriteFile(path, append(append([]byte{}, key...), '\n'), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, key) {
+		t.Errorf("LoadKey = %q, want %q", got, key)
+	}
+
+	short := filepath.Join(dir, "short")
+	if err := os.WriteFile(short, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKey(short); err == nil {
+		t.Error("short key: expected error")
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// WriterSink writes records to an io.Writer such as os.Stdout.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink returns a sink writing to w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Write implements Sink.
+func (s *WriterSink) Write(_ context.Context, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.w.Write(line)
+	return err
+}
+
+// Close implements Sink. The underlying writer is left open.
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FileSink appends records to a file and rotates it once it grows past
+// MaxSize bytes. Rotated files are renamed to <path>.1, <path>.2, ... and at
+// most MaxBackups of them are kept.
+type FileSink struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// renamed is set once the current file was moved to <path>.1 by a
+	// rotation that failed to open the next file.
+	renamed bool
+}
+
+// openFile opens the audit file; tests replace it to make opening fail.
+var openFile = os.OpenFile
+
+// NewFileSink opens path for appending, creating it if needed.
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{Path: path, MaxSize: maxSize, MaxBackups: maxBackups}
+	if err := s.open(0); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write implements Sink. When the file cannot be rotated, line is still
+// appended to the current file and the rotation is retried with the next
+// record.
+func (s *FileSink) Write(_ context.Context, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rotateErr error
+	if s.MaxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.MaxSize {
+		rotateErr = s.rotate()
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return errors.Join(rotateErr, err)
+}
+
+// Close implements Sink.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// open opens Path for appending, with the additional flags, and makes it
+// the current file. The current file is left alone when opening fails.
+func (s *FileSink) open(flags int) error {
+	f, err := openFile(s.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|flags, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening audit file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("opening audit file: %w", err)
+	}
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// rotate moves the current file to <path>.1, shifting older backups, or
+// truncates it without backups, and continues with a new file. The current
+// file is only closed once the new one is open, so that records keep being
+// written, to the moved file if need be, when rotating fails.
+func (s *FileSink) rotate() error {
+	old := s.file
+	if s.MaxBackups == 0 {
+		if err := s.open(os.O_TRUNC); err != nil {
+			return fmt.Errorf("rotating audit file: %w", err)
+		}
+		return old.Close()
+	}
+
+	if !s.renamed {
+		for i := s.MaxBackups - 1; i > 0; i-- {
+			from := fmt.Sprintf("%s.%d", s.Path, i)
+			if _, err := os.Stat(from); err == nil {
+				if err := os.Rename(from, fmt.Sprintf("%s.%d", s.Path, i+1)); err != nil {
+					return fmt.Errorf("rotating audit file: %w", err)
+				}
+			}
+		}
+		if err := os.Rename(s.Path, s.Path+".1"); err != nil {
+			return fmt.Errorf("rotating audit file: %w", err)
+		}
+		s.renamed = true
+	}
+	if err := s.open(0); err != nil {
+		return fmt.Errorf("rotating audit file: %w", err)
+	}
+	s.renamed = false
+	return old.Close()
+}
+
+// WebhookSink posts every record as a JSON document to an HTTP endpoint.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookSink returns a sink posting to url, giving up on a record after timeout.
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+// Write implements Sink.
+func (s *WebhookSink) Write(ctx context.Context, line []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(line))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting audit record: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("posting audit record: %s", resp.Status)
+	}
+	return nil
+}
+
+// Close implements Sink.
+func (s *WebhookSink) Close() error {
+	s.Client.CloseIdleConnections()
+	return nil
+}
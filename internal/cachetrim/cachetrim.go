@@ -0,0 +1,71 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cachetrim reduces the memory held by the manager's informer cache
+// by dropping the fields of cached objects that the controllers never read.
+package cachetrim
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// LastAppliedAnnotation holds a full copy of objects managed with
+// kubectl apply, which is often larger than the rest of the object.
+const LastAppliedAnnotation = corev1.LastAppliedConfigAnnotation
+
+// Transform strips managedFields and the kubectl last-applied-configuration
+// annotation from every object before it is cached. A cached object must
+// therefore never be written back with an update, which would remove the
+// annotation on the server; the controllers write Namespaces and
+// NamespaceLabels with merge patches, which leave the dropped fields untouched.
+func Transform(obj interface{}) (interface{}, error) {
+	// Deletion tombstones carry no object metadata of their own.
+	if _, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		return obj, nil
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return obj, nil
+	}
+	// Only clear managedFields when set, see
+	// https://github.com/kubernetes/kubernetes/issues/124337.
+	if accessor.GetManagedFields() != nil {
+		accessor.SetManagedFields(nil)
+	}
+	if annotations := accessor.GetAnnotations(); annotations != nil {
+		if _, ok := annotations[LastAppliedAnnotation]; ok {
+			delete(annotations, LastAppliedAnnotation)
+			accessor.SetAnnotations(annotations)
+		}
+	}
+	return obj, nil
+}
+
+// Options returns opts with Transform applied to every cached object,
+// including those of ByObject entries that have no transform of their own.
+func Options(opts cache.Options) cache.Options {
+	opts.DefaultTransform = Transform
+	for obj, byObject := range opts.ByObject {
+		if byObject.Transform == nil {
+			byObject.Transform = Transform
+			opts.ByObject[obj] = byObject
+		}
+	}
+	return opts
+}
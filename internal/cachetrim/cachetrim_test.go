@@ -0,0 +1,132 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachetrim
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// namespace returns a Namespace as typically found in a multi-tenant cluster:
+// created with kubectl apply and labeled by several field managers.
+func namespace(i int) *corev1.Namespace {
+	name := fmt.Sprintf("tenant-%05d", i)
+	labels := map[string]string{
+		"kubernetes.io/metadata.name": name,
+		"team":                        fmt.Sprintf("team-%d", i%50),
+		"environment":                 "production",
+		"cost-center":                 fmt.Sprintf("cc-%d", i%20),
+	}
+	lastApplied := fmt.Sprintf(`{"apiVersion":"v1","kind":"Namespace","metadata":{"annotations":{},`+
+		`"labels":{"team":"team-%d","environment":"production","cost-center":"cc-%d"},"name":%q}}`, i%50, i%20, name)
+	fields := `{"f:metadata":{"f:annotations":{".":{},"f:kubectl.kubernetes.io/last-applied-configuration":{}},` +
+		`"f:labels":{".":{},"f:cost-center":{},"f:environment":{},"f:kubernetes.io/metadata.name":{},"f:team":{}}}}`
+	now := metav1.NewTime(time.Now())
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			UID:               "d3b07384-d9a0-4c2b-8f1e-6f1f0e6a1a2b",
+			ResourceVersion:   fmt.Sprint(1000 + i),
+			CreationTimestamp: now,
+			Labels:            labels,
+			Annotations:       map[string]string{LastAppliedAnnotation: lastApplied},
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kubectl-client-side-apply", Operation: metav1.ManagedFieldsOperationUpdate,
+					APIVersion: "v1", Time: &now, FieldsType: "FieldsV1", FieldsV1: &metav1.FieldsV1{Raw: []byte(fields)}},
+				{Manager: "namespacelabel", Operation: metav1.ManagedFieldsOperationUpdate,
+					APIVersion: "v1", Time: &now, FieldsType: "FieldsV1", FieldsV1: &metav1.FieldsV1{Raw: []byte(fields)}},
+			},
+		},
+		Spec:   corev1.NamespaceSpec{Finalizers: []corev1.FinalizerName{corev1.FinalizerKubernetes}},
+		Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	}
+}
+
+// metadataOf returns the metadata of ns as served to a metadata-only informer.
+func metadataOf(ns *corev1.Namespace) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "meta.k8s.io/v1", Kind: "PartialObjectMetadata"},
+		ObjectMeta: *ns.ObjectMeta.DeepCopy(),
+	}
+}
+
+func TestTransform(t *testing.T) {
+	obj, err := Transform(metadataOf(namespace(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns := obj.(*metav1.PartialObjectMetadata)
+	if ns.ManagedFields != nil {
+		t.Error("managedFields were kept")
+	}
+	if _, ok := ns.Annotations[LastAppliedAnnotation]; ok {
+		t.Error("the last-applied-configuration annotation was kept")
+	}
+	if ns.Labels["team"] != "team-1" {
+		t.Errorf("labels must be kept, got %v", ns.Labels)
+	}
+
+	tombstone := toolscache.DeletedFinalStateUnknown{Key: "tenant-00001"}
+	if obj, err := Transform(tombstone); err != nil || obj != tombstone {
+		t.Errorf("tombstones must pass through unchanged, got %v, %v", obj, err)
+	}
+}
+
+// BenchmarkNamespaceCache reports the heap retained by an informer store of
+// 10,000 Namespaces, cached as full objects and as trimmed metadata.
+func BenchmarkNamespaceCache(b *testing.B) {
+	const count = 10000
+	cases := map[string]func(*corev1.Namespace) interface{}{
+		"full": func(ns *corev1.Namespace) interface{} { return ns },
+		"metadata": func(ns *corev1.Namespace) interface{} {
+			obj, _ := Transform(metadataOf(ns))
+			return obj
+		},
+	}
+	for _, name := range []string{"full", "metadata"} {
+		convert := cases[name]
+		b.Run(name, func(b *testing.B) {
+			var retained uint64
+			for n := 0; n < b.N; n++ {
+				before := heapInUse()
+				store := toolscache.NewStore(toolscache.MetaNamespaceKeyFunc)
+				for i := 0; i < count; i++ {
+					if err := store.Add(convert(namespace(i))); err != nil {
+						b.Fatal(err)
+					}
+				}
+				retained += heapInUse() - before
+				runtime.KeepAlive(store)
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "heap-bytes/10k-namespaces")
+			b.ReportMetric(float64(retained)/float64(b.N*count), "heap-bytes/namespace")
+		})
+	}
+}
+
+func heapInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cleanup removes everything the operator leaves behind in a
+// cluster, so that it can be uninstalled cleanly: the labels it manages on
+// Namespaces, including those filled in for NamespaceLabelRequirements, with
+// their ownership annotations, and the finalizers of
+// NamespaceLabels, which would otherwise keep the CRD from being deleted.
+package cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/pkg/labelplan"
+)
+
+// Report describes what Run removed, or would remove in a dry run.
+type Report struct {
+	DryRun     bool              `json:"dryRun"`
+	Namespaces []NamespaceReport `json:"namespaces"`
+	// Finalized lists the NamespaceLabels, as namespace/name, whose finalizer
+	// was removed.
+	Finalized []string `json:"finalized"`
+}
+
+// NamespaceReport describes the cleanup of a single Namespace.
+type NamespaceReport struct {
+	Name string `json:"name"`
+	// Labels are the removed labels with their values.
+	Labels map[string]string `json:"labels"`
+	// Error is set when the ownership or immutable labels annotation could
+	// not be decoded. The annotations are removed, but the labels they list
+	// are left in place.
+	Error string `json:"error,omitempty"`
+}
+
+// Run removes every label the operator owns or locked from the Namespaces,
+// along with its annotations, then removes the finalizer of every
+// NamespaceLabel. Nothing is changed when dryRun is set. The manager should
+// be stopped first, since it would apply the labels again.
+func Run(ctx context.Context, c client.Client, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun, Namespaces: []NamespaceReport{}, Finalized: []string{}}
+
+	namespaces := &metav1.PartialObjectMetadataList{}
+	namespaces.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("NamespaceList"))
+	if err := c.List(ctx, namespaces); err != nil {
+		return report, fmt.Errorf("listing namespaces: %w", err)
+	}
+	for i := range namespaces.Items {
+		ns := &namespaces.Items[i]
+		_, managed := ns.Annotations[danateamv1.ManagedLabelsAnnotation]
+		_, hashed := ns.Annotations[danateamv1.DesiredStateAnnotation]
+		// Locked labels outlive their owners, so a Namespace may carry
+		// nothing else of the operator.
+		_, locked := ns.Annotations[danateamv1.ImmutableLabelsAnnotation]
+		if !managed && !hashed && !locked {
+			continue
+		}
+		// Items of metadata lists may lack their type; patches need it.
+		ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+		orig := ns.DeepCopy()
+		nsReport := strip(ns)
+		if !dryRun {
+			if err := c.Patch(ctx, ns, client.MergeFrom(orig)); err != nil {
+				return report, fmt.Errorf("removing labels from namespace %s: %w", ns.Name, err)
+			}
+		}
+		report.Namespaces = append(report.Namespaces, nsReport)
+	}
+
+	nls := &danateamv1.NamespaceLabelList{}
+	if err := c.List(ctx, nls); meta.IsNoMatchError(err) {
+		// The CRD is already gone, and with it every NamespaceLabel.
+		return report, nil
+	} else if err != nil {
+		return report, fmt.Errorf("listing NamespaceLabels: %w", err)
+	}
+	for i := range nls.Items {
+		nl := &nls.Items[i]
+		orig := nl.DeepCopy()
+		if !controllerutil.RemoveFinalizer(nl, danateamv1.Finalizer) {
+			continue
+		}
+		if !dryRun {
+			if err := c.Patch(ctx, nl, client.MergeFrom(orig)); client.IgnoreNotFound(err) != nil {
+				return report, fmt.Errorf("removing finalizer of NamespaceLabel %s/%s: %w", nl.Namespace, nl.Name, err)
+			}
+		}
+		report.Finalized = append(report.Finalized, nl.Namespace+"/"+nl.Name)
+	}
+	return report, nil
+}
+
+// strip removes the labels owned according to the ownership annotation of ns,
+// those locked by its immutable labels annotation, and the operator's
+// annotations.
+func strip(ns *metav1.PartialObjectMetadata) NamespaceReport {
+	report := NamespaceReport{Name: ns.Name, Labels: map[string]string{}}
+	owners := map[string]string{}
+	var problems []string
+	if value := ns.Annotations[danateamv1.ManagedLabelsAnnotation]; value != "" {
+		if err := json.Unmarshal([]byte(value), &owners); err != nil {
+			problems = append(problems, fmt.Sprintf("decoding %s: %v", danateamv1.ManagedLabelsAnnotation, err))
+		}
+	}
+	locked, err := labelplan.Locks(ns.Annotations)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	report.Error = strings.Join(problems, "; ")
+	for key := range locked {
+		owners[key] = ""
+	}
+	for key := range owners {
+		if value, ok := ns.Labels[key]; ok {
+			report.Labels[key] = value
+			delete(ns.Labels, key)
+		}
+	}
+	delete(ns.Annotations, danateamv1.ManagedLabelsAnnotation)
+	delete(ns.Annotations, danateamv1.DesiredStateAnnotation)
+	delete(ns.Annotations, danateamv1.ImmutableLabelsAnnotation)
+	return report
+}
+
+// WriteText writes a line for every Namespace and NamespaceLabel in the
+// report to w.
+func (r *Report) WriteText(w io.Writer) error {
+	verb := "removed"
+	if r.DryRun {
+		verb = "would remove"
+	}
+	var b strings.Builder
+	for _, ns := range r.Namespaces {
+		keys := make([]string, 0, len(ns.Labels))
+		for key := range ns.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		labels := make([]string, 0, len(keys))
+		for _, key := range keys {
+			labels = append(labels, key+"="+ns.Labels[key])
+		}
+		fmt.Fprintf(&b, "namespace/%s: %s labels [%s]", ns.Name, verb, strings.Join(labels, ", "))
+		if ns.Error != "" {
+			fmt.Fprintf(&b, " (%s)", ns.Error)
+		}
+		b.WriteString("\n")
+	}
+	for _, nl := range r.Finalized {
+		fmt.Fprintf(&b, "namespacelabel/%s: %s finalizer\n", nl, verb)
+	}
+	if len(r.Namespaces) == 0 && len(r.Finalized) == 0 {
+		b.WriteString("nothing to clean up\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
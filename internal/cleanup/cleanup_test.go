@@ -0,0 +1,174 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cleanup
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+)
+
+func newClient() client.Client {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(danateamv1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "team-a",
+			Labels: map[string]string{"team": "platform", "tier": "gold", "owner": "alice", "environment": "dev"},
+			Annotations: map[string]string{
+				danateamv1.ManagedLabelsAnnotation:   `{"team":"labels","tier":"labels","environment":"requirement/governance"}`,
+				danateamv1.DesiredStateAnnotation:    "0123",
+				danateamv1.ImmutableLabelsAnnotation: `{"tier":"gold"}`,
+				"note":                               "kept",
+			},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "unmanaged",
+			Labels: map[string]string{"team": "payments"},
+		}},
+		&danateamv1.NamespaceLabel{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-a", Name: "labels", Finalizers: []string{danateamv1.Finalizer},
+		}},
+	).Build()
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	c := newClient()
+
+	report, err := Run(ctx, c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Report{
+		Namespaces: []NamespaceReport{{Name: "team-a", Labels: map[string]string{
+			"team": "platform", "tier": "gold", "environment": "dev",
+		}}},
+		Finalized: []string{"team-a/labels"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Fatalf("report = %+v, want %+v", report, want)
+	}
+
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: "team-a"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ns.Labels, map[string]string{"owner": "alice"}) {
+		t.Errorf("labels = %v", ns.Labels)
+	}
+	if !reflect.DeepEqual(ns.Annotations, map[string]string{"note": "kept"}) {
+		t.Errorf("annotations = %v", ns.Annotations)
+	}
+	nl := &danateamv1.NamespaceLabel{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: "labels"}, nl); err != nil {
+		t.Fatal(err)
+	}
+	if len(nl.Finalizers) != 0 {
+		t.Errorf("finalizers = %v", nl.Finalizers)
+	}
+
+	report, err = Run(ctx, c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := report.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "nothing to clean up\n" {
+		t.Errorf("second run reported %q", out.String())
+	}
+}
+
+func TestRunRemovesLockedLabels(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(danateamv1.AddToScheme(scheme))
+	// The NamespaceLabel that locked environment is gone, and with it every
+	// other annotation of the operator.
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "team-a",
+			Labels:      map[string]string{"environment": "prod", "owner": "alice"},
+			Annotations: map[string]string{danateamv1.ImmutableLabelsAnnotation: `{"environment":"prod"}`},
+		}},
+	).Build()
+
+	report, err := Run(ctx, c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []NamespaceReport{{Name: "team-a", Labels: map[string]string{"environment": "prod"}}}
+	if !reflect.DeepEqual(report.Namespaces, want) {
+		t.Fatalf("namespaces = %+v, want %+v", report.Namespaces, want)
+	}
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: "team-a"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ns.Labels, map[string]string{"owner": "alice"}) || len(ns.Annotations) != 0 {
+		t.Errorf("labels = %v, annotations = %v", ns.Labels, ns.Annotations)
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	ctx := context.Background()
+	c := newClient()
+
+	report, err := Run(ctx, c, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := report.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := "namespace/team-a: would remove labels [environment=dev, team=platform, tier=gold]\n" +
+		"namespacelabel/team-a/labels: would remove finalizer\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: "team-a"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ns.Labels["team"]; !ok {
+		t.Errorf("dry run removed labels: %v", ns.Labels)
+	}
+	nl := &danateamv1.NamespaceLabel{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: "labels"}, nl); err != nil {
+		t.Fatal(err)
+	}
+	if len(nl.Finalizers) != 1 {
+		t.Errorf("dry run removed finalizers: %v", nl.Finalizers)
+	}
+}
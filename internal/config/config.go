@@ -0,0 +1,303 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config defines the versioned configuration file of the manager.
+//
+// Every setting can also be given as a command-line flag. Flags that are set
+// explicitly take precedence over the file, which takes precedence over the
+// defaults returned by Default.
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// APIVersion is the only configuration file version understood by this manager.
+	APIVersion = "config.danateam.namespacelabel.io/v1alpha1"
+	// Kind is the kind of the configuration file.
+	Kind = "ManagerConfig"
+)
+
+// ManagerConfig is the configuration of the controller manager.
+type ManagerConfig struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	Metrics        MetricsConfig        `json:"metrics"`
+	Health         HealthConfig         `json:"health"`
+	LeaderElection LeaderElectionConfig `json:"leaderElection"`
+	Webhook        WebhookConfig        `json:"webhook"`
+	Controller     ControllerConfig     `json:"controller"`
+	Scope          ScopeConfig          `json:"scope"`
+	Sharding       ShardingConfig       `json:"sharding"`
+	Policy         Policy               `json:"policy"`
+	// PolicyConfigMap names a ConfigMap whose PolicyConfigMapKey overrides
+	// Policy at runtime, without restarting the manager.
+	PolicyConfigMap ConfigMapReference `json:"policyConfigMap,omitempty"`
+	Audit           AuditConfig        `json:"audit"`
+	Tracing         TracingConfig      `json:"tracing"`
+	Inheritance     InheritanceConfig  `json:"inheritance"`
+
+	// EnableHTTP2 enables HTTP/2 for the metrics and webhook servers.
+	EnableHTTP2 bool `json:"enableHTTP2"`
+}
+
+// MetricsConfig configures the metrics server.
+type MetricsConfig struct {
+	// BindAddress is the address the metrics endpoint binds to, or 0 to disable it.
+	BindAddress string `json:"bindAddress"`
+	// Secure serves metrics over HTTPS with authentication and authorization.
+	Secure bool `json:"secure"`
+	// CertPath is the directory holding the serving certificate. A
+	// self-signed certificate is generated when empty.
+	CertPath string `json:"certPath,omitempty"`
+	CertName string `json:"certName"`
+	CertKey  string `json:"certKey"`
+}
+
+// HealthConfig configures the health probe server.
+type HealthConfig struct {
+	// ProbeBindAddress is the address the probe endpoint binds to.
+	ProbeBindAddress string `json:"probeBindAddress"`
+}
+
+// LeaderElectionConfig configures leader election between manager replicas.
+type LeaderElectionConfig struct {
+	Enabled bool `json:"enabled"`
+	// ID is the name of the Lease used for leader election.
+	ID string `json:"id"`
+	// Namespace holding the Lease. The manager's namespace is used when empty.
+	Namespace     string          `json:"namespace,omitempty"`
+	LeaseDuration metav1.Duration `json:"leaseDuration"`
+	RenewDeadline metav1.Duration `json:"renewDeadline"`
+	RetryPeriod   metav1.Duration `json:"retryPeriod"`
+}
+
+// WebhookConfig configures the webhook server.
+type WebhookConfig struct {
+	// Enabled serves the admission webhooks, which reject changes to
+	// immutable labels. The webhook configuration must be installed.
+	Enabled bool `json:"enabled"`
+	Port    int  `json:"port"`
+	// CertPath is the directory holding the serving certificate. The
+	// controller-runtime default directory is used when empty.
+	CertPath string `json:"certPath,omitempty"`
+	CertName string `json:"certName"`
+	CertKey  string `json:"certKey"`
+}
+
+// ControllerConfig configures the NamespaceLabel controller.
+type ControllerConfig struct {
+	// MaxConcurrentReconciles is the number of NamespaceLabels reconciled in parallel.
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles"`
+	// BaseBackoff and MaxBackoff bound the exponential delay before a failed
+	// reconcile of a NamespaceLabel is retried.
+	BaseBackoff metav1.Duration `json:"baseBackoff"`
+	MaxBackoff  metav1.Duration `json:"maxBackoff"`
+	// QPS and Burst limit how fast reconciles are started overall.
+	QPS   float64 `json:"qps"`
+	Burst int     `json:"burst"`
+	// WriteQPS and WriteBurst limit the writes of the manager to the API
+	// server, so a storm of label changes cannot overload it. Writes are not
+	// limited when WriteQPS is zero.
+	WriteQPS   float64 `json:"writeQPS"`
+	WriteBurst int     `json:"writeBurst"`
+}
+
+// ConfigMapReference names a ConfigMap. An empty namespace is the
+// namespace the manager runs in.
+type ConfigMapReference struct {
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ScopeConfig limits the namespaces the manager caches and reconciles. At
+// most one of its fields may be set; all namespaces are managed when neither is.
+type ScopeConfig struct {
+	// Namespaces is a static list of namespaces. Only NamespaceLabels in
+	// them are cached, so the manager can run with the namespaced RBAC of
+	// config/rbac-namespaced.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector is a label selector, such as managed-by=platform.
+	// Only matching Namespaces are cached and reconciled.
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+}
+
+// ShardingConfig spreads the namespaces of the cluster across several active
+// replicas. Each namespace is hashed into one of Shards shards, and a replica
+// only reconciles the NamespaceLabels of the shards it owns.
+type ShardingConfig struct {
+	// Shards is the number of shards. Sharding is disabled when it is 0,
+	// and requires leader election to be disabled otherwise.
+	Shards int `json:"shards,omitempty"`
+	// Index is the shard owned by this replica. When it is -1, replicas
+	// share the shards through Leases, and take over the shards of a
+	// replica that stops renewing them.
+	Index int `json:"index"`
+	// LeaseNamespace holds the shard Leases. The manager's namespace is used when empty.
+	LeaseNamespace string `json:"leaseNamespace,omitempty"`
+	// LeaseName prefixes the names of the shard Leases.
+	LeaseName     string          `json:"leaseName"`
+	LeaseDuration metav1.Duration `json:"leaseDuration"`
+	RenewPeriod   metav1.Duration `json:"renewPeriod"`
+	// LeaseSafetyMargin is how long before its Lease expires a replica that
+	// failed to renew it stops reconciling a shard, to allow for clock skew.
+	LeaseSafetyMargin metav1.Duration `json:"leaseSafetyMargin"`
+}
+
+// AuditConfig configures the audit trail of label mutations.
+type AuditConfig struct {
+	// Stdout writes audit records to standard output.
+	Stdout bool `json:"stdout"`
+	// File appends audit records to a file rotated at FileMaxSizeMB.
+	File           string `json:"file,omitempty"`
+	FileMaxSizeMB  int    `json:"fileMaxSizeMB"`
+	FileMaxBackups int    `json:"fileMaxBackups"`
+	// WebhookURL receives every audit record as an HTTP POST.
+	WebhookURL     string          `json:"webhookURL,omitempty"`
+	WebhookTimeout metav1.Duration `json:"webhookTimeout"`
+	// KeyFile holds the key audit records are authenticated with, usually
+	// mounted from a Secret. It is required when auditing is enabled.
+	KeyFile string `json:"keyFile,omitempty"`
+	// QueueSize is the number of records waiting to be written to the sinks
+	// before further records are dropped.
+	QueueSize int `json:"queueSize"`
+}
+
+// Enabled reports whether audit records are written anywhere.
+func (c AuditConfig) Enabled() bool {
+	return c.Stdout || c.File != "" || c.WebhookURL != ""
+}
+
+// TracingConfig configures OpenTelemetry tracing.
+type TracingConfig struct {
+	// OTLPEndpoint is the host:port of an OTLP gRPC collector. Tracing is disabled when empty.
+	OTLPEndpoint string  `json:"otlpEndpoint,omitempty"`
+	Insecure     bool    `json:"insecure"`
+	SampleRatio  float64 `json:"sampleRatio"`
+}
+
+// DefaultParentKey is the default label, or annotation, naming the parent of
+// a namespace.
+const DefaultParentKey = "danateam.namespacelabel.io/parent"
+
+// InheritanceConfig makes namespaces inherit labels from their parent
+// namespace, and from its ancestors in turn. Labels requested by the
+// NamespaceLabels of a namespace take precedence over inherited ones.
+type InheritanceConfig struct {
+	// Labels are the label keys namespaces inherit. Inheritance is disabled
+	// when empty.
+	Labels []string `json:"labels,omitempty"`
+	// ParentKey is the label, or else the annotation, of a namespace that
+	// names its parent.
+	ParentKey string `json:"parentKey"`
+	// MaxDepth is the number of ancestors walked to find an inherited label.
+	MaxDepth int `json:"maxDepth"`
+}
+
+// Enabled reports whether any label is inherited.
+func (c InheritanceConfig) Enabled() bool {
+	return len(c.Labels) > 0
+}
+
+// Default returns the configuration used for settings that are neither in
+// the configuration file nor set by a flag.
+func Default() *ManagerConfig {
+	return &ManagerConfig{
+		APIVersion: APIVersion,
+		Kind:       Kind,
+		Metrics: MetricsConfig{
+			BindAddress: "0",
+			Secure:      true,
+			CertName:    "tls.crt",
+			CertKey:     "tls.key",
+		},
+		Health: HealthConfig{ProbeBindAddress: ":8081"},
+		LeaderElection: LeaderElectionConfig{
+			ID:            "4d82f92d.namespacelabel.io",
+			LeaseDuration: metav1.Duration{Duration: 15 * time.Second},
+			RenewDeadline: metav1.Duration{Duration: 10 * time.Second},
+			RetryPeriod:   metav1.Duration{Duration: 2 * time.Second},
+		},
+		Webhook: WebhookConfig{
+			Port:     9443,
+			CertName: "tls.crt",
+			CertKey:  "tls.key",
+		},
+		// The backoff and reconcile rate defaults are those of controller-runtime.
+		Controller: ControllerConfig{
+			MaxConcurrentReconciles: 1,
+			BaseBackoff:             metav1.Duration{Duration: 5 * time.Millisecond},
+			MaxBackoff:              metav1.Duration{Duration: 1000 * time.Second},
+			QPS:                     10,
+			Burst:                   100,
+			WriteQPS:                20,
+			WriteBurst:              50,
+		},
+		Sharding: ShardingConfig{
+			Index:             -1,
+			LeaseName:         "namespacelabel-shard",
+			LeaseDuration:     metav1.Duration{Duration: 15 * time.Second},
+			RenewPeriod:       metav1.Duration{Duration: 5 * time.Second},
+			LeaseSafetyMargin: metav1.Duration{Duration: 3 * time.Second},
+		},
+		Policy: Policy{
+			ProtectedPrefixes: append([]string(nil), DefaultProtectedPrefixes...),
+		},
+		Audit: AuditConfig{
+			FileMaxSizeMB:  100,
+			FileMaxBackups: 5,
+			WebhookTimeout: metav1.Duration{Duration: 5 * time.Second},
+			QueueSize:      1024,
+		},
+		Tracing:     TracingConfig{SampleRatio: 1},
+		Inheritance: InheritanceConfig{ParentKey: DefaultParentKey, MaxDepth: 10},
+	}
+}
+
+// Decode strictly decodes a configuration file on top of the defaults.
+// Unknown or duplicate fields and an unexpected apiVersion or kind are errors.
+func Decode(data []byte) (*ManagerConfig, error) {
+	cfg := Default()
+	cfg.APIVersion, cfg.Kind = "", ""
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.APIVersion != APIVersion || cfg.Kind != Kind {
+		return nil, fmt.Errorf("expected apiVersion %q and kind %q, got %q and %q",
+			APIVersion, Kind, cfg.APIVersion, cfg.Kind)
+	}
+	return cfg, nil
+}
+
+// LoadFile reads and decodes the configuration file at path.
+func LoadFile(path string) (*ManagerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sample = `
+apiVersion: config.danateam.namespacelabel.io/v1alpha1
+kind: ManagerConfig
+health:
+  probeBindAddress: ":9091"
+leaderElection:
+  enabled: true
+  leaseDuration: 30s
+controller:
+  maxConcurrentReconciles: 4
+policy:
+  protectedPrefixes: ["kubernetes.io/"]
+  excludedNamespaces: ["kube-system"]
+`
+
+func TestDecode(t *testing.T) {
+	cfg, err := Decode([]byte(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Health.ProbeBindAddress != ":9091" || !cfg.LeaderElection.Enabled ||
+		cfg.LeaderElection.LeaseDuration.Duration != 30*time.Second || cfg.Controller.MaxConcurrentReconciles != 4 {
+		t.Fatalf("file settings not applied: %+v", cfg)
+	}
+	if cfg.LeaderElection.RenewDeadline.Duration != 10*time.Second || cfg.Webhook.Port != 9443 {
+		t.Fatalf("defaults not kept for settings missing from the file: %+v", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown field": sample + "controler:\n  maxConcurrentReconciles: 2\n",
+		"wrong kind":    strings.Replace(sample, "kind: ManagerConfig", "kind: Config", 1),
+		"wrong version": strings.Replace(sample, "v1alpha1", "v1", 1),
+		"wrong type":    strings.Replace(sample, "maxConcurrentReconciles: 4", "maxConcurrentReconciles: four", 1),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Decode([]byte(data)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestLoaderFlagsOverrideFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(sample), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := NewLoader(fs)
+	err := fs.Parse([]string{
+		"--max-concurrent-reconciles=8",
+		"--config=" + path,
+		"--excluded-namespaces=kube-public, default",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Controller.MaxConcurrentReconciles != 8 {
+		t.Errorf("flag should override the file, got %d reconciles", cfg.Controller.MaxConcurrentReconciles)
+	}
+	if want := []string{"kube-public", "default"}; !reflect.DeepEqual(cfg.Policy.ExcludedNamespaces, want) {
+		t.Errorf("excluded namespaces = %v, want %v", cfg.Policy.ExcludedNamespaces, want)
+	}
+	if cfg.Health.ProbeBindAddress != ":9091" {
+		t.Errorf("unset flag should not override the file, got %q", cfg.Health.ProbeBindAddress)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]func(*ManagerConfig){
+		"no reconcilers":     func(c *ManagerConfig) { c.Controller.MaxConcurrentReconciles = 0 },
+		"lease before renew": func(c *ManagerConfig) { c.LeaderElection.LeaseDuration.Duration = time.Second },
+		"webhook port":       func(c *ManagerConfig) { c.Webhook.Port = 70000 },
+		"backoff bounds":     func(c *ManagerConfig) { c.Controller.MaxBackoff.Duration = time.Millisecond },
+		"write burst":        func(c *ManagerConfig) { c.Controller.WriteBurst = 0 },
+		"sample ratio":       func(c *ManagerConfig) { c.Tracing.SampleRatio = 2 },
+		"scope list and selector": func(c *ManagerConfig) {
+			c.Scope = ScopeConfig{Namespaces: []string{"team-a"}, NamespaceSelector: "managed-by=platform"}
+		},
+		"scope selector": func(c *ManagerConfig) { c.Scope.NamespaceSelector = "managed-by in (" },
+		"sharding with leader election": func(c *ManagerConfig) {
+			c.Sharding.Shards = 4
+			c.LeaderElection.Enabled = true
+		},
+		"shard index":       func(c *ManagerConfig) { c.Sharding.Shards, c.Sharding.Index = 4, 4 },
+		"empty prefix":      func(c *ManagerConfig) { c.Policy.ProtectedPrefixes = []string{""} },
+		"invalid label":     func(c *ManagerConfig) { c.Policy.ProtectedLabels = []string{"not a key"} },
+		"invalid namespace": func(c *ManagerConfig) { c.Policy.ExcludedNamespaces = []string{"Kube_System"} },
+		"audit webhook scheme": func(c *ManagerConfig) {
+			c.Audit.WebhookURL, c.Audit.KeyFile = "ftp://audit.example.com", "/etc/audit/key"
+		},
+		"audit without key": func(c *ManagerConfig) { c.Audit.Stdout = true },
+		"audit queue size": func(c *ManagerConfig) {
+			c.Audit.Stdout, c.Audit.KeyFile, c.Audit.QueueSize = true, "/etc/audit/key", 0
+		},
+		"inherited parent key": func(c *ManagerConfig) { c.Inheritance.Labels = []string{DefaultParentKey} },
+		"inheritance depth":    func(c *ManagerConfig) { c.Inheritance.MaxDepth = 0 },
+		"inheritance with scope list": func(c *ManagerConfig) {
+			c.Inheritance.Labels = []string{"team"}
+			c.Scope.Namespaces = []string{"team-a"}
+		},
+	}
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := Default()
+			mutate(cfg)
+			if err := cfg.Validate(); err == nil {
+				t.Fatal("expected a validation error")
+			}
+		})
+	}
+}
+
+func TestPolicyDenies(t *testing.T) {
+	p := Policy{
+		ProtectedPrefixes:   DefaultProtectedPrefixes,
+		ProtectedLabels:     []string{"owner"},
+		ProtectedNamespaces: []string{"kube-system"},
+	}
+	tests := []struct {
+		namespace, key, reason string
+	}{
+		{"team-a", "team", ""},
+		{"team-a", "kubernetes.io/metadata.name", DenialProtectedPrefix},
+		{"team-a", "owner", DenialProtectedLabel},
+		{"kube-system", "team", DenialProtectedNamespace},
+	}
+	for _, tt := range tests {
+		reason, denied := p.Denies(tt.namespace, tt.key)
+		if reason != tt.reason || denied != (tt.reason != "") {
+			t.Errorf("Denies(%q, %q) = %q, %v; want %q", tt.namespace, tt.key, reason, denied, tt.reason)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"flag"
+	"strings"
+	"time"
+)
+
+// Loader builds a ManagerConfig from the file named by --config and from
+// command-line flags.
+type Loader struct {
+	fs    *flag.FlagSet
+	cfg   *ManagerConfig
+	file  string
+	flags map[string]bool
+}
+
+// NewLoader registers --config and a flag for every setting on fs.
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{fs: fs, cfg: Default(), flags: map[string]bool{}}
+	fs.StringVar(&l.file, "config", "",
+		"The path of a "+Kind+" file. Flags that are set explicitly take precedence over the file.")
+
+	c := l.cfg
+	l.stringVar(&c.Metrics.BindAddress, "metrics-bind-address", "The address the metrics endpoint binds to. "+
+		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
+	l.boolVar(&c.Metrics.Secure, "metrics-secure",
+		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
+	l.stringVar(&c.Metrics.CertPath, "metrics-cert-path", "The directory that contains the metrics server certificate.")
+	l.stringVar(&c.Metrics.CertName, "metrics-cert-name", "The name of the metrics server certificate file.")
+	l.stringVar(&c.Metrics.CertKey, "metrics-cert-key", "The name of the metrics server key file.")
+	l.stringVar(&c.Health.ProbeBindAddress, "health-probe-bind-address", "The address the probe endpoint binds to.")
+	l.boolVar(&c.LeaderElection.Enabled, "leader-elect",
+		"Enable leader election for controller manager. "+
+			"Enabling this will ensure there is only one active controller manager.")
+	l.stringVar(&c.LeaderElection.ID, "leader-election-id", "The name of the Lease used for leader election.")
+	l.stringVar(&c.LeaderElection.Namespace, "leader-election-namespace",
+		"The namespace of the leader election Lease. Defaults to the manager's namespace.")
+	l.durationVar(&c.LeaderElection.LeaseDuration.Duration, "leader-election-lease-duration",
+		"The duration non-leader candidates wait before forcing to acquire leadership.")
+	l.durationVar(&c.LeaderElection.RenewDeadline.Duration, "leader-election-renew-deadline",
+		"The duration the leader retries refreshing leadership before giving up.")
+	l.durationVar(&c.LeaderElection.RetryPeriod.Duration, "leader-election-retry-period",
+		"The duration leader election clients wait between actions.")
+	l.boolVar(&c.Webhook.Enabled, "enable-webhooks", "If set, the admission webhooks are served.")
+	l.intVar(&c.Webhook.Port, "webhook-port", "The port the webhook server listens on.")
+	l.stringVar(&c.Webhook.CertPath, "webhook-cert-path", "The directory that contains the webhook certificate.")
+	l.stringVar(&c.Webhook.CertName, "webhook-cert-name", "The name of the webhook certificate file.")
+	l.stringVar(&c.Webhook.CertKey, "webhook-cert-key", "The name of the webhook key file.")
+	l.boolVar(&c.EnableHTTP2, "enable-http2", "If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	l.intVar(&c.Controller.MaxConcurrentReconciles, "max-concurrent-reconciles",
+		"The number of NamespaceLabels reconciled in parallel.")
+	l.durationVar(&c.Controller.BaseBackoff.Duration, "reconcile-base-backoff",
+		"The delay before the first retry of a failed reconcile. It doubles with every further failure.")
+	l.durationVar(&c.Controller.MaxBackoff.Duration, "reconcile-max-backoff",
+		"The maximum delay before retrying a failed reconcile.")
+	l.float64Var(&c.Controller.QPS, "reconcile-qps", "The number of reconciles started per second overall.")
+	l.intVar(&c.Controller.Burst, "reconcile-burst", "The number of reconciles that may start at once above --reconcile-qps.")
+	l.float64Var(&c.Controller.WriteQPS, "write-qps",
+		"The number of writes per second the manager sends to the API server. Zero disables the limit.")
+	l.intVar(&c.Controller.WriteBurst, "write-burst", "The number of writes that may be sent at once above --write-qps.")
+	l.stringSliceVar(&c.Policy.ProtectedPrefixes, "protected-prefixes",
+		"Comma-separated label key prefixes the operator never writes.")
+	l.stringSliceVar(&c.Policy.ProtectedLabels, "protected-labels",
+		"Comma-separated label keys the operator never writes.")
+	l.stringSliceVar(&c.Policy.ProtectedNamespaces, "protected-namespaces",
+		"Comma-separated namespaces whose labels the operator never changes. "+
+			"NamespaceLabels in them report a policy denial.")
+	l.stringSliceVar(&c.Policy.ExcludedNamespaces, "excluded-namespaces",
+		"Comma-separated namespaces whose NamespaceLabels are ignored.")
+	l.boolVar(&c.Policy.AdoptExisting, "adopt-existing-labels",
+		"If set, labels set outside the operator are only taken over when they already have the requested value.")
+	l.stringSliceVar(&c.Scope.Namespaces, "watch-namespaces",
+		"Comma-separated namespaces to manage. All namespaces are managed when empty.")
+	l.stringVar(&c.Scope.NamespaceSelector, "namespace-selector",
+		"A label selector limiting the namespaces to manage, such as managed-by=platform.")
+	l.intVar(&c.Sharding.Shards, "shards",
+		"The number of shards namespaces are spread across. Sharding is disabled when 0.")
+	l.intVar(&c.Sharding.Index, "shard-index",
+		"The shard reconciled by this replica, or -1 to share the shards between replicas through Leases.")
+	l.stringVar(&c.Sharding.LeaseNamespace, "shard-lease-namespace",
+		"The namespace of the shard Leases. Defaults to the manager's namespace.")
+	l.stringVar(&c.Sharding.LeaseName, "shard-lease-name", "The prefix of the names of the shard Leases.")
+	l.durationVar(&c.Sharding.LeaseDuration.Duration, "shard-lease-duration",
+		"The duration after which the shards of a replica that stopped renewing its Leases are taken over.")
+	l.durationVar(&c.Sharding.RenewPeriod.Duration, "shard-renew-period",
+		"The interval at which shard Leases are renewed and shards rebalanced.")
+	l.durationVar(&c.Sharding.LeaseSafetyMargin.Duration, "shard-lease-safety-margin",
+		"How long before its Lease expires a replica that failed to renew it stops reconciling a shard.")
+	l.stringVar(&c.PolicyConfigMap.Name, "policy-configmap",
+		"The name of a ConfigMap whose "+PolicyConfigMapKey+" key overrides the policy at runtime.")
+	l.stringVar(&c.PolicyConfigMap.Namespace, "policy-configmap-namespace",
+		"The namespace of --policy-configmap. Defaults to the manager's namespace.")
+	l.boolVar(&c.Audit.Stdout, "audit-stdout",
+		"If set, an audit record of every namespace label mutation is written to stdout as a JSON line.")
+	l.stringVar(&c.Audit.File, "audit-file",
+		"If set, audit records are appended to this file, which is rotated according to --audit-file-max-size.")
+	l.intVar(&c.Audit.FileMaxSizeMB, "audit-file-max-size", "The size in megabytes at which the audit file is rotated.")
+	l.intVar(&c.Audit.FileMaxBackups, "audit-file-max-backups", "The number of rotated audit files to keep.")
+	l.stringVar(&c.Audit.WebhookURL, "audit-webhook-url",
+		"If set, every audit record is posted as JSON to this HTTP endpoint.")
+	l.durationVar(&c.Audit.WebhookTimeout.Duration, "audit-webhook-timeout",
+		"The timeout for posting an audit record to --audit-webhook-url.")
+	l.stringVar(&c.Audit.KeyFile, "audit-key-file",
+		"The file holding the key audit records are authenticated with. Required when auditing is enabled.")
+	l.intVar(&c.Audit.QueueSize, "audit-queue-size",
+		"The number of audit records waiting to be written before further records are dropped.")
+	l.stringVar(&c.Tracing.OTLPEndpoint, "otlp-endpoint",
+		"The host:port of an OTLP gRPC collector to export traces to. Tracing is disabled when empty.")
+	l.boolVar(&c.Tracing.Insecure, "otlp-insecure", "If set, traces are exported to the collector without TLS.")
+	l.float64Var(&c.Tracing.SampleRatio, "trace-sample-ratio",
+		"The fraction of reconciles that are traced. Admission requests with a sampled parent are always traced.")
+	l.stringSliceVar(&c.Inheritance.Labels, "inherit-labels",
+		"Comma-separated label keys namespaces inherit from their parent namespaces. Inheritance is disabled when empty.")
+	l.stringVar(&c.Inheritance.ParentKey, "inheritance-parent-key",
+		"The label, or else annotation, of a namespace that names its parent.")
+	l.intVar(&c.Inheritance.MaxDepth, "inheritance-max-depth",
+		"The number of ancestors walked to find an inherited label.")
+	return l
+}
+
+// Load returns the effective configuration. It must be called after the
+// flag set is parsed. The result is not validated.
+func (l *Loader) Load() (*ManagerConfig, error) {
+	if l.file == "" {
+		return l.cfg, nil
+	}
+
+	set := map[string]string{}
+	l.fs.Visit(func(f *flag.Flag) {
+		if l.flags[f.Name] {
+			set[f.Name] = f.Value.String()
+		}
+	})
+
+	fromFile, err := LoadFile(l.file)
+	if err != nil {
+		return nil, err
+	}
+	// The flags point into l.cfg, so re-applying them overrides the file.
+	*l.cfg = *fromFile
+	for name, value := range set {
+		if err := l.fs.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	return l.cfg, nil
+}
+
+func (l *Loader) stringVar(p *string, name, usage string) {
+	l.flags[name] = true
+	l.fs.StringVar(p, name, *p, usage)
+}
+
+func (l *Loader) boolVar(p *bool, name, usage string) {
+	l.flags[name] = true
+	l.fs.BoolVar(p, name, *p, usage)
+}
+
+func (l *Loader) intVar(p *int, name, usage string) {
+	l.flags[name] = true
+	l.fs.IntVar(p, name, *p, usage)
+}
+
+func (l *Loader) float64Var(p *float64, name, usage string) {
+	l.flags[name] = true
+	l.fs.Float64Var(p, name, *p, usage)
+}
+
+func (l *Loader) durationVar(p *time.Duration, name, usage string) {
+	l.flags[name] = true
+	l.fs.DurationVar(p, name, *p, usage)
+}
+
+func (l *Loader) stringSliceVar(p *[]string, name, usage string) {
+	l.flags[name] = true
+	l.fs.Var((*stringSlice)(p), name, usage)
+}
+
+// stringSlice is a flag.Value for a comma-separated list that replaces,
+// rather than appends to, its default.
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*s = append(*s, item)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultProtectedPrefixes are the label key prefixes reserved for Kubernetes
+// itself.
+var DefaultProtectedPrefixes = []string{"kubernetes.io/", "k8s.io/"}
+
+// Reasons a label is denied by a Policy. They are used as the reason label of
+// the policy denial metric.
+const (
+	DenialProtectedPrefix    = "protected_prefix"
+	DenialProtectedLabel     = "protected_label"
+	DenialProtectedNamespace = "protected_namespace"
+)
+
+// Policy restricts which namespaces and labels the controller manages.
+type Policy struct {
+	// ProtectedPrefixes are label key prefixes that are never written.
+	ProtectedPrefixes []string `json:"protectedPrefixes"`
+	// ProtectedLabels are label keys that are never written.
+	ProtectedLabels []string `json:"protectedLabels,omitempty"`
+	// ProtectedNamespaces are namespaces whose labels are never changed.
+	// NamespaceLabels in them report every label as denied.
+	ProtectedNamespaces []string `json:"protectedNamespaces,omitempty"`
+	// ExcludedNamespaces are namespaces whose NamespaceLabels are ignored.
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+	// AdoptExisting only lets NamespaceLabels take over labels set outside
+	// the operator that already have the requested value, without rewriting
+	// them. Labels with another value are left alone and reported as
+	// conflicts, instead of being overwritten.
+	AdoptExisting bool `json:"adoptExisting,omitempty"`
+}
+
+// Excludes reports whether NamespaceLabels in namespace are ignored.
+func (p Policy) Excludes(namespace string) bool {
+	return contains(p.ExcludedNamespaces, namespace)
+}
+
+// Denies reports whether the label key may not be written to namespace, and
+// why.
+func (p Policy) Denies(namespace, key string) (string, bool) {
+	if contains(p.ProtectedNamespaces, namespace) {
+		return DenialProtectedNamespace, true
+	}
+	if contains(p.ProtectedLabels, key) {
+		return DenialProtectedLabel, true
+	}
+	for _, prefix := range p.ProtectedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return DenialProtectedPrefix, true
+		}
+	}
+	return "", false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyConfigMapKey is the key of the policy in the ConfigMap named by
+// ManagerConfig.PolicyConfigMap.
+const PolicyConfigMapKey = "policy.yaml"
+
+// DecodePolicy strictly decodes a policy on top of base. Lists that are set
+// replace the lists of base; omitted ones keep them.
+func DecodePolicy(data []byte, base Policy) (Policy, error) {
+	p := base.DeepCopy()
+	if err := yaml.UnmarshalStrict(data, &p); err != nil {
+		return Policy{}, err
+	}
+	return p, nil
+}
+
+// Validate returns every invalid setting of p, or nil.
+func (p Policy) Validate() error {
+	return p.validate(field.NewPath("policy")).ToAggregate()
+}
+
+// DeepCopy returns a copy of p that shares no slices with it.
+func (p Policy) DeepCopy() Policy {
+	return Policy{
+		ProtectedPrefixes:   append([]string(nil), p.ProtectedPrefixes...),
+		ProtectedLabels:     append([]string(nil), p.ProtectedLabels...),
+		ProtectedNamespaces: append([]string(nil), p.ProtectedNamespaces...),
+		ExcludedNamespaces:  append([]string(nil), p.ExcludedNamespaces...),
+		AdoptExisting:       p.AdoptExisting,
+	}
+}
+
+// Affected returns the namespaces in which switching from old to p can change
+// the outcome of a reconcile. all is set when every namespace is affected.
+func (p Policy) Affected(old Policy) (all bool, namespaces []string) {
+	if !sets.New(p.ProtectedPrefixes...).Equal(sets.New(old.ProtectedPrefixes...)) ||
+		!sets.New(p.ProtectedLabels...).Equal(sets.New(old.ProtectedLabels...)) ||
+		p.AdoptExisting != old.AdoptExisting {
+		return true, nil
+	}
+	changed := sets.New(p.ProtectedNamespaces...).SymmetricDifference(sets.New(old.ProtectedNamespaces...))
+	changed = changed.Union(sets.New(p.ExcludedNamespaces...).SymmetricDifference(sets.New(old.ExcludedNamespaces...)))
+	return false, sets.List(changed)
+}
+
+// PolicyStore holds the Policy in effect, which may be replaced at runtime.
+// A nil store holds the default policy.
+type PolicyStore struct {
+	mu       sync.RWMutex
+	policy   Policy
+	revision string
+}
+
+// NewPolicyStore returns a store holding p.
+func NewPolicyStore(p Policy) *PolicyStore {
+	return &PolicyStore{policy: p}
+}
+
+// Load returns the policy in effect and the revision it was loaded from,
+// which is empty for the policy of the configuration file.
+func (s *PolicyStore) Load() (Policy, string) {
+	if s == nil {
+		return Default().Policy, ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.policy, s.revision
+}
+
+// Store puts p into effect and returns the policy it replaces.
+func (s *PolicyStore) Store(p Policy, revision string) Policy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.policy
+	s.policy, s.revision = p, revision
+	return old
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"net/url"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Validate returns every invalid setting of c, or nil.
+func (c *ManagerConfig) Validate() error {
+	var errs field.ErrorList
+
+	le := field.NewPath("leaderElection")
+	if c.LeaderElection.Enabled && c.LeaderElection.ID == "" {
+		errs = append(errs, field.Required(le.Child("id"), "required when leader election is enabled"))
+	}
+	if c.LeaderElection.RetryPeriod.Duration <= 0 {
+		errs = append(errs, field.Invalid(le.Child("retryPeriod"), c.LeaderElection.RetryPeriod.Duration,
+			"must be positive"))
+	}
+	if c.LeaderElection.RenewDeadline.Duration <= c.LeaderElection.RetryPeriod.Duration {
+		errs = append(errs, field.Invalid(le.Child("renewDeadline"), c.LeaderElection.RenewDeadline.Duration,
+			"must be greater than retryPeriod"))
+	}
+	if c.LeaderElection.LeaseDuration.Duration <= c.LeaderElection.RenewDeadline.Duration {
+		errs = append(errs, field.Invalid(le.Child("leaseDuration"), c.LeaderElection.LeaseDuration.Duration,
+			"must be greater than renewDeadline"))
+	}
+
+	if c.Webhook.Port < 1 || c.Webhook.Port > 65535 {
+		errs = append(errs, field.Invalid(field.NewPath("webhook", "port"), c.Webhook.Port,
+			"must be between 1 and 65535"))
+	}
+
+	ctl := field.NewPath("controller")
+	if c.Controller.MaxConcurrentReconciles < 1 {
+		errs = append(errs, field.Invalid(ctl.Child("maxConcurrentReconciles"),
+			c.Controller.MaxConcurrentReconciles, "must be at least 1"))
+	}
+	if c.Controller.BaseBackoff.Duration <= 0 {
+		errs = append(errs, field.Invalid(ctl.Child("baseBackoff"), c.Controller.BaseBackoff.Duration,
+			"must be positive"))
+	}
+	if c.Controller.MaxBackoff.Duration < c.Controller.BaseBackoff.Duration {
+		errs = append(errs, field.Invalid(ctl.Child("maxBackoff"), c.Controller.MaxBackoff.Duration,
+			"must not be less than baseBackoff"))
+	}
+	if c.Controller.QPS <= 0 {
+		errs = append(errs, field.Invalid(ctl.Child("qps"), c.Controller.QPS, "must be positive"))
+	}
+	if c.Controller.Burst < 1 {
+		errs = append(errs, field.Invalid(ctl.Child("burst"), c.Controller.Burst, "must be at least 1"))
+	}
+	if c.Controller.WriteQPS < 0 {
+		errs = append(errs, field.Invalid(ctl.Child("writeQPS"), c.Controller.WriteQPS, "must not be negative"))
+	}
+	if c.Controller.WriteQPS > 0 && c.Controller.WriteBurst < 1 {
+		errs = append(errs, field.Invalid(ctl.Child("writeBurst"), c.Controller.WriteBurst, "must be at least 1"))
+	}
+
+	scope := field.NewPath("scope")
+	if len(c.Scope.Namespaces) > 0 && c.Scope.NamespaceSelector != "" {
+		errs = append(errs, field.Forbidden(scope.Child("namespaceSelector"),
+			"may not be set together with scope.namespaces"))
+	}
+	for i, ns := range c.Scope.Namespaces {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			errs = append(errs, field.Invalid(scope.Child("namespaces").Index(i), ns, msg))
+		}
+	}
+	if c.Scope.NamespaceSelector != "" {
+		if _, err := labels.Parse(c.Scope.NamespaceSelector); err != nil {
+			errs = append(errs, field.Invalid(scope.Child("namespaceSelector"), c.Scope.NamespaceSelector, err.Error()))
+		}
+	}
+
+	errs = append(errs, c.Sharding.validate(field.NewPath("sharding"), c.LeaderElection.Enabled)...)
+
+	errs = append(errs, c.Policy.validate(field.NewPath("policy"))...)
+	if c.PolicyConfigMap.Name != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(c.PolicyConfigMap.Name) {
+			errs = append(errs, field.Invalid(field.NewPath("policyConfigMap", "name"), c.PolicyConfigMap.Name, msg))
+		}
+	}
+
+	audit := field.NewPath("audit")
+	if c.Audit.File != "" {
+		if c.Audit.FileMaxSizeMB < 1 {
+			errs = append(errs, field.Invalid(audit.Child("fileMaxSizeMB"), c.Audit.FileMaxSizeMB,
+				"must be at least 1"))
+		}
+		if c.Audit.FileMaxBackups < 0 {
+			errs = append(errs, field.Invalid(audit.Child("fileMaxBackups"), c.Audit.FileMaxBackups,
+				"must not be negative"))
+		}
+	}
+	if c.Audit.WebhookURL != "" {
+		if u, err := url.Parse(c.Audit.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, field.Invalid(audit.Child("webhookURL"), c.Audit.WebhookURL,
+				"must be an http or https URL"))
+		}
+		if c.Audit.WebhookTimeout.Duration <= 0 {
+			errs = append(errs, field.Invalid(audit.Child("webhookTimeout"), c.Audit.WebhookTimeout.Duration,
+				"must be positive"))
+		}
+	}
+	if c.Audit.Enabled() {
+		if c.Audit.KeyFile == "" {
+			errs = append(errs, field.Required(audit.Child("keyFile"), "required when auditing is enabled"))
+		}
+		if c.Audit.QueueSize < 1 {
+			errs = append(errs, field.Invalid(audit.Child("queueSize"), c.Audit.QueueSize, "must be at least 1"))
+		}
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, field.Invalid(field.NewPath("tracing", "sampleRatio"), c.Tracing.SampleRatio,
+			"must be between 0 and 1"))
+	}
+
+	errs = append(errs, c.Inheritance.validate(field.NewPath("inheritance"), len(c.Scope.Namespaces) > 0)...)
+
+	return errs.ToAggregate()
+}
+
+func (s ShardingConfig) validate(path *field.Path, leaderElection bool) field.ErrorList {
+	var errs field.ErrorList
+	if s.Shards < 0 {
+		errs = append(errs, field.Invalid(path.Child("shards"), s.Shards, "must not be negative"))
+	}
+	if s.Shards == 0 {
+		return errs
+	}
+	if leaderElection {
+		errs = append(errs, field.Forbidden(path.Child("shards"),
+			"sharded replicas run concurrently and may not use leader election"))
+	}
+	if s.Index < -1 || s.Index >= s.Shards {
+		errs = append(errs, field.Invalid(path.Child("index"), s.Index, "must be -1 or less than shards"))
+	}
+	if s.Index != -1 {
+		return errs
+	}
+	for _, msg := range validation.IsDNS1123Label(s.LeaseName) {
+		errs = append(errs, field.Invalid(path.Child("leaseName"), s.LeaseName, msg))
+	}
+	if s.RenewPeriod.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("renewPeriod"), s.RenewPeriod.Duration, "must be positive"))
+	}
+	if s.LeaseDuration.Duration <= s.RenewPeriod.Duration {
+		errs = append(errs, field.Invalid(path.Child("leaseDuration"), s.LeaseDuration.Duration,
+			"must be greater than renewPeriod"))
+	}
+	if margin := s.LeaseSafetyMargin.Duration; margin < 0 || margin >= s.LeaseDuration.Duration-s.RenewPeriod.Duration {
+		errs = append(errs, field.Invalid(path.Child("leaseSafetyMargin"), margin,
+			"must not be negative and must be less than leaseDuration minus renewPeriod"))
+	}
+	return errs
+}
+
+func (p Policy) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, prefix := range p.ProtectedPrefixes {
+		if prefix == "" {
+			errs = append(errs, field.Invalid(path.Child("protectedPrefixes").Index(i), prefix, "must not be empty"))
+		}
+	}
+	for i, key := range p.ProtectedLabels {
+		for _, msg := range validation.IsQualifiedName(key) {
+			errs = append(errs, field.Invalid(path.Child("protectedLabels").Index(i), key, msg))
+		}
+	}
+	for _, list := range []struct {
+		name       string
+		namespaces []string
+	}{
+		{"protectedNamespaces", p.ProtectedNamespaces},
+		{"excludedNamespaces", p.ExcludedNamespaces},
+	} {
+		for i, ns := range list.namespaces {
+			for _, msg := range validation.IsDNS1123Label(ns) {
+				errs = append(errs, field.Invalid(path.Child(list.name).Index(i), ns, msg))
+			}
+		}
+	}
+	return errs
+}
+
+func (c InheritanceConfig) validate(path *field.Path, staticScope bool) field.ErrorList {
+	var errs field.ErrorList
+	for _, msg := range validation.IsQualifiedName(c.ParentKey) {
+		errs = append(errs, field.Invalid(path.Child("parentKey"), c.ParentKey, msg))
+	}
+	if c.MaxDepth < 1 {
+		errs = append(errs, field.Invalid(path.Child("maxDepth"), c.MaxDepth, "must be at least 1"))
+	}
+	for i, key := range c.Labels {
+		for _, msg := range validation.IsQualifiedName(key) {
+			errs = append(errs, field.Invalid(path.Child("labels").Index(i), key, msg))
+		}
+		if key == c.ParentKey {
+			errs = append(errs, field.Invalid(path.Child("labels").Index(i), key, "must not be the parent key"))
+		}
+	}
+	if c.Enabled() && staticScope {
+		errs = append(errs, field.Forbidden(path.Child("labels"),
+			"inheritance needs every namespace to be watched, so it may not be used with scope.namespaces"))
+	}
+	return errs
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/internal/audit"
+	"github.com/matanamar10/namesapcelabel/pkg/labelplan"
+)
+
+// inherit walks the parents of ns and returns the labels it inherits: for
+// every inherited key, the value set on the nearest ancestor that does not
+// inherit the key itself. The walk ends at a namespace without a parent or
+// whose parent does not exist, and after Inheritance.MaxDepth ancestors.
+// Nothing is inherited when the parents form a cycle. problem describes a
+// cycle, or a chain longer than the depth limit, and is empty otherwise.
+func (r *NamespaceLabelReconciler) inherit(ctx context.Context,
+	ns *metav1.PartialObjectMetadata) (labels map[string]string, problem string, err error) {
+	if !r.Inheritance.Enabled() {
+		return nil, "", nil
+	}
+	labels = map[string]string{}
+	chain := []string{ns.Name}
+	current := ns
+	for depth := 0; ; depth++ {
+		parent := parentOf(current, r.Inheritance.ParentKey)
+		if parent == "" {
+			return labels, "", nil
+		}
+		if contains(chain, parent) {
+			return nil, fmt.Sprintf("the parents of the namespace form a cycle: %s -> %s",
+				strings.Join(chain, " -> "), parent), nil
+		}
+		if depth == r.Inheritance.MaxDepth {
+			return labels, fmt.Sprintf("the namespace has more than %d ancestors; labels are not inherited from %s "+
+				"and above", r.Inheritance.MaxDepth, parent), nil
+		}
+		chain = append(chain, parent)
+
+		ancestor, err := r.getNamespace(ctx, parent)
+		if apierrors.IsNotFound(err) {
+			return labels, "", nil
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("getting parent namespace %s: %w", parent, err)
+		}
+		owners, err := managedLabels(ancestor)
+		if err != nil {
+			// The labels of the ancestor are then all its own.
+			owners = map[string]string{}
+		}
+		for _, key := range r.Inheritance.Labels {
+			if _, ok := labels[key]; ok {
+				continue
+			}
+			if value, ok := ancestor.Labels[key]; ok && owners[key] != danateamv1.InheritedOwner {
+				labels[key] = value
+			}
+		}
+		current = ancestor
+	}
+}
+
+// parentOf returns the name of the parent of ns, given by its label key or
+// else its annotation key.
+func parentOf(ns metav1.Object, key string) string {
+	if parent, ok := ns.GetLabels()[key]; ok {
+		return parent
+	}
+	return ns.GetAnnotations()[key]
+}
+
+// recordInherited records an event on ns for every change to its inherited
+// labels, and audits them.
+func (r *NamespaceLabelReconciler) recordInherited(ctx context.Context, ns *metav1.PartialObjectMetadata,
+	step labelplan.Step) {
+	for _, c := range step.Changes {
+		switch c.Op {
+		case labelplan.OpRemove:
+			r.namespaceEvent(ns, corev1.EventTypeNormal, EventReasonLabelRemoved,
+				"Removed inherited label %q (was %q)", c.Key, c.Old)
+		case labelplan.OpUpdate:
+			r.namespaceEvent(ns, corev1.EventTypeNormal, EventReasonLabelInherited,
+				"Changed inherited label %q from %q to %q", c.Key, c.Old, c.New)
+		default:
+			r.namespaceEvent(ns, corev1.EventTypeNormal, EventReasonLabelInherited,
+				"Inherited label %q=%q from the parent namespaces", c.Key, c.New)
+		}
+	}
+	if r.Audit == nil || len(step.Changes) == 0 {
+		return
+	}
+	err := r.Audit.Record(ctx, audit.Record{
+		Namespace: ns.Name,
+		Reason:    audit.ReasonInherited,
+		Before:    step.Before,
+		After:     copyMap(step.Labels),
+	})
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to write audit record", "namespace", ns.Name)
+	}
+}
+
+// namespaceAndDescendants returns a request for the Namespace obj and, when
+// labels are inherited, for every namespace below it within the depth limit,
+// so that a change to an ancestor reaches all of its descendants.
+func (r *NamespaceLabelReconciler) namespaceAndDescendants(ctx context.Context, obj client.Object) []reconcile.Request {
+	requests := r.namespaceNamed(ctx, obj)
+	if !r.Inheritance.Enabled() {
+		return requests
+	}
+	list := &metav1.PartialObjectMetadataList{}
+	list.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("NamespaceList"))
+	if err := r.List(ctx, list); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list the descendants of namespace", "namespace", obj.GetName())
+		return requests
+	}
+	children := map[string][]string{}
+	for i := range list.Items {
+		ns := &list.Items[i]
+		if parent := parentOf(ns, r.Inheritance.ParentKey); parent != "" {
+			children[parent] = append(children[parent], ns.Name)
+		}
+	}
+
+	seen := map[string]bool{obj.GetName(): true}
+	level := []string{obj.GetName()}
+	for depth := 0; depth < r.Inheritance.MaxDepth && len(level) > 0; depth++ {
+		var next []string
+		for _, name := range level {
+			for _, child := range children[name] {
+				if seen[child] {
+					continue
+				}
+				seen[child] = true
+				next = append(next, child)
+				requests = append(requests, r.requestFor(child)...)
+			}
+		}
+		level = next
+	}
+	return requests
+}
@@ -18,45 +18,899 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apilabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/internal/audit"
+	"github.com/matanamar10/namesapcelabel/internal/config"
+	"github.com/matanamar10/namesapcelabel/internal/health"
+	"github.com/matanamar10/namesapcelabel/internal/metrics"
+	"github.com/matanamar10/namesapcelabel/internal/sharding"
+	"github.com/matanamar10/namesapcelabel/internal/tracing"
+	"github.com/matanamar10/namesapcelabel/pkg/labelplan"
+	"github.com/matanamar10/namesapcelabel/pkg/namerule"
+)
+
+// ControllerName is the name of the NamespaceLabel controller, used for its
+// workqueue, metrics and logs.
+const ControllerName = "namespacelabel"
+
+// Reasons of the events recorded on NamespaceLabels and their Namespaces.
+const (
+	EventReasonLabelApplied   = "LabelApplied"
+	EventReasonLabelAdopted   = "LabelAdopted"
+	EventReasonLabelRemoved   = "LabelRemoved"
+	EventReasonConflict       = "Conflict"
+	EventReasonDriftCorrected = "DriftCorrected"
+	EventReasonPolicyDenied   = "PolicyDenied"
+	EventReasonImmutable      = "ImmutableLabel"
+	EventReasonInvalidLabel   = "InvalidLabel"
+	EventReasonWriteRejected  = "NamespaceUpdateRejected"
+	EventReasonLabelInherited = "LabelInherited"
+	EventReasonInheritance    = "InheritanceLimited"
 )
 
 // NamespaceLabelReconciler reconciles a NamespaceLabel object
 type NamespaceLabelReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Audit receives a record of every label mutation. Auditing is disabled when nil.
+	Audit *audit.Auditor
+	// Health follows reconcile outcomes for the manager's health checks. Optional.
+	Health *health.ReconcileTracker
+
+	// Policy holds the policy restricting the namespaces and labels the
+	// reconciler manages. The default policy is used when nil.
+	Policy *config.PolicyStore
+	// PolicyUpdates receives the NamespaceLabels to reconcile again after the
+	// policy changed. Optional.
+	PolicyUpdates <-chan event.GenericEvent
+	// MaxConcurrentReconciles is the number of NamespaceLabels reconciled in
+	// parallel. One is used when zero.
+	MaxConcurrentReconciles int
+	// Namespaces limits the reconciler to NamespaceLabels in these
+	// namespaces. All namespaces are reconciled when empty; Namespaces are
+	// then not watched, since that needs access to every Namespace.
+	Namespaces []string
+	// NamespaceSelector limits the reconciler to Namespaces with matching
+	// labels. Optional.
+	NamespaceSelector apilabels.Selector
+	// Shard limits the reconciler to the namespaces of the shards owned by
+	// this replica. All namespaces are reconciled when nil.
+	Shard sharding.Filter
+	// ShardUpdates receives the NamespaceLabels of the shards this replica
+	// starts to own. Optional.
+	ShardUpdates <-chan event.GenericEvent
+	// RateLimiter delays requeued NamespaceLabels. The controller-runtime
+	// default is used when nil.
+	RateLimiter workqueue.TypedRateLimiter[reconcile.Request]
+	// Inheritance makes Namespaces inherit labels from their parent
+	// namespaces. It needs every Namespace to be watched, so it is ignored
+	// when Namespaces is set.
+	Inheritance config.InheritanceConfig
+	// QueueTimer times the wait of requests in the workqueue, which is then
+	// recorded on reconcile spans. Optional.
+	QueueTimer *tracing.QueueTimer
 }
 
 // +kubebuilder:rbac:groups=danateam.namespacelabel.io,resources=namespacelabels,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=danateam.namespacelabel.io,resources=namespacelabels/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=danateam.namespacelabel.io,resources=namespacelabels/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the NamespaceLabel object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// Reconcile applies the labels of every NamespaceLabel in a Namespace to that
+// Namespace. Requests name the Namespace, so that changes to several of its
+// NamespaceLabels collapse into a single reconcile and at most one write.
+// Ownership of every applied key is recorded in the
+// danateamv1.ManagedLabelsAnnotation on the Namespace, so keys requested by
+// several NamespaceLabels are reported as conflicts instead of being
+// overwritten back and forth, and so the labels can be removed again when a
+// NamespaceLabel is deleted. Labels inherited from the parent namespaces are
+// owned by danateamv1.InheritedOwner and rank below those of NamespaceLabels.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.0/pkg/reconcile
 func (r *NamespaceLabelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	ctx, span := tracing.Tracer().Start(ctx, "NamespaceLabel.Reconcile", trace.WithAttributes(
+		tracing.AttrNamespace.String(req.Name),
+	))
+	if wait, ok := r.QueueTimer.Waited(req); ok {
+		span.SetAttributes(tracing.AttrQueueWait.Float64(wait.Seconds()))
+	}
+	done := r.Health.Start()
+	result, err := r.reconcile(ctx, req)
+	done(err)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (r *NamespaceLabelReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	namespace := req.Name
+
+	if !r.owns(namespace) {
+		// Another replica reconciles the namespace, finalizers included, and
+		// exports its series.
+		metrics.ForgetNamespace(namespace)
+		return ctrl.Result{}, nil
+	}
+
+	list := &danateamv1.NamespaceLabelList{}
+	if err := r.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+	nls := list.Items
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("namespacelabel.count", len(nls)))
+	if len(nls) == 0 && !r.inherits() {
+		metrics.ForgetNamespace(namespace)
+		return ctrl.Result{}, nil
+	}
+
+	policy, revision := r.Policy.Load()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("namespacelabel.policy_revision", revision))
+	if policy.Excludes(namespace) || (len(r.Namespaces) > 0 && !contains(r.Namespaces, namespace)) {
+		return ctrl.Result{}, r.ignore(ctx, namespace, nls)
+	}
+
+	ns, err := r.getNamespace(ctx, namespace)
+	if apierrors.IsNotFound(err) && (r.NamespaceSelector != nil || allDeleted(nls)) {
+		// With a selector, the cache only holds the matching Namespaces.
+		return ctrl.Result{}, r.ignore(ctx, namespace, nls)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if r.NamespaceSelector != nil && !r.NamespaceSelector.Matches(apilabels.Set(ns.Labels)) {
+		return ctrl.Result{}, r.ignore(ctx, namespace, nls)
+	}
+
+	for i := range nls {
+		nl := &nls[i]
+		orig := nl.DeepCopy()
+		if nl.DeletionTimestamp.IsZero() && controllerutil.AddFinalizer(nl, danateamv1.Finalizer) {
+			if err := r.patchFinalizers(ctx, nl, orig); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
 
-	// TODO(user): your logic here
+	owners, err := managedLabels(ns)
+	if err != nil {
+		logger.Error(err, "ignoring malformed managed labels annotation", "namespace", ns.Name)
+		owners = map[string]string{}
+	}
+	var inherited map[string]string
+	if r.inherits() {
+		var problem string
+		if inherited, problem, err = r.inherit(ctx, ns); err != nil {
+			return ctrl.Result{}, err
+		}
+		if problem != "" {
+			logger.Info("inheritance limited", "namespace", ns.Name, "problem", problem)
+			r.namespaceEvent(ns, corev1.EventTypeWarning, EventReasonInheritance, "%s", problem)
+		}
+	}
+	locked, err := labelplan.Locks(ns.Annotations)
+	if err != nil {
+		logger.Error(err, "ignoring malformed immutable labels annotation", "namespace", ns.Name)
+		locked = map[string]string{}
+	}
+	unlocked := labelplan.Unlocked(ns.Annotations)
+	p, planned, inheritedStep := planNamespace(ctx, policy, namespace, nls, ns.Labels, owners, locked, unlocked,
+		inherited)
 
-	return ctrl.Result{}, nil
+	var findings []metrics.Finding
+	for _, step := range p.Steps {
+		nl := &planned[step.Index]
+		for _, c := range step.Conflicts {
+			findings = append(findings, metrics.Finding{Requester: nl.Name, Key: c.Key})
+			r.event(nl, ns, corev1.EventTypeWarning, EventReasonConflict,
+				"Label %q is owned by NamespaceLabel %q and was not applied", c.Key, c.Owner)
+		}
+		for _, key := range step.Unadopted {
+			findings = append(findings, metrics.Finding{Requester: nl.Name, Key: key})
+			r.event(nl, ns, corev1.EventTypeWarning, EventReasonConflict,
+				"Label %q is set to %q outside the operator and was not adopted", key, step.Labels[key])
+		}
+		for _, d := range step.Denials {
+			findings = append(findings, metrics.Finding{Requester: nl.Name, Key: d.Key, Reason: d.Reason})
+			r.event(nl, ns, corev1.EventTypeWarning, EventReasonPolicyDenied,
+				"Label %q was not applied: %s", d.Key, denialMessages[d.Reason])
+		}
+		for _, l := range step.Invalid {
+			r.event(nl, ns, corev1.EventTypeWarning, EventReasonInvalidLabel,
+				"Label %q=%q is not a valid label and was not applied: %s", l.Key, l.Value, l.Message)
+		}
+		for _, l := range step.Locked {
+			if l.Removed {
+				r.event(nl, ns, corev1.EventTypeWarning, EventReasonImmutable,
+					"Label %q is immutable and stays %q", l.Key, l.Value)
+			} else {
+				r.event(nl, ns, corev1.EventTypeWarning, EventReasonImmutable,
+					"Label %q is immutable and stays %q instead of %q", l.Key, l.Value, l.Requested)
+			}
+		}
+	}
+	for _, key := range inheritedStep.Unadopted {
+		findings = append(findings, metrics.Finding{Requester: danateamv1.InheritedOwner, Key: key})
+		r.namespaceEvent(ns, corev1.EventTypeWarning, EventReasonConflict,
+			"Inherited label %q is set to %q outside the operator and was not adopted", key, inheritedStep.Labels[key])
+	}
+	for _, d := range inheritedStep.Denials {
+		findings = append(findings, metrics.Finding{Requester: danateamv1.InheritedOwner, Key: d.Key, Reason: d.Reason})
+		r.namespaceEvent(ns, corev1.EventTypeWarning, EventReasonPolicyDenied,
+			"Inherited label %q was not applied: %s", d.Key, denialMessages[d.Reason])
+	}
+
+	// Conflicts and denials are only counted when they start, not on every reconcile.
+	metrics.RecordFindings(namespace, findings)
+
+	writeErr := r.writeNamespace(ctx, ns, p.Labels, p.Owners, p.Locked, revision)
+	if writeErr == nil {
+		for _, step := range p.Steps {
+			nl := &planned[step.Index]
+			if len(step.Drifted) > 0 {
+				logger.Info("corrected drifted labels", "namespace", ns.Name, "keys", step.Drifted)
+				metrics.DriftCorrections.Add(float64(len(step.Drifted)))
+			}
+			for _, key := range step.Adopted {
+				r.event(nl, ns, corev1.EventTypeNormal, EventReasonLabelAdopted,
+					"Adopted label %q=%q set outside the operator", key, step.Labels[key])
+			}
+			r.recordChanges(nl, ns, step.Changes)
+
+			reason := audit.ReasonSpecChange
+			switch {
+			case !nl.DeletionTimestamp.IsZero():
+				reason = audit.ReasonCleanup
+			case len(step.Drifted) == len(step.Changes):
+				reason = audit.ReasonDrift
+			}
+			r.audit(ctx, nl, reason, step.Before, step.Labels, step.Changes)
+		}
+		r.recordInherited(ctx, ns, inheritedStep)
+	}
+
+	for _, step := range p.Steps {
+		nl := &planned[step.Index]
+		if !nl.DeletionTimestamp.IsZero() {
+			if writeErr == nil {
+				if err := r.removeFinalizer(ctx, nl); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+			continue
+		}
+
+		orig := nl.DeepCopy()
+		if writeErr == nil {
+			nl.Status.AppliedLabels = step.Applied
+			nl.Status.ImmutableLabels = immutableLabels(nl, step.Applied, unlocked)
+			nl.Status.ObservedGeneration = nl.Generation
+		}
+		// Only the NamespaceLabels that changed the Namespace can have caused
+		// the write to fail; the labels of the others are already in place.
+		var stepErr error
+		if writeErr != nil && (len(step.Changes) > 0 || len(step.Adopted) > 0) {
+			stepErr = writeErr
+			r.event(nl, ns, corev1.EventTypeWarning, EventReasonWriteRejected,
+				"Namespace update failed: %v", writeErr)
+		}
+		nl.Status.PolicyRevision = revision
+		meta.SetStatusCondition(&nl.Status.Conditions, condition(step, nl.Generation, stepErr))
+		var nameCond *metav1.Condition
+		nl.Status.DerivedLabels, nameCond = deriveLabels(nl)
+		if nameCond != nil {
+			meta.SetStatusCondition(&nl.Status.Conditions, *nameCond)
+		} else {
+			meta.RemoveStatusCondition(&nl.Status.Conditions, danateamv1.ConditionNameMatched)
+		}
+		if !equality.Semantic.DeepEqual(orig.Status, nl.Status) {
+			if err := r.Status().Patch(ctx, nl, client.MergeFrom(orig)); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	if writeErr == nil {
+		r.recordNamespaceMetrics(ns.Name, p.Owners, planned)
+	}
+	if permanent(writeErr) {
+		// Retrying the same write fails the same way; a change to a
+		// NamespaceLabel or the Namespace triggers the next attempt.
+		return ctrl.Result{}, reconcile.TerminalError(writeErr)
+	}
+	return ctrl.Result{}, writeErr
+}
+
+// permanent reports whether err rejects a Namespace write for its content or
+// the operator's permissions, so that retrying it cannot succeed.
+func permanent(err error) bool {
+	return apierrors.IsInvalid(err) || apierrors.IsForbidden(err) || apierrors.IsBadRequest(err)
+}
+
+// inherits reports whether Namespaces inherit labels from their parents.
+func (r *NamespaceLabelReconciler) inherits() bool {
+	return r.Inheritance.Enabled() && len(r.Namespaces) == 0
+}
+
+// owns reports whether namespace belongs to a shard owned by this replica.
+func (r *NamespaceLabelReconciler) owns(namespace string) bool {
+	return r.Shard == nil || r.Shard.Owns(namespace)
+}
+
+// ignore leaves a Namespace outside the reconciler's scope alone, but lets
+// its NamespaceLabels be deleted if they were created before the namespace
+// left the scope. Its series are deleted, like those of a deleted Namespace.
+func (r *NamespaceLabelReconciler) ignore(ctx context.Context, namespace string,
+	nls []danateamv1.NamespaceLabel) error {
+	metrics.ForgetNamespace(namespace)
+	for i := range nls {
+		if nls[i].DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := r.removeFinalizer(ctx, &nls[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *NamespaceLabelReconciler) removeFinalizer(ctx context.Context, nl *danateamv1.NamespaceLabel) error {
+	orig := nl.DeepCopy()
+	if controllerutil.RemoveFinalizer(nl, danateamv1.Finalizer) {
+		return r.patchFinalizers(ctx, nl, orig)
+	}
+	return nil
+}
+
+// patchFinalizers writes the finalizers of nl. A patch is sent instead of an
+// update, since cached NamespaceLabels lack the fields cachetrim drops and an
+// update would remove them on the server. The patch is made with optimistic
+// locking, so finalizers added by others in the meantime are not lost.
+func (r *NamespaceLabelReconciler) patchFinalizers(ctx context.Context, nl, orig *danateamv1.NamespaceLabel) error {
+	return r.Patch(ctx, nl, client.MergeFromWithOptions(orig, client.MergeFromWithOptimisticLock{}))
+}
+
+// writeNamespace patches ns so it carries labels, the ownership annotation
+// for owners, the hash of both in the DesiredStateAnnotation and the locked
+// keys in the ImmutableLabelsAnnotation. Nothing is sent when the desired
+// state hashes the same as the one last written, the locks are unchanged and
+// the managed labels are still in place.
+func (r *NamespaceLabelReconciler) writeNamespace(ctx context.Context, ns *metav1.PartialObjectMetadata,
+	labels, owners, locked map[string]string, policyRevision string) error {
+	var managed, hash, locks string
+	if len(owners) > 0 {
+		value, err := json.Marshal(owners)
+		if err != nil {
+			return err
+		}
+		managed = string(value)
+		hash = desiredStateHash(labels, owners, policyRevision)
+	}
+	if len(locked) > 0 {
+		value, err := json.Marshal(locked)
+		if err != nil {
+			return err
+		}
+		locks = string(value)
+	}
+	if ns.Annotations[danateamv1.DesiredStateAnnotation] == hash &&
+		ns.Annotations[danateamv1.ManagedLabelsAnnotation] == managed &&
+		ns.Annotations[danateamv1.ImmutableLabelsAnnotation] == locks && inPlace(ns.Labels, labels, owners) {
+		metrics.NamespaceWrites.WithLabelValues(metrics.WriteSkipped).Inc()
+		return nil
+	}
+
+	orig := ns.DeepCopy()
+	ns.Labels = labels
+	if len(owners) == 0 {
+		delete(ns.Annotations, danateamv1.ManagedLabelsAnnotation)
+		delete(ns.Annotations, danateamv1.DesiredStateAnnotation)
+	} else {
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Annotations[danateamv1.ManagedLabelsAnnotation] = managed
+		ns.Annotations[danateamv1.DesiredStateAnnotation] = hash
+	}
+	if locks == "" {
+		delete(ns.Annotations, danateamv1.ImmutableLabelsAnnotation)
+	} else {
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+		ns.Annotations[danateamv1.ImmutableLabelsAnnotation] = locks
+	}
+
+	start := time.Now()
+	err := r.Patch(ctx, ns, client.MergeFrom(orig))
+	result := metrics.ResultSuccess
+	if err != nil {
+		result = metrics.ResultError
+	} else {
+		metrics.NamespaceWrites.WithLabelValues(metrics.WritePerformed).Inc()
+	}
+	metrics.WriteDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// recordChanges records an event on nl and ns for every label change.
+func (r *NamespaceLabelReconciler) recordChanges(nl *danateamv1.NamespaceLabel, ns *metav1.PartialObjectMetadata,
+	changes []labelplan.Change) {
+	for _, c := range changes {
+		switch {
+		case c.Op == labelplan.OpRemove:
+			r.event(nl, ns, corev1.EventTypeNormal, EventReasonLabelRemoved,
+				"Removed label %q (was %q)", c.Key, c.Old)
+		case c.Drifted:
+			r.event(nl, ns, corev1.EventTypeWarning, EventReasonDriftCorrected,
+				"Restored label %q from %q to %q", c.Key, c.Old, c.New)
+		case c.Op == labelplan.OpUpdate:
+			r.event(nl, ns, corev1.EventTypeNormal, EventReasonLabelApplied,
+				"Changed label %q from %q to %q", c.Key, c.Old, c.New)
+		default:
+			r.event(nl, ns, corev1.EventTypeNormal, EventReasonLabelApplied,
+				"Set label %q to %q", c.Key, c.New)
+		}
+	}
+}
+
+// audit writes an audit record for changes, if there are any. Failing to
+// audit does not fail the reconcile, since the Namespace is already written.
+func (r *NamespaceLabelReconciler) audit(ctx context.Context, nl *danateamv1.NamespaceLabel, reason audit.Reason,
+	before, after map[string]string, changes []labelplan.Change) {
+	if r.Audit == nil || len(changes) == 0 {
+		return
+	}
+	err := r.Audit.Record(ctx, audit.Record{
+		Namespace:      nl.Namespace,
+		NamespaceLabel: nl.Name,
+		Generation:     nl.Generation,
+		Reason:         reason,
+		Before:         before,
+		After:          copyMap(after),
+	})
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to write audit record", "namespace", nl.Namespace)
+	}
+}
+
+// event records an event on nl and, naming nl, on ns so that describing
+// either object explains why a label changed.
+func (r *NamespaceLabelReconciler) event(nl *danateamv1.NamespaceLabel, ns *metav1.PartialObjectMetadata,
+	eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	message := fmt.Sprintf(messageFmt, args...)
+	r.Recorder.Event(nl, eventType, reason, message)
+	r.namespaceEvent(ns, eventType, reason, "NamespaceLabel %s: %s", nl.Name, message)
+}
+
+// namespaceEvent records an event on ns alone, for labels no NamespaceLabel
+// requests. Events on the cluster-scoped Namespace are created in the default
+// namespace, which a reconciler limited to Namespaces may not write to, so
+// they are only recorded when every namespace is reconciled.
+func (r *NamespaceLabelReconciler) namespaceEvent(ns *metav1.PartialObjectMetadata,
+	eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil || len(r.Namespaces) > 0 {
+		return
+	}
+	r.Recorder.Eventf(ns, eventType, reason, messageFmt, args...)
+}
+
+// recordNamespaceMetrics updates the per-namespace gauges from the
+// NamespaceLabels that were just reconciled.
+func (r *NamespaceLabelReconciler) recordNamespaceMetrics(namespace string, owners map[string]string,
+	nls []danateamv1.NamespaceLabel) {
+	compliant := true
+	active := 0
+	for i := range nls {
+		if !nls[i].DeletionTimestamp.IsZero() {
+			continue
+		}
+		active++
+		if !meta.IsStatusConditionTrue(nls[i].Status.Conditions, danateamv1.ConditionReady) {
+			compliant = false
+		}
+	}
+	if active == 0 {
+		metrics.ForgetNamespace(namespace)
+		return
+	}
+
+	metrics.RecordNamespace(namespace, len(owners), compliant)
+}
+
+// denialMessages explain the config.Policy denial reasons in events and conditions.
+var denialMessages = map[string]string{
+	config.DenialProtectedPrefix:    "the key has a protected prefix",
+	config.DenialProtectedLabel:     "the key is protected",
+	config.DenialProtectedNamespace: "the namespace is protected",
+}
+
+// deriveLabels returns the labels the name rules of nl derive from the name
+// of its Namespace, and the NameMatched condition describing them. The
+// condition is nil when nl has no name rules.
+func deriveLabels(nl *danateamv1.NamespaceLabel) (map[string]string, *metav1.Condition) {
+	if len(nl.Spec.NameRules) == 0 {
+		return nil, nil
+	}
+	cond := &metav1.Condition{
+		Type:               danateamv1.ConditionNameMatched,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: nl.Generation,
+	}
+	rules, errs := namerule.Compile(nl.Spec.NameRules, field.NewPath("spec", "nameRules"))
+	if len(errs) > 0 {
+		cond.Reason = danateamv1.ReasonInvalidSpec
+		cond.Message = errs.ToAggregate().Error()
+		return nil, cond
+	}
+	derived, matched, err := rules.Derive(nl.Namespace)
+	switch {
+	case err != nil:
+		cond.Reason = danateamv1.ReasonInvalidValue
+		cond.Message = fmt.Sprintf("namespace name %q: %v", nl.Namespace, err)
+	case !matched:
+		cond.Reason = danateamv1.ReasonNoMatch
+		cond.Message = fmt.Sprintf("namespace name %q matches none of the %d name rules",
+			nl.Namespace, len(nl.Spec.NameRules))
+	default:
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = danateamv1.ReasonNameMatched
+		cond.Message = fmt.Sprintf("%d labels derived from namespace name %q", len(derived), nl.Namespace)
+	}
+	return derived, cond
+}
+
+// immutableLabels returns the keys of applied that are immutable for nl:
+// those its spec lists, and those applied as immutable before that are not
+// unlocked.
+func immutableLabels(nl *danateamv1.NamespaceLabel, applied map[string]string, unlocked []string) []string {
+	var keys []string
+	for _, key := range labelplan.Immutable(nl) {
+		if _, ok := applied[key]; !ok {
+			continue
+		}
+		if contains(nl.Spec.Immutable, key) || !contains(unlocked, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// condition returns the Ready condition describing step.
+func condition(step labelplan.Step, generation int64, writeErr error) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               danateamv1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             danateamv1.ReasonApplied,
+		Message:            fmt.Sprintf("%d labels applied", len(step.Applied)),
+	}
+	switch {
+	case writeErr != nil:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = danateamv1.ReasonError
+		cond.Message = fmt.Sprintf("updating namespace: %v", writeErr)
+	case len(step.Invalid) > 0:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = danateamv1.ReasonInvalidLabel
+		invalid := make([]string, 0, len(step.Invalid))
+		for _, l := range step.Invalid {
+			invalid = append(invalid, fmt.Sprintf("%s (%s)", l.Key, l.Message))
+		}
+		cond.Message = fmt.Sprintf("invalid labels are not applied: %s", strings.Join(invalid, ", "))
+	case len(step.Denials) > 0:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = danateamv1.ReasonPolicyDenied
+		denied := make([]string, 0, len(step.Denials))
+		for _, d := range step.Denials {
+			denied = append(denied, fmt.Sprintf("%s (%s)", d.Key, denialMessages[d.Reason]))
+		}
+		cond.Message = fmt.Sprintf("labels denied by policy are not applied: %s", strings.Join(denied, ", "))
+	case len(step.Locked) > 0:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = danateamv1.ReasonImmutable
+		locked := make([]string, 0, len(step.Locked))
+		for _, l := range step.Locked {
+			locked = append(locked, fmt.Sprintf("%s=%s", l.Key, l.Value))
+		}
+		cond.Message = fmt.Sprintf("immutable labels keep their applied value: %s; "+
+			"list the keys in the %s annotation of the Namespace to change them",
+			strings.Join(locked, ", "), danateamv1.UnlockImmutableLabelsAnnotation)
+	case len(step.Conflicts) > 0 || len(step.Unadopted) > 0:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = danateamv1.ReasonConflict
+		var messages []string
+		if len(step.Conflicts) > 0 {
+			keys := make([]string, 0, len(step.Conflicts))
+			for _, c := range step.Conflicts {
+				keys = append(keys, c.Key)
+			}
+			messages = append(messages, fmt.Sprintf("labels owned by another NamespaceLabel are not applied: %s",
+				strings.Join(keys, ", ")))
+		}
+		if len(step.Unadopted) > 0 {
+			messages = append(messages, fmt.Sprintf(
+				"labels set outside the operator with another value are not adopted: %s",
+				strings.Join(step.Unadopted, ", ")))
+		}
+		cond.Message = strings.Join(messages, "; ")
+	}
+	return cond
+}
+
+// planNamespace plans the labels of the Namespace namespace labeled with live
+// and owned according to owners, as requested by nls and with the inherited
+// labels below them, with the immutable keys unlocked changeable. It returns
+// the NamespaceLabels the plan was computed for, which the Index of every
+// step refers to: NamespaceLabels being deleted without the finalizer have
+// already released their keys and are left out. The step of the inherited
+// labels is returned apart from the others.
+func planNamespace(ctx context.Context, policy config.Policy, namespace string, nls []danateamv1.NamespaceLabel,
+	live, owners, locked map[string]string, unlocked []string,
+	inherited map[string]string) (labelplan.Plan, []danateamv1.NamespaceLabel, labelplan.Step) {
+	_, span := tracing.Tracer().Start(ctx, "NamespaceLabel.plan")
+	defer span.End()
+
+	planned := make([]danateamv1.NamespaceLabel, 0, len(nls))
+	for i := range nls {
+		if nls[i].DeletionTimestamp.IsZero() || controllerutil.ContainsFinalizer(&nls[i], danateamv1.Finalizer) {
+			planned = append(planned, nls[i])
+		}
+	}
+	requests := labelplan.Requests(planned)
+	// Without inherited labels, the request releases the keys inherited before.
+	requests = append(requests, labelplan.Inherited(inherited, requests))
+	p := labelplan.Compute(labelplan.Input{
+		Namespace:     namespace,
+		Live:          live,
+		Owners:        owners,
+		Requests:      requests,
+		Policy:        policy,
+		AdoptExisting: policy.AdoptExisting,
+		Unlocked:      unlocked,
+		Locked:        locked,
+	})
+
+	var inheritedStep labelplan.Step
+	steps := make([]labelplan.Step, 0, len(planned))
+	for _, step := range p.Steps {
+		if step.Index == len(planned) {
+			inheritedStep = step
+			continue
+		}
+		steps = append(steps, step)
+	}
+	p.Steps = steps
+
+	for _, step := range p.Steps {
+		span.AddEvent("step", trace.WithAttributes(
+			tracing.AttrNamespaceLabel.String(step.Name),
+			attribute.Int("namespacelabel.changes", len(step.Changes)),
+			attribute.Int("namespacelabel.conflicts", len(step.Conflicts)),
+			attribute.Int("namespacelabel.denied", len(step.Denials)),
+		))
+	}
+	span.SetAttributes(attribute.Int("namespacelabel.inherited", len(inheritedStep.Applied)))
+	return p, planned, inheritedStep
+}
+
+// allDeleted reports whether every NamespaceLabel of nls is being deleted.
+func allDeleted(nls []danateamv1.NamespaceLabel) bool {
+	for i := range nls {
+		if nls[i].DeletionTimestamp.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// getNamespace reads the metadata of a Namespace, which is all the reconciler
+// needs, so that only metadata is cached.
+func (r *NamespaceLabelReconciler) getNamespace(ctx context.Context, name string) (*metav1.PartialObjectMetadata, error) {
+	gvk := corev1.SchemeGroupVersion.WithKind("Namespace")
+	ns := &metav1.PartialObjectMetadata{}
+	ns.SetGroupVersionKind(gvk)
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+		return nil, err
+	}
+	// Some clients clear the type of the object read; patches and events need it.
+	ns.SetGroupVersionKind(gvk)
+	return ns, nil
+}
+
+// managedLabels decodes the ownership annotation of ns.
+func managedLabels(ns *metav1.PartialObjectMetadata) (map[string]string, error) {
+	owners := map[string]string{}
+	value, ok := ns.Annotations[danateamv1.ManagedLabelsAnnotation]
+	if !ok || value == "" {
+		return owners, nil
+	}
+	if err := json.Unmarshal([]byte(value), &owners); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", danateamv1.ManagedLabelsAnnotation, err)
+	}
+	return owners, nil
+}
+
+// desiredStateHash hashes the labels owned according to owners, the owners
+// and the policy revision they were planned with.
+func desiredStateHash(labels, owners map[string]string, policyRevision string) string {
+	managed := make(map[string]string, len(owners))
+	for key := range owners {
+		managed[key] = labels[key]
+	}
+	// Map keys are encoded in sorted order, so equal states hash the same.
+	data, _ := json.Marshal(struct {
+		Labels, Owners map[string]string
+		Policy         string
+	}{managed, owners, policyRevision})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// inPlace reports whether live carries the value in labels of every key in owners.
+func inPlace(live, labels, owners map[string]string) bool {
+	for key := range owners {
+		if value, ok := live[key]; !ok || value != labels[key] {
+			return false
+		}
+	}
+	return true
+}
+
+func copyMap(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, i int) bool {
+	for _, item := range list {
+		if item == i {
+			return true
+		}
+	}
+	return false
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *NamespaceLabelReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&danateamv1.NamespaceLabel{}).
-		Complete(r)
+	opts := controller.Options{
+		MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+		RateLimiter:             r.RateLimiter,
+	}
+	if r.QueueTimer != nil {
+		opts.NewQueue = r.QueueTimer.NewQueue
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
+		Named(ControllerName).
+		WithOptions(opts).
+		// Requests name the Namespace of a NamespaceLabel, so changes to its
+		// NamespaceLabels are queued once and reconciled together.
+		Watches(&danateamv1.NamespaceLabel{}, handler.EnqueueRequestsFromMapFunc(r.namespaceOf))
+	if len(r.Namespaces) == 0 {
+		// Labels changed outside the operator are restored right away, and
+		// changes to a namespace reach the descendants inheriting from it.
+		// Only the metadata of Namespaces is watched and cached.
+		b = b.WatchesMetadata(&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.namespaceAndDescendants),
+			builder.WithPredicates(predicate.Or(predicate.LabelChangedPredicate{},
+				predicate.AnnotationChangedPredicate{})))
+	}
+	if r.PolicyUpdates != nil {
+		b = b.WatchesRawSource(source.Channel(r.PolicyUpdates, handler.EnqueueRequestsFromMapFunc(r.namespaceOf)))
+	}
+	if r.ShardUpdates != nil {
+		b = b.WatchesRawSource(source.Channel(r.ShardUpdates, handler.EnqueueRequestsFromMapFunc(r.namespaceOf)))
+	}
+	return b.Complete(r)
+}
+
+// namespaceOf returns a request for the namespace of obj.
+func (r *NamespaceLabelReconciler) namespaceOf(_ context.Context, obj client.Object) []reconcile.Request {
+	return r.requestFor(obj.GetNamespace())
+}
+
+// namespaceNamed returns a request for the Namespace obj.
+func (r *NamespaceLabelReconciler) namespaceNamed(_ context.Context, obj client.Object) []reconcile.Request {
+	return r.requestFor(obj.GetName())
+}
+
+func (r *NamespaceLabelReconciler) requestFor(namespace string) []reconcile.Request {
+	if !r.owns(namespace) {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: namespace}}}
+}
+
+// RequeueShards returns a sharding.Coordinator OnAcquire callback that sends
+// the NamespaceLabels of the acquired shards, among shards, to updates.
+func RequeueShards(reader client.Reader, shards int, updates chan<- event.GenericEvent) func(context.Context, []int) {
+	return func(ctx context.Context, acquired []int) {
+		list := &danateamv1.NamespaceLabelList{}
+		if err := reader.List(ctx, list); err != nil {
+			log.FromContext(ctx).Error(err, "unable to list NamespaceLabels of acquired shards", "shards", acquired)
+			return
+		}
+		for i := range list.Items {
+			nl := &list.Items[i]
+			if !containsInt(acquired, sharding.Of(nl.Namespace, shards)) {
+				continue
+			}
+			select {
+			case updates <- event.GenericEvent{Object: nl}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// ForgetShards returns a sharding.Coordinator OnRelease callback that deletes
+// the series of the namespaces in the released shards, which the replica
+// taking them over exports from then on.
+func ForgetShards(shards int) func(context.Context, []int) {
+	return func(_ context.Context, released []int) {
+		metrics.ForgetNamespaces(func(namespace string) bool {
+			return containsInt(released, sharding.Of(namespace, shards))
+		})
+	}
 }
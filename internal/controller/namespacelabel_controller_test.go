@@ -17,17 +17,30 @@ limitations under the License.
 package controller
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	goerrors "errors"
+	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apilabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/internal/audit"
+	"github.com/matanamar10/namesapcelabel/internal/cachetrim"
+	"github.com/matanamar10/namesapcelabel/internal/config"
+	"github.com/matanamar10/namesapcelabel/internal/sharding"
 )
 
 var _ = Describe("NamespaceLabel Controller", func() {
@@ -36,49 +49,546 @@ var _ = Describe("NamespaceLabel Controller", func() {
 
 		ctx := context.Background()
 
-		typeNamespacedName := types.NamespacedName{
-			Name:      resourceName,
-			Namespace: "default", // TODO(user):Modify as needed
+		var namespace string
+		var typeNamespacedName types.NamespacedName
+		var controllerReconciler *NamespaceLabelReconciler
+		var recorder *record.FakeRecorder
+
+		reconcileNamespace := func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: namespace},
+			})
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		}
+
+		namespaceLabels := func() map[string]string {
+			ns := &corev1.Namespace{}
+			ExpectWithOffset(1, k8sClient.Get(ctx, types.NamespacedName{Name: namespace}, ns)).To(Succeed())
+			return ns.Labels
 		}
-		namespacelabel := &danateamv1.NamespaceLabel{}
 
 		BeforeEach(func() {
+			By("creating a namespace for the test")
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "nslabel-"}}
+			Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+			namespace = ns.Name
+			typeNamespacedName = types.NamespacedName{Name: resourceName, Namespace: namespace}
+
+			recorder = record.NewFakeRecorder(100)
+			controllerReconciler = &NamespaceLabelReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: recorder,
+				Policy:   config.NewPolicyStore(config.Default().Policy),
+			}
+
 			By("creating the custom resource for the Kind NamespaceLabel")
-			err := k8sClient.Get(ctx, typeNamespacedName, namespacelabel)
-			if err != nil && errors.IsNotFound(err) {
-				resource := &danateamv1.NamespaceLabel{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      resourceName,
-						Namespace: "default",
-					},
-					// TODO(user): Specify other spec details if needed.
-				}
-				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			resource := &danateamv1.NamespaceLabel{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: namespace,
+				},
+				Spec: danateamv1.NamespaceLabelSpec{
+					Labels: map[string]string{"team": "platform", "tier": "gold"},
+				},
 			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
 		})
 
 		AfterEach(func() {
-			// TODO(user): Cleanup logic after each test, like removing the resource instance.
 			resource := &danateamv1.NamespaceLabel{}
 			err := k8sClient.Get(ctx, typeNamespacedName, resource)
+			if errors.IsNotFound(err) {
+				return
+			}
 			Expect(err).NotTo(HaveOccurred())
 
 			By("Cleanup the specific resource instance NamespaceLabel")
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			reconcileNamespace()
 		})
-		It("should successfully reconcile the resource", func() {
+
+		It("should apply the labels to the namespace", func() {
 			By("Reconciling the created resource")
-			controllerReconciler := &NamespaceLabelReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
+			reconcileNamespace()
+
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+			Expect(namespaceLabels()).To(HaveKeyWithValue("tier", "gold"))
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: namespace}, ns)).To(Succeed())
+			Expect(ns.Annotations).To(HaveKey(danateamv1.DesiredStateAnnotation))
+
+			resource := &danateamv1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Finalizers).To(ContainElement(danateamv1.Finalizer))
+			Expect(resource.Status.AppliedLabels).To(Equal(map[string]string{"team": "platform", "tier": "gold"}))
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, danateamv1.ConditionReady)).To(BeTrue())
+
+			By("recording an event on the NamespaceLabel and the Namespace for each label")
+			Expect(recorder.Events).To(Receive(Equal(`Normal LabelApplied Set label "team" to "platform"`)))
+			Expect(recorder.Events).To(Receive(Equal(
+				`Normal LabelApplied NamespaceLabel test-resource: Set label "team" to "platform"`)))
+		})
+
+		It("should restore labels changed outside the operator", func() {
+			reconcileNamespace()
+			for len(recorder.Events) > 0 {
+				<-recorder.Events
 			}
 
+			By("changing a managed label on the namespace")
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: namespace}, ns)).To(Succeed())
+			ns.Labels["team"] = "someone-else"
+			Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+
+			reconcileNamespace()
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+			Expect(recorder.Events).To(Receive(Equal(
+				`Warning DriftCorrected Restored label "team" from "someone-else" to "platform"`)))
+		})
+
+		It("should report a conflict when another NamespaceLabel owns a key", func() {
+			reconcileNamespace()
+
+			By("creating a second NamespaceLabel requesting the same key")
+			other := &danateamv1.NamespaceLabel{
+				ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: namespace},
+				Spec:       danateamv1.NamespaceLabelSpec{Labels: map[string]string{"team": "payments"}},
+			}
+			Expect(k8sClient.Create(ctx, other)).To(Succeed())
+			reconcileNamespace()
+
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(other), other)).To(Succeed())
+			cond := meta.FindStatusCondition(other.Status.Conditions, danateamv1.ConditionReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Reason).To(Equal(danateamv1.ReasonConflict))
+
+			Expect(k8sClient.Delete(ctx, other)).To(Succeed())
+			reconcileNamespace()
+		})
+
+		It("should write the labels of every NamespaceLabel in one patch", func() {
+			other := &danateamv1.NamespaceLabel{
+				ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: namespace},
+				Spec:       danateamv1.NamespaceLabelSpec{Labels: map[string]string{"cost-center": "42"}},
+			}
+			Expect(k8sClient.Create(ctx, other)).To(Succeed())
+			writes := &patchCounter{Client: k8sClient}
+			controllerReconciler.Client = writes
+
+			reconcileNamespace()
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+			Expect(namespaceLabels()).To(HaveKeyWithValue("cost-center", "42"))
+			Expect(writes.patches).To(Equal(1))
+
+			By("skipping the write when the labels are up to date")
+			reconcileNamespace()
+			Expect(writes.patches).To(Equal(1))
+
+			Expect(k8sClient.Delete(ctx, other)).To(Succeed())
+			reconcileNamespace()
+			Expect(namespaceLabels()).NotTo(HaveKey("cost-center"))
+		})
+
+		It("should keep the fields dropped from the cache when writing finalizers", func() {
+			resource := &danateamv1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Annotations = map[string]string{cachetrim.LastAppliedAnnotation: `{"kind":"NamespaceLabel"}`}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			controllerReconciler.Client = &trimmingClient{Client: k8sClient}
+
+			reconcileNamespace()
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Finalizers).To(ContainElement(danateamv1.Finalizer))
+			Expect(resource.Annotations).To(HaveKey(cachetrim.LastAppliedAnnotation))
+		})
+
+		It("should refuse labels with protected prefixes", func() {
+			resource := &danateamv1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Labels["kubernetes.io/metadata.name"] = "spoofed"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			reconcileNamespace()
+			Expect(namespaceLabels()).NotTo(HaveKeyWithValue("kubernetes.io/metadata.name", "spoofed"))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			cond := meta.FindStatusCondition(resource.Status.Conditions, danateamv1.ConditionReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Reason).To(Equal(danateamv1.ReasonPolicyDenied))
+		})
+
+		It("should adopt existing labels without rewriting them", func() {
+			policy := config.Default().Policy
+			policy.AdoptExisting = true
+			controllerReconciler.Policy = config.NewPolicyStore(policy)
+
+			By("labeling the namespace outside the operator")
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: namespace}, ns)).To(Succeed())
+			ns.Labels["team"] = "platform"
+			ns.Labels["tier"] = "silver"
+			Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+
+			reconcileNamespace()
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+			Expect(namespaceLabels()).To(HaveKeyWithValue("tier", "silver"))
+			Expect(recorder.Events).To(Receive(Equal(
+				`Warning Conflict Label "tier" is set to "silver" outside the operator and was not adopted`)))
+
+			resource := &danateamv1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.AppliedLabels).To(Equal(map[string]string{"team": "platform"}))
+			cond := meta.FindStatusCondition(resource.Status.Conditions, danateamv1.ConditionReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Reason).To(Equal(danateamv1.ReasonConflict))
+		})
+
+		It("should leave out invalid labels and write the others", func() {
+			other := &danateamv1.NamespaceLabel{
+				ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: namespace},
+				Spec: danateamv1.NamespaceLabelSpec{
+					Labels: map[string]string{"bad key": "x", "cost-center": "42"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, other)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, other)).To(Succeed())
+				reconcileNamespace()
+			}()
+			reconcileNamespace()
+
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+			Expect(namespaceLabels()).To(HaveKeyWithValue("cost-center", "42"))
+			Expect(namespaceLabels()).NotTo(HaveKey("bad key"))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "invalid", Namespace: namespace}, other)).To(Succeed())
+			cond := meta.FindStatusCondition(other.Status.Conditions, danateamv1.ConditionReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Reason).To(Equal(danateamv1.ReasonInvalidLabel))
+			Expect(cond.Message).To(ContainSubstring("bad key"))
+		})
+
+		It("should report a rejected namespace write on the NamespaceLabels that changed it", func() {
+			reconcileNamespace()
+			other := &danateamv1.NamespaceLabel{
+				ObjectMeta: metav1.ObjectMeta{Name: "rejected", Namespace: namespace},
+				Spec:       danateamv1.NamespaceLabelSpec{Labels: map[string]string{"cost-center": "42"}},
+			}
+			Expect(k8sClient.Create(ctx, other)).To(Succeed())
+			defer func() {
+				controllerReconciler.Client = k8sClient
+				Expect(k8sClient.Delete(ctx, other)).To(Succeed())
+				reconcileNamespace()
+			}()
+
+			controllerReconciler.Client = &rejectingClient{Client: k8sClient}
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
-				NamespacedName: typeNamespacedName,
+				NamespacedName: types.NamespacedName{Name: namespace},
 			})
-			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+			Expect(errors.IsInvalid(err)).To(BeTrue())
+			Expect(goerrors.Is(err, reconcile.TerminalError(nil))).To(BeTrue())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "rejected", Namespace: namespace}, other)).To(Succeed())
+			cond := meta.FindStatusCondition(other.Status.Conditions, danateamv1.ConditionReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Reason).To(Equal(danateamv1.ReasonError))
+			resource := &danateamv1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, danateamv1.ConditionReady)).To(BeTrue())
+			Expect(recorder.Events).To(Receive(ContainSubstring(EventReasonWriteRejected)))
+		})
+
+		It("should keep immutable labels until they are unlocked", func() {
+			resource := &danateamv1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Immutable = []string{"tier"}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileNamespace()
+
+			By("changing and no longer listing the immutable label")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.ImmutableLabels).To(Equal([]string{"tier"}))
+			resource.Spec.Labels["tier"] = "silver"
+			resource.Spec.Immutable = nil
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileNamespace()
+
+			Expect(namespaceLabels()).To(HaveKeyWithValue("tier", "gold"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			cond := meta.FindStatusCondition(resource.Status.Conditions, danateamv1.ConditionReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Reason).To(Equal(danateamv1.ReasonImmutable))
+			Expect(resource.Status.ImmutableLabels).To(Equal([]string{"tier"}))
+
+			By("unlocking the label on the namespace")
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: namespace}, ns)).To(Succeed())
+			ns.Annotations[danateamv1.UnlockImmutableLabelsAnnotation] = "tier"
+			Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+			reconcileNamespace()
+
+			Expect(namespaceLabels()).To(HaveKeyWithValue("tier", "silver"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, danateamv1.ConditionReady)).To(BeTrue())
+			Expect(resource.Status.ImmutableLabels).To(BeEmpty())
+		})
+
+		It("should keep immutable labels locked when their NamespaceLabel is recreated", func() {
+			resource := &danateamv1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Immutable = []string{"tier"}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileNamespace()
+
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: namespace}, ns)).To(Succeed())
+			Expect(ns.Annotations).To(HaveKeyWithValue(danateamv1.ImmutableLabelsAnnotation, `{"tier":"gold"}`))
+
+			By("deleting the NamespaceLabel")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			reconcileNamespace()
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, resource))).To(BeTrue())
+			Expect(namespaceLabels()).To(HaveKeyWithValue("tier", "gold"))
+
+			By("recreating it with another value for the locked key")
+			resource = &danateamv1.NamespaceLabel{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: namespace},
+				Spec:       danateamv1.NamespaceLabelSpec{Labels: map[string]string{"tier": "silver"}},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			reconcileNamespace()
+
+			Expect(namespaceLabels()).To(HaveKeyWithValue("tier", "gold"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			cond := meta.FindStatusCondition(resource.Status.Conditions, danateamv1.ConditionReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Reason).To(Equal(danateamv1.ReasonImmutable))
+
+			By("unlocking the label on the namespace")
+			ns = &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: namespace}, ns)).To(Succeed())
+			ns.Annotations[danateamv1.UnlockImmutableLabelsAnnotation] = "tier"
+			Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+			reconcileNamespace()
+
+			Expect(namespaceLabels()).To(HaveKeyWithValue("tier", "silver"))
+			ns = &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: namespace}, ns)).To(Succeed())
+			Expect(ns.Annotations).NotTo(HaveKey(danateamv1.ImmutableLabelsAnnotation))
+		})
+
+		It("should apply the labels derived from the namespace name", func() {
+			resource := &danateamv1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.NameRules = []danateamv1.NameRule{{
+				Pattern: "(?P<prefix>nslabel)-[a-z0-9]+",
+				Labels: []danateamv1.DerivedLabel{
+					{Key: "prefix", Group: "prefix"},
+					{Key: "team", Group: "prefix", Values: map[string]string{"nslabel": "derived"}},
+				},
+			}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileNamespace()
+
+			By("preferring the labels of the spec over derived labels")
+			Expect(namespaceLabels()).To(HaveKeyWithValue("prefix", "nslabel"))
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.DerivedLabels).To(Equal(map[string]string{"prefix": "nslabel", "team": "derived"}))
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, danateamv1.ConditionNameMatched)).To(BeTrue())
+
+			By("reporting a namespace name no rule matches")
+			resource.Spec.NameRules[0].Pattern = "(?P<prefix>other)-[a-z0-9]+"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileNamespace()
+
+			Expect(namespaceLabels()).NotTo(HaveKey("prefix"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.DerivedLabels).To(BeEmpty())
+			cond := meta.FindStatusCondition(resource.Status.Conditions, danateamv1.ConditionNameMatched)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Reason).To(Equal(danateamv1.ReasonNoMatch))
+		})
+
+		It("should inherit labels from the parent namespaces", func() {
+			controllerReconciler.Inheritance = config.InheritanceConfig{
+				Labels: []string{"team", "cost-center"}, ParentKey: config.DefaultParentKey, MaxDepth: 10,
+			}
+			root := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "nslabel-root-", Labels: map[string]string{"team": "search", "cost-center": "1234"},
+			}}
+			Expect(k8sClient.Create(ctx, root)).To(Succeed())
+			parent := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "nslabel-parent-", Annotations: map[string]string{config.DefaultParentKey: root.Name},
+			}}
+			Expect(k8sClient.Create(ctx, parent)).To(Succeed())
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: namespace}, ns)).To(Succeed())
+			ns.Labels[config.DefaultParentKey] = parent.Name
+			Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+
+			By("reconciling the namespace and its ancestors")
+			Expect(controllerReconciler.namespaceAndDescendants(ctx, root)).To(ContainElement(
+				reconcile.Request{NamespacedName: types.NamespacedName{Name: namespace}}))
+			reconcileNamespace()
+			Expect(namespaceLabels()).To(HaveKeyWithValue("cost-center", "1234"))
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: namespace}, ns)).To(Succeed())
+			Expect(ns.Annotations[danateamv1.ManagedLabelsAnnotation]).To(ContainSubstring(
+				`"cost-center":"` + danateamv1.InheritedOwner + `"`))
+
+			By("removing the inherited labels once the namespace has no parent")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: namespace}, ns)).To(Succeed())
+			delete(ns.Labels, config.DefaultParentKey)
+			Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+			reconcileNamespace()
+			Expect(namespaceLabels()).NotTo(HaveKey("cost-center"))
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+		})
+
+		It("should ignore NamespaceLabels in excluded namespaces", func() {
+			controllerReconciler.Policy = config.NewPolicyStore(config.Policy{ExcludedNamespaces: []string{namespace}})
+
+			reconcileNamespace()
+			Expect(namespaceLabels()).NotTo(HaveKey("team"))
+
+			resource := &danateamv1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Finalizers).To(BeEmpty())
+		})
+
+		It("should only record events on the NamespaceLabel when limited to namespaces", func() {
+			controllerReconciler.Namespaces = []string{namespace}
+			reconcileNamespace()
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+
+			Expect(recorder.Events).To(Receive(Equal(`Normal LabelApplied Set label "team" to "platform"`)))
+			Expect(recorder.Events).To(Receive(Equal(`Normal LabelApplied Set label "tier" to "gold"`)))
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should only manage namespaces in its scope", func() {
+			By("limiting the reconciler to other namespaces")
+			controllerReconciler.Namespaces = []string{"somewhere-else"}
+			reconcileNamespace()
+			Expect(namespaceLabels()).NotTo(HaveKey("team"))
+
+			By("limiting the reconciler to namespaces with a label")
+			controllerReconciler.Namespaces = nil
+			controllerReconciler.NamespaceSelector = apilabels.SelectorFromSet(apilabels.Set{"managed-by": "platform"})
+			reconcileNamespace()
+			Expect(namespaceLabels()).NotTo(HaveKey("team"))
+
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: namespace}, ns)).To(Succeed())
+			if ns.Labels == nil {
+				ns.Labels = map[string]string{}
+			}
+			ns.Labels["managed-by"] = "platform"
+			Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+			reconcileNamespace()
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+		})
+
+		It("should only manage namespaces of its shard", func() {
+			const shards = 4
+			index := sharding.Of(namespace, shards)
+			controllerReconciler.Shard = sharding.Static{Index: (index + 1) % shards, Shards: shards}
+			reconcileNamespace()
+			Expect(namespaceLabels()).NotTo(HaveKey("team"))
+
+			controllerReconciler.Shard = sharding.Static{Index: index, Shards: shards}
+			reconcileNamespace()
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+		})
+
+		It("should write an audit record for every mutation", func() {
+			var trail bytes.Buffer
+			key := []byte("0123456789abcdef0123456789abcdef")
+			controllerReconciler.Audit = audit.New(key, audit.DefaultQueueSize, audit.NewWriterSink(&trail))
+
+			reconcileNamespace()
+			resource := &danateamv1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			reconcileNamespace()
+			Expect(controllerReconciler.Audit.Close()).To(Succeed())
+			controllerReconciler.Audit = nil
+
+			Expect(audit.Verify(bytes.NewReader(trail.Bytes()), key, "")).To(Succeed())
+			lines := strings.Split(strings.TrimSpace(trail.String()), "\n")
+			Expect(lines).To(HaveLen(2))
+
+			var applied, cleaned audit.Record
+			Expect(json.Unmarshal([]byte(lines[0]), &applied)).To(Succeed())
+			Expect(applied.Namespace).To(Equal(namespace))
+			Expect(applied.NamespaceLabel).To(Equal(resourceName))
+			Expect(applied.Reason).To(Equal(audit.ReasonSpecChange))
+			Expect(applied.Before).NotTo(HaveKey("team"))
+			Expect(applied.After).To(HaveKeyWithValue("team", "platform"))
+
+			Expect(json.Unmarshal([]byte(lines[1]), &cleaned)).To(Succeed())
+			Expect(cleaned.Reason).To(Equal(audit.ReasonCleanup))
+			Expect(cleaned.After).NotTo(HaveKey("team"))
+		})
+
+		It("should remove its labels when deleted", func() {
+			reconcileNamespace()
+
+			resource := &danateamv1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			reconcileNamespace()
+
+			Expect(namespaceLabels()).NotTo(HaveKey("team"))
+			Expect(namespaceLabels()).NotTo(HaveKey("tier"))
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, resource))).To(BeTrue())
 		})
 	})
 })
+
+// patchCounter counts the Namespace patches sent through it.
+type patchCounter struct {
+	client.Client
+	patches int
+}
+
+func (c *patchCounter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if _, ok := obj.(*corev1.Namespace); ok {
+		c.patches++
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+// rejectingClient fails every Namespace patch as the API server does for
+// invalid labels.
+type rejectingClient struct {
+	client.Client
+}
+
+func (c *rejectingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if _, ok := obj.(*danateamv1.NamespaceLabel); ok {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	return errors.NewInvalid(corev1.SchemeGroupVersion.WithKind("Namespace").GroupKind(), obj.GetName(), nil)
+}
+
+// trimmingClient returns the NamespaceLabels it reads as the manager's cache
+// does, with the fields dropped by cachetrim.
+type trimmingClient struct {
+	client.Client
+}
+
+func (c *trimmingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	if nls, ok := list.(*danateamv1.NamespaceLabelList); ok {
+		for i := range nls.Items {
+			_, _ = cachetrim.Transform(&nls.Items[i])
+		}
+	}
+	return nil
+}
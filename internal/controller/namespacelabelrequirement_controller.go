@@ -0,0 +1,535 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apilabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/internal/audit"
+	"github.com/matanamar10/namesapcelabel/internal/config"
+	"github.com/matanamar10/namesapcelabel/internal/metrics"
+	"github.com/matanamar10/namesapcelabel/internal/tracing"
+	"github.com/matanamar10/namesapcelabel/pkg/namerule"
+)
+
+// RequirementControllerName is the name of the NamespaceLabelRequirement controller.
+const RequirementControllerName = "namespacelabelrequirement"
+
+// Reasons of the events recorded on NamespaceLabelRequirements and Namespaces.
+const (
+	EventReasonDefaultApplied = "DefaultApplied"
+	EventReasonLabelDerived   = "LabelDerived"
+	EventReasonInvalidSpec    = "InvalidSpec"
+)
+
+// NamespaceLabelRequirementReconciler reports the Namespaces that do not
+// carry the labels required by a NamespaceLabelRequirement, and fills in
+// the default values of missing labels, and the labels derived from their
+// names, when asked to.
+type NamespaceLabelRequirementReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// Audit receives a record of every default written. Auditing is disabled when nil.
+	Audit *audit.Auditor
+	// Policy holds the policy restricting the namespaces and labels defaults
+	// are written to. The default policy is used when nil.
+	Policy *config.PolicyStore
+	// QueueTimer times the wait of requests in the workqueue, which is then
+	// recorded on reconcile spans. Optional.
+	QueueTimer *tracing.QueueTimer
+}
+
+// +kubebuilder:rbac:groups=danateam.namespacelabel.io,resources=namespacelabelrequirements,verbs=get;list;watch
+// +kubebuilder:rbac:groups=danateam.namespacelabel.io,resources=namespacelabelrequirements/status,verbs=get;update;patch
+
+// Reconcile evaluates a NamespaceLabelRequirement against every Namespace it
+// selects, writes the defaults and derived values of missing labels if the
+// requirement has AutoFill set, and reports the result in its status and metrics.
+func (r *NamespaceLabelRequirementReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "NamespaceLabelRequirement.Reconcile", trace.WithAttributes(
+		tracing.AttrNamespaceLabelRequirement.String(req.Name),
+	))
+	if wait, ok := r.QueueTimer.Waited(req); ok {
+		span.SetAttributes(tracing.AttrQueueWait.Float64(wait.Seconds()))
+	}
+	result, err := r.reconcile(ctx, req)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (r *NamespaceLabelRequirementReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	nlr := &danateamv1.NamespaceLabelRequirement{}
+	if err := r.Get(ctx, req.NamespacedName, nlr); err != nil {
+		if apierrors.IsNotFound(err) {
+			metrics.ForgetRequirement(req.Name)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !nlr.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	orig := nlr.DeepCopy()
+
+	selector, required, rules, err := compileRequirement(&nlr.Spec)
+	if err != nil {
+		metrics.ForgetRequirement(nlr.Name)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(nlr, corev1.EventTypeWarning, EventReasonInvalidSpec, "%v", err)
+		}
+		nlr.Status.ObservedGeneration = nlr.Generation
+		nlr.Status.MatchedNamespaces, nlr.Status.NonCompliantNamespaces, nlr.Status.NonCompliant = 0, 0, nil
+		nlr.Status.UnmatchedNamespaces, nlr.Status.Unmatched = 0, nil
+		meta.RemoveStatusCondition(&nlr.Status.Conditions, danateamv1.ConditionNameMatched)
+		meta.SetStatusCondition(&nlr.Status.Conditions, metav1.Condition{
+			Type:               danateamv1.ConditionCompliant,
+			Status:             metav1.ConditionUnknown,
+			ObservedGeneration: nlr.Generation,
+			Reason:             danateamv1.ReasonInvalidSpec,
+			Message:            err.Error(),
+		})
+		// Retrying cannot fix the spec; the next change to it is reconciled.
+		return ctrl.Result{}, r.patchStatus(ctx, orig, nlr)
+	}
+
+	list := &metav1.PartialObjectMetadataList{}
+	list.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("NamespaceList"))
+	if err := r.List(ctx, list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return ctrl.Result{}, err
+	}
+	namespaces := list.Items
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Name < namespaces[j].Name })
+
+	policy, _ := r.Policy.Load()
+	var fillErrs []error
+	result := compliance{violations: map[violation]int{}}
+	for i := range namespaces {
+		ns := &namespaces[i]
+		if !ns.DeletionTimestamp.IsZero() {
+			continue
+		}
+		derived, matched, err := rules.Derive(ns.Name)
+		if rules != nil && (!matched || err != nil) {
+			result.unmatched = append(result.unmatched, ns.Name)
+		}
+		missing, invalid := required.check(ns.Labels)
+		values := fillValues(ns.Labels, required, missing, derived)
+		if nlr.Spec.AutoFill && len(values) > 0 && !policy.Excludes(ns.Name) {
+			if err := r.fill(ctx, nlr, ns, values, derived, policy); err != nil {
+				fillErrs = append(fillErrs, fmt.Errorf("namespace %s: %w", ns.Name, err))
+			} else {
+				missing, invalid = required.check(ns.Labels)
+			}
+		}
+		result.add(ns.Name, missing, invalid)
+	}
+	fillErr := errors.Join(fillErrs...)
+
+	nlr.Status.ObservedGeneration = nlr.Generation
+	nlr.Status.MatchedNamespaces = int32(result.matched)
+	nlr.Status.NonCompliantNamespaces = int32(len(result.nonCompliant))
+	nlr.Status.NonCompliant = result.nonCompliant
+	if len(nlr.Status.NonCompliant) > danateamv1.MaxNonCompliantListed {
+		nlr.Status.NonCompliant = nlr.Status.NonCompliant[:danateamv1.MaxNonCompliantListed]
+	}
+	nlr.Status.UnmatchedNamespaces = int32(len(result.unmatched))
+	nlr.Status.Unmatched = result.unmatched
+	if len(nlr.Status.Unmatched) > danateamv1.MaxNonCompliantListed {
+		nlr.Status.Unmatched = nlr.Status.Unmatched[:danateamv1.MaxNonCompliantListed]
+	}
+	meta.SetStatusCondition(&nlr.Status.Conditions, result.condition(nlr.Generation, fillErr))
+	if rules != nil {
+		meta.SetStatusCondition(&nlr.Status.Conditions, result.nameCondition(nlr.Generation))
+	} else {
+		meta.RemoveStatusCondition(&nlr.Status.Conditions, danateamv1.ConditionNameMatched)
+	}
+	result.record(nlr.Name)
+
+	if err := r.patchStatus(ctx, orig, nlr); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, fillErr
+}
+
+// fillValues returns the values to fill in on a Namespace carrying labels:
+// the defaults of the missing required keys, and the derived labels it does
+// not carry. A derived value takes precedence over a default.
+func fillValues(labels map[string]string, required requiredLabels, missing []string,
+	derived map[string]string) map[string]string {
+	values := map[string]string{}
+	for _, key := range missing {
+		if value := required.defaultOf(key); value != "" {
+			values[key] = value
+		}
+	}
+	for key, value := range derived {
+		if _, ok := labels[key]; !ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// fill writes the values that the policy allows to ns, and records nlr as
+// their owner in the danateamv1.ManagedLabelsAnnotation. Keys owned by a
+// NamespaceLabel or inherited are left to their own controller. Derived
+// values are reported as such.
+func (r *NamespaceLabelRequirementReconciler) fill(ctx context.Context, nlr *danateamv1.NamespaceLabelRequirement,
+	ns *metav1.PartialObjectMetadata, values, derived map[string]string, policy config.Policy) error {
+	// Listed objects do not carry their type; patches and events need it.
+	ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+	owners, err := managedLabels(ns)
+	if err != nil {
+		return err
+	}
+	owner := danateamv1.RequirementOwnerPrefix + nlr.Name
+	before := copyMap(ns.Labels)
+	orig := ns.DeepCopy()
+	var filled []string
+	for _, key := range sortedKeys(values) {
+		value := values[key]
+		if _, denied := policy.Denies(ns.Name, key); denied {
+			continue
+		}
+		if current, ok := owners[key]; ok && current != owner {
+			continue
+		}
+		if ns.Labels == nil {
+			ns.Labels = map[string]string{}
+		}
+		ns.Labels[key] = value
+		owners[key] = owner
+		filled = append(filled, key)
+	}
+	if len(filled) == 0 {
+		return nil
+	}
+	managed, err := json.Marshal(owners)
+	if err != nil {
+		return err
+	}
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[danateamv1.ManagedLabelsAnnotation] = string(managed)
+
+	// The ownership annotation is shared with the NamespaceLabel controller,
+	// so it is only written over the version it was read from.
+	if err := r.Patch(ctx, ns, client.MergeFromWithOptions(orig, client.MergeFromWithOptimisticLock{})); err != nil {
+		ns.Labels, ns.Annotations = orig.Labels, orig.Annotations
+		return err
+	}
+	metrics.RequirementDefaultsApplied.WithLabelValues(nlr.Name).Add(float64(len(filled)))
+	if r.Recorder != nil {
+		for _, key := range filled {
+			reason := EventReasonDefaultApplied
+			message := fmt.Sprintf("Set missing label %q to its default %q", key, ns.Labels[key])
+			if value, ok := derived[key]; ok && value == ns.Labels[key] {
+				reason = EventReasonLabelDerived
+				message = fmt.Sprintf("Set missing label %q to %q derived from the namespace name", key, value)
+			}
+			r.Recorder.Eventf(nlr, corev1.EventTypeNormal, reason, "Namespace %s: %s", ns.Name, message)
+			r.Recorder.Eventf(ns, corev1.EventTypeNormal, reason,
+				"NamespaceLabelRequirement %s: %s", nlr.Name, message)
+		}
+	}
+	if r.Audit != nil {
+		err := r.Audit.Record(ctx, audit.Record{
+			Namespace:   ns.Name,
+			Requirement: nlr.Name,
+			Generation:  nlr.Generation,
+			Reason:      audit.ReasonRequirementDefault,
+			Before:      before,
+			After:       copyMap(ns.Labels),
+		})
+		if err != nil {
+			log.FromContext(ctx).Error(err, "unable to write audit record", "namespace", ns.Name)
+		}
+	}
+	return nil
+}
+
+func (r *NamespaceLabelRequirementReconciler) patchStatus(ctx context.Context,
+	orig, nlr *danateamv1.NamespaceLabelRequirement) error {
+	if equality.Semantic.DeepEqual(orig.Status, nlr.Status) {
+		return nil
+	}
+	return r.Status().Patch(ctx, nlr, client.MergeFrom(orig))
+}
+
+// requiredLabel is a danateamv1.RequiredLabel with its pattern compiled.
+type requiredLabel struct {
+	key          string
+	pattern      *regexp.Regexp
+	defaultValue string
+}
+
+type requiredLabels []requiredLabel
+
+// check returns the required keys labels does not carry, and those whose
+// value does not match the pattern.
+func (rl requiredLabels) check(labels map[string]string) (missing, invalid []string) {
+	for _, l := range rl {
+		value, ok := labels[l.key]
+		switch {
+		case !ok:
+			missing = append(missing, l.key)
+		case l.pattern != nil && !l.pattern.MatchString(value):
+			invalid = append(invalid, l.key)
+		}
+	}
+	return missing, invalid
+}
+
+func (rl requiredLabels) defaultOf(key string) string {
+	for _, l := range rl {
+		if l.key == key {
+			return l.defaultValue
+		}
+	}
+	return ""
+}
+
+// compileRequirement returns the selector, the required labels and the name
+// rules of spec, or an error describing everything that is invalid in it.
+// The rules are nil when spec has none.
+func compileRequirement(spec *danateamv1.NamespaceLabelRequirementSpec) (apilabels.Selector, requiredLabels,
+	*namerule.Rules, error) {
+	var problems []string
+	selector := apilabels.Everything()
+	if spec.NamespaceSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(spec.NamespaceSelector); err != nil {
+			problems = append(problems, fmt.Sprintf("namespaceSelector: %v", err))
+		}
+	}
+
+	required := make(requiredLabels, 0, len(spec.Labels))
+	for _, l := range spec.Labels {
+		for _, msg := range validation.IsQualifiedName(l.Key) {
+			problems = append(problems, fmt.Sprintf("label %q: %s", l.Key, msg))
+		}
+		rl := requiredLabel{key: l.Key, defaultValue: l.Default}
+		if l.Pattern != "" {
+			if _, err := regexp.Compile(l.Pattern); err != nil {
+				problems = append(problems, fmt.Sprintf("label %q: invalid pattern: %v", l.Key, err))
+				continue
+			}
+			// The pattern must match the whole value.
+			rl.pattern = regexp.MustCompile("^(?:" + l.Pattern + ")$")
+		}
+		if l.Default != "" {
+			for _, msg := range validation.IsValidLabelValue(l.Default) {
+				problems = append(problems, fmt.Sprintf("label %q: invalid default: %s", l.Key, msg))
+			}
+			if rl.pattern != nil && !rl.pattern.MatchString(l.Default) {
+				problems = append(problems, fmt.Sprintf("label %q: default %q does not match the pattern", l.Key, l.Default))
+			}
+		}
+		required = append(required, rl)
+	}
+	var rules *namerule.Rules
+	if len(spec.NameRules) > 0 {
+		var errs field.ErrorList
+		rules, errs = namerule.Compile(spec.NameRules, field.NewPath("spec", "nameRules"))
+		for _, err := range errs {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return nil, nil, nil, errors.New(strings.Join(problems, "; "))
+	}
+	return selector, required, rules, nil
+}
+
+// violation is a label key some Namespaces miss or carry an invalid value of.
+type violation struct {
+	key, reason string
+}
+
+// compliance accumulates the evaluation of a requirement.
+type compliance struct {
+	matched      int
+	nonCompliant []danateamv1.NonCompliantNamespace
+	violations   map[violation]int
+	// unmatched are the Namespaces whose name no name rule derives labels from.
+	unmatched []string
+}
+
+func (c *compliance) add(namespace string, missing, invalid []string) {
+	c.matched++
+	if len(missing) == 0 && len(invalid) == 0 {
+		return
+	}
+	c.nonCompliant = append(c.nonCompliant, danateamv1.NonCompliantNamespace{
+		Namespace: namespace,
+		Missing:   missing,
+		Invalid:   invalid,
+	})
+	for _, key := range missing {
+		c.violations[violation{key, metrics.ViolationMissing}]++
+	}
+	for _, key := range invalid {
+		c.violations[violation{key, metrics.ViolationInvalid}]++
+	}
+}
+
+// condition returns the Compliant condition describing c.
+func (c *compliance) condition(generation int64, fillErr error) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               danateamv1.ConditionCompliant,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             danateamv1.ReasonCompliant,
+		Message:            fmt.Sprintf("all %d selected namespaces carry the required labels", c.matched),
+	}
+	switch {
+	case fillErr != nil:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = danateamv1.ReasonError
+		cond.Message = fmt.Sprintf("writing defaults: %v", fillErr)
+	case len(c.nonCompliant) > 0:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = danateamv1.ReasonNonCompliant
+		cond.Message = fmt.Sprintf("%d of %d selected namespaces miss required labels or have invalid values",
+			len(c.nonCompliant), c.matched)
+	}
+	return cond
+}
+
+// nameCondition returns the NameMatched condition describing c.
+func (c *compliance) nameCondition(generation int64) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               danateamv1.ConditionNameMatched,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: generation,
+		Reason:             danateamv1.ReasonNameMatched,
+		Message:            fmt.Sprintf("the names of all %d selected namespaces match a name rule", c.matched),
+	}
+	if len(c.unmatched) > 0 {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = danateamv1.ReasonNoMatch
+		cond.Message = fmt.Sprintf("%d of %d selected namespaces match no name rule or derive an invalid value",
+			len(c.unmatched), c.matched)
+	}
+	return cond
+}
+
+// record exports c as the metrics of the requirement name.
+func (c *compliance) record(name string) {
+	metrics.RequirementNamespaces.WithLabelValues(name).Set(float64(c.matched))
+	metrics.RequirementNonCompliant.WithLabelValues(name).Set(float64(len(c.nonCompliant)))
+	// Keys that are no longer violated, or no longer required, lose their series.
+	metrics.RequirementViolations.DeletePartialMatch(map[string]string{"requirement": name})
+	for v, count := range c.violations {
+		metrics.RequirementViolations.WithLabelValues(name, v.key, v.reason).Set(float64(count))
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager. Every
+// NamespaceLabelRequirement is evaluated again when the labels of a
+// Namespace change.
+func (r *NamespaceLabelRequirementReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	var opts controller.Options
+	if r.QueueTimer != nil {
+		opts.NewQueue = r.QueueTimer.NewQueue
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(RequirementControllerName).
+		WithOptions(opts).
+		For(&danateamv1.NamespaceLabelRequirement{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WatchesMetadata(&corev1.Namespace{},
+			r.namespaceHandler(),
+			builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Complete(r)
+}
+
+// namespaceHandler enqueues the NamespaceLabelRequirements that select a
+// Namespace, before or after a change of its labels, so that requirements
+// are only evaluated again when one of their namespaces changes.
+func (r *NamespaceLabelRequirementReconciler) namespaceHandler() handler.EventHandler {
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			r.enqueueSelecting(ctx, q, e.Object)
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			r.enqueueSelecting(ctx, q, e.ObjectOld, e.ObjectNew)
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			r.enqueueSelecting(ctx, q, e.Object)
+		},
+		GenericFunc: func(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			r.enqueueSelecting(ctx, q, e.Object)
+		},
+	}
+}
+
+// enqueueSelecting adds the NamespaceLabelRequirements whose namespace
+// selector matches any of namespaces to q. Requirements with an invalid
+// selector do not depend on namespaces and are left out.
+func (r *NamespaceLabelRequirementReconciler) enqueueSelecting(ctx context.Context,
+	q workqueue.TypedRateLimitingInterface[reconcile.Request], namespaces ...client.Object) {
+	list := &danateamv1.NamespaceLabelRequirementList{}
+	if err := r.List(ctx, list); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list NamespaceLabelRequirements")
+		return
+	}
+	for i := range list.Items {
+		nlr := &list.Items[i]
+		selector := apilabels.Everything()
+		if nlr.Spec.NamespaceSelector != nil {
+			var err error
+			if selector, err = metav1.LabelSelectorAsSelector(nlr.Spec.NamespaceSelector); err != nil {
+				continue
+			}
+		}
+		for _, ns := range namespaces {
+			if selector.Matches(apilabels.Set(ns.GetLabels())) {
+				q.Add(reconcile.Request{NamespacedName: types.NamespacedName{Name: nlr.Name}})
+				break
+			}
+		}
+	}
+}
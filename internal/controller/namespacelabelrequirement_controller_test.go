@@ -0,0 +1,228 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/internal/config"
+)
+
+var _ = Describe("NamespaceLabelRequirement Controller", func() {
+	Context("When evaluating a requirement", func() {
+		ctx := context.Background()
+
+		var requirement *danateamv1.NamespaceLabelRequirement
+		var requirementReconciler *NamespaceLabelRequirementReconciler
+		var recorder *record.FakeRecorder
+		var compliant, missing, protected string
+
+		createNamespace := func(labels map[string]string) string {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "nslabel-req-", Labels: labels}}
+			ExpectWithOffset(1, k8sClient.Create(ctx, ns)).To(Succeed())
+			return ns.Name
+		}
+
+		reconcileRequirement := func() *danateamv1.NamespaceLabelRequirement {
+			_, err := requirementReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: requirement.Name},
+			})
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+			nlr := &danateamv1.NamespaceLabelRequirement{}
+			ExpectWithOffset(1, k8sClient.Get(ctx, types.NamespacedName{Name: requirement.Name}, nlr)).To(Succeed())
+			return nlr
+		}
+
+		namespaceLabels := func(name string) map[string]string {
+			ns := &corev1.Namespace{}
+			ExpectWithOffset(1, k8sClient.Get(ctx, types.NamespacedName{Name: name}, ns)).To(Succeed())
+			return ns.Labels
+		}
+
+		BeforeEach(func() {
+			// Namespaces of other tests are left out by the selector.
+			selected := map[string]string{"requirement-test": "true"}
+			compliant = createNamespace(map[string]string{
+				"requirement-test": "true", "team": "platform", "cost-center": "1234", "environment": "prod",
+			})
+			missing = createNamespace(map[string]string{"requirement-test": "true", "cost-center": "12"})
+			protected = createNamespace(map[string]string{"requirement-test": "true", "team": "search", "cost-center": "4321"})
+
+			recorder = record.NewFakeRecorder(100)
+			requirementReconciler = &NamespaceLabelRequirementReconciler{
+				Client:   k8sClient,
+				Recorder: recorder,
+				Policy:   config.NewPolicyStore(config.Policy{ProtectedNamespaces: []string{protected}}),
+			}
+
+			requirement = &danateamv1.NamespaceLabelRequirement{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "governance-"},
+				Spec: danateamv1.NamespaceLabelRequirementSpec{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: selected},
+					Labels: []danateamv1.RequiredLabel{
+						{Key: "team"},
+						{Key: "cost-center", Pattern: "[0-9]{4}"},
+						{Key: "environment", Pattern: "dev|staging|prod", Default: "dev"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, requirement)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, requirement)).To(Succeed())
+		})
+
+		It("should report the namespaces missing required labels", func() {
+			nlr := reconcileRequirement()
+
+			Expect(nlr.Status.MatchedNamespaces).To(Equal(int32(3)))
+			Expect(nlr.Status.NonCompliantNamespaces).To(Equal(int32(2)))
+			Expect(nlr.Status.NonCompliant).To(ContainElements(
+				danateamv1.NonCompliantNamespace{
+					Namespace: missing, Missing: []string{"team", "environment"}, Invalid: []string{"cost-center"},
+				},
+				danateamv1.NonCompliantNamespace{Namespace: protected, Missing: []string{"environment"}},
+			))
+			Expect(nlr.Status.NonCompliant).NotTo(ContainElement(HaveField("Namespace", compliant)))
+			cond := meta.FindStatusCondition(nlr.Status.Conditions, danateamv1.ConditionCompliant)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(danateamv1.ReasonNonCompliant))
+			Expect(namespaceLabels(missing)).NotTo(HaveKey("environment"))
+		})
+
+		It("should fill in defaults the policy allows when asked to", func() {
+			requirement.Spec.AutoFill = true
+			Expect(k8sClient.Update(ctx, requirement)).To(Succeed())
+			nlr := reconcileRequirement()
+
+			Expect(namespaceLabels(missing)).To(HaveKeyWithValue("environment", "dev"))
+			Expect(namespaceLabels(protected)).NotTo(HaveKey("environment"))
+			Expect(nlr.Status.NonCompliant).To(ContainElements(
+				danateamv1.NonCompliantNamespace{
+					Namespace: missing, Missing: []string{"team"}, Invalid: []string{"cost-center"},
+				},
+				danateamv1.NonCompliantNamespace{Namespace: protected, Missing: []string{"environment"}},
+			))
+			Expect(recorder.Events).To(Receive(ContainSubstring(EventReasonDefaultApplied)))
+		})
+
+		It("should record itself as the owner of the labels it fills in", func() {
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: protected}, ns)).To(Succeed())
+			ns.Annotations = map[string]string{danateamv1.ManagedLabelsAnnotation: `{"environment":"labels"}`}
+			Expect(k8sClient.Update(ctx, ns)).To(Succeed())
+			requirementReconciler.Policy = config.NewPolicyStore(config.Policy{})
+			requirement.Spec.AutoFill = true
+			Expect(k8sClient.Update(ctx, requirement)).To(Succeed())
+			reconcileRequirement()
+
+			filled := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: missing}, filled)).To(Succeed())
+			Expect(filled.Labels).To(HaveKeyWithValue("environment", "dev"))
+			owners := map[string]string{}
+			Expect(json.Unmarshal([]byte(filled.Annotations[danateamv1.ManagedLabelsAnnotation]), &owners)).To(Succeed())
+			Expect(owners).To(Equal(map[string]string{
+				"environment": danateamv1.RequirementOwnerPrefix + requirement.Name,
+			}))
+
+			By("leaving keys owned by a NamespaceLabel to it")
+			Expect(namespaceLabels(protected)).NotTo(HaveKey("environment"))
+		})
+
+		It("should only requeue the requirements selecting a changed namespace", func() {
+			other := &danateamv1.NamespaceLabelRequirement{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "other-"},
+				Spec: danateamv1.NamespaceLabelRequirementSpec{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"requirement-test": "other"}},
+					Labels:            []danateamv1.RequiredLabel{{Key: "team"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, other)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, other)).To(Succeed()) }()
+
+			q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+			defer q.ShutDown()
+			ns := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{
+				Name: missing, Labels: map[string]string{"requirement-test": "true"},
+			}}
+			requirementReconciler.enqueueSelecting(ctx, q, ns)
+
+			var names []string
+			for q.Len() > 0 {
+				req, _ := q.Get()
+				names = append(names, req.Name)
+				q.Done(req)
+			}
+			Expect(names).To(ContainElement(requirement.Name))
+			Expect(names).NotTo(ContainElement(other.Name))
+		})
+
+		It("should fill in labels derived from namespace names", func() {
+			other := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "nslabel-other-", Labels: map[string]string{"requirement-test": "true"},
+			}}
+			Expect(k8sClient.Create(ctx, other)).To(Succeed())
+			requirement.Spec.AutoFill = true
+			requirement.Spec.NameRules = []danateamv1.NameRule{{
+				Pattern: "nslabel-(?P<kind>req)-[a-z0-9]+",
+				Labels: []danateamv1.DerivedLabel{
+					{Key: "kind", Group: "kind", Values: map[string]string{"req": "requirement"}},
+				},
+			}}
+			Expect(k8sClient.Update(ctx, requirement)).To(Succeed())
+			nlr := reconcileRequirement()
+
+			Expect(namespaceLabels(missing)).To(HaveKeyWithValue("kind", "requirement"))
+			Expect(namespaceLabels(protected)).NotTo(HaveKey("kind"))
+			Expect(namespaceLabels(other.Name)).NotTo(HaveKey("kind"))
+			Expect(nlr.Status.UnmatchedNamespaces).To(Equal(int32(1)))
+			Expect(nlr.Status.Unmatched).To(Equal([]string{other.Name}))
+			cond := meta.FindStatusCondition(nlr.Status.Conditions, danateamv1.ConditionNameMatched)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(danateamv1.ReasonNoMatch))
+			Expect(recorder.Events).To(Receive(ContainSubstring(EventReasonLabelDerived)))
+		})
+
+		It("should report an invalid pattern", func() {
+			requirement.Spec.Labels[1].Pattern = "[0-9"
+			Expect(k8sClient.Update(ctx, requirement)).To(Succeed())
+			nlr := reconcileRequirement()
+
+			cond := meta.FindStatusCondition(nlr.Status.Conditions, danateamv1.ConditionCompliant)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionUnknown))
+			Expect(cond.Reason).To(Equal(danateamv1.ReasonInvalidSpec))
+			Expect(cond.Message).To(ContainSubstring(`label "cost-center": invalid pattern`))
+			Expect(nlr.Status.NonCompliant).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,185 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/internal/config"
+	"github.com/matanamar10/namesapcelabel/internal/metrics"
+)
+
+// PolicyControllerName is the name of the controller reloading the policy.
+const PolicyControllerName = "policy"
+
+// Reasons of the events recorded on the policy ConfigMap.
+const (
+	EventReasonPolicyLoaded   = "PolicyLoaded"
+	EventReasonPolicyRejected = "PolicyRejected"
+)
+
+// PolicyReconciler puts the policy stored in a ConfigMap into effect. An
+// invalid policy is rejected and the last valid one stays in effect; without
+// the ConfigMap, the policy of the manager's configuration file applies.
+type PolicyReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+
+	// ConfigMap names the ConfigMap holding the policy under config.PolicyConfigMapKey.
+	ConfigMap types.NamespacedName
+	// Base is the policy of the configuration file. The ConfigMap overrides
+	// the fields it sets.
+	Base config.Policy
+	// Store receives the policy in effect.
+	Store *config.PolicyStore
+	// Updates receives the NamespaceLabels affected by a policy change.
+	Updates chan<- event.GenericEvent
+}
+
+// +kubebuilder:rbac:groups="",namespace=system,resources=configmaps,verbs=get;list;watch
+
+// Reconcile loads the policy ConfigMap and requeues the NamespaceLabels in the
+// namespaces whose outcome the new policy can change.
+func (r *PolicyReconciler) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	policy, revision := r.Base, ""
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, r.ConfigMap, cm); client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	} else if err == nil {
+		var loadErr error
+		policy, loadErr = r.decode(cm)
+		if loadErr != nil {
+			_, current := r.Store.Load()
+			logger.Error(loadErr, "rejected policy, keeping the last valid one",
+				"resourceVersion", cm.ResourceVersion, "revision", current)
+			metrics.PolicyReloads.WithLabelValues(metrics.ResultError).Inc()
+			r.event(cm, corev1.EventTypeWarning, EventReasonPolicyRejected,
+				"Policy rejected, revision %q stays in effect: %v", current, loadErr)
+			// Retrying cannot fix the ConfigMap; the next change to it is reconciled.
+			return ctrl.Result{}, nil
+		}
+		revision = cm.ResourceVersion
+	}
+
+	old, current := r.Store.Load()
+	if current == revision {
+		return ctrl.Result{}, nil
+	}
+	affected, err := r.affected(ctx, policy, old)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	r.Store.Store(policy, revision)
+	metrics.PolicyReloads.WithLabelValues(metrics.ResultSuccess).Inc()
+	logger.Info("policy in effect", "revision", revision, "policy", policy, "requeued", len(affected))
+	if revision != "" {
+		r.event(cm, corev1.EventTypeNormal, EventReasonPolicyLoaded, "Policy revision %q is in effect", revision)
+	}
+
+	for i := range affected {
+		select {
+		case r.Updates <- event.GenericEvent{Object: &affected[i]}:
+		case <-ctx.Done():
+			return ctrl.Result{}, ctx.Err()
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// Preload puts the policy of the ConfigMap into effect before the manager
+// starts, so that NamespaceLabels are not first reconciled with the policy of
+// the configuration file. reader must not depend on the manager's cache. An
+// invalid or missing ConfigMap leaves the policy of the configuration file in
+// effect.
+func (r *PolicyReconciler) Preload(ctx context.Context, reader client.Reader) error {
+	cm := &corev1.ConfigMap{}
+	if err := reader.Get(ctx, r.ConfigMap, cm); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	policy, err := r.decode(cm)
+	if err != nil {
+		return err
+	}
+	r.Store.Store(policy, cm.ResourceVersion)
+	return nil
+}
+
+func (r *PolicyReconciler) decode(cm *corev1.ConfigMap) (config.Policy, error) {
+	data, ok := cm.Data[config.PolicyConfigMapKey]
+	if !ok {
+		return config.Policy{}, fmt.Errorf("key %q not found", config.PolicyConfigMapKey)
+	}
+	policy, err := config.DecodePolicy([]byte(data), r.Base)
+	if err != nil {
+		return config.Policy{}, err
+	}
+	return policy, policy.Validate()
+}
+
+// affected lists the NamespaceLabels whose reconcile can have a different
+// outcome under policy than under old.
+func (r *PolicyReconciler) affected(ctx context.Context, policy, old config.Policy) ([]danateamv1.NamespaceLabel, error) {
+	all, namespaces := policy.Affected(old)
+	if all {
+		list := &danateamv1.NamespaceLabelList{}
+		if err := r.List(ctx, list); err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	}
+
+	var affected []danateamv1.NamespaceLabel
+	for _, ns := range namespaces {
+		list := &danateamv1.NamespaceLabelList{}
+		if err := r.List(ctx, list, client.InNamespace(ns)); err != nil {
+			return nil, err
+		}
+		affected = append(affected, list.Items...)
+	}
+	return affected, nil
+}
+
+func (r *PolicyReconciler) event(cm *corev1.ConfigMap, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder != nil {
+		r.Recorder.Eventf(cm, eventType, reason, messageFmt, args...)
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager. Only the
+// ConfigMap named by r.ConfigMap is reconciled.
+func (r *PolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(PolicyControllerName).
+		For(&corev1.ConfigMap{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return obj.GetNamespace() == r.ConfigMap.Namespace && obj.GetName() == r.ConfigMap.Name
+		}))).
+		Complete(r)
+}
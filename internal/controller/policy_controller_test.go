@@ -0,0 +1,131 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/internal/config"
+)
+
+var _ = Describe("Policy Controller", func() {
+	Context("When the policy ConfigMap changes", func() {
+		ctx := context.Background()
+
+		var namespace string
+		var cm *corev1.ConfigMap
+		var policyReconciler *PolicyReconciler
+		var updates chan event.GenericEvent
+		var recorder *record.FakeRecorder
+
+		reconcilePolicy := func() {
+			_, err := policyReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: policyReconciler.ConfigMap})
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		}
+
+		setPolicy := func(policy string) {
+			cm.Data = map[string]string{config.PolicyConfigMapKey: policy}
+			ExpectWithOffset(1, k8sClient.Update(ctx, cm)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "nslabel-policy-"}}
+			Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+			namespace = ns.Name
+
+			Expect(k8sClient.Create(ctx, &danateamv1.NamespaceLabel{
+				ObjectMeta: metav1.ObjectMeta{Name: "labels", Namespace: namespace},
+			})).To(Succeed())
+
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: namespace},
+				Data:       map[string]string{config.PolicyConfigMapKey: "protectedNamespaces: []\n"},
+			}
+			Expect(k8sClient.Create(ctx, cm)).To(Succeed())
+
+			updates = make(chan event.GenericEvent, 100)
+			recorder = record.NewFakeRecorder(100)
+			policyReconciler = &PolicyReconciler{
+				Client:    k8sClient,
+				Recorder:  recorder,
+				ConfigMap: types.NamespacedName{Name: cm.Name, Namespace: namespace},
+				Base:      config.Default().Policy,
+				Store:     config.NewPolicyStore(config.Default().Policy),
+				Updates:   updates,
+			}
+			reconcilePolicy()
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, cm)).To(Succeed())
+		})
+
+		It("should put a valid policy into effect and requeue affected NamespaceLabels", func() {
+			setPolicy("excludedNamespaces: [" + namespace + "]\n")
+			reconcilePolicy()
+
+			policy, revision := policyReconciler.Store.Load()
+			Expect(policy.ExcludedNamespaces).To(ConsistOf(namespace))
+			Expect(policy.ProtectedPrefixes).To(Equal(config.DefaultProtectedPrefixes))
+			Expect(revision).To(Equal(cm.ResourceVersion))
+
+			var update event.GenericEvent
+			Expect(updates).To(Receive(&update))
+			Expect(update.Object.GetNamespace()).To(Equal(namespace))
+			Expect(update.Object.GetName()).To(Equal("labels"))
+		})
+
+		It("should keep the last valid policy when the ConfigMap is invalid", func() {
+			_, valid := policyReconciler.Store.Load()
+			for len(recorder.Events) > 0 {
+				<-recorder.Events
+			}
+
+			setPolicy("excludedNamespace: [typo]\n")
+			reconcilePolicy()
+
+			_, revision := policyReconciler.Store.Load()
+			Expect(revision).To(Equal(valid))
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning " + EventReasonPolicyRejected)))
+			Expect(updates).To(BeEmpty())
+		})
+
+		It("should fall back to the configured policy when the ConfigMap is deleted", func() {
+			setPolicy("protectedLabels: [owner]\n")
+			reconcilePolicy()
+
+			Expect(k8sClient.Delete(ctx, cm)).To(Succeed())
+			reconcilePolicy()
+			policy, revision := policyReconciler.Store.Load()
+			Expect(revision).To(BeEmpty())
+			Expect(policy.ProtectedLabels).To(BeEmpty())
+
+			cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: cm.Name, Namespace: namespace}}
+			Expect(k8sClient.Create(ctx, cm)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,80 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package health contains the readiness and liveness checks exposed by the
+// manager's health probe endpoint.
+package health
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// now is overridden in tests.
+var now = time.Now
+
+// CertificateChecker returns a checker that fails when the key pair at
+// certPath and keyPath cannot be loaded, for example because a rotated
+// certificate does not match its key, or when either the pair on disk or the
+// certificate currently served by watcher is expired or not yet valid.
+func CertificateChecker(watcher *certwatcher.CertWatcher, certPath, keyPath string) healthz.Checker {
+	return func(_ *http.Request) error {
+		pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return fmt.Errorf("loading key pair %s, %s: %w", certPath, keyPath, err)
+		}
+		if err := checkValidity(&pair); err != nil {
+			return fmt.Errorf("certificate %s: %w", certPath, err)
+		}
+
+		served, err := watcher.GetCertificate(nil)
+		if err != nil {
+			return fmt.Errorf("getting served certificate: %w", err)
+		}
+		if err := checkValidity(served); err != nil {
+			return fmt.Errorf("served certificate: %w", err)
+		}
+		return nil
+	}
+}
+
+func checkValidity(cert *tls.Certificate) error {
+	if cert == nil || len(cert.Certificate) == 0 {
+		return fmt.Errorf("no certificate loaded")
+	}
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("parsing certificate: %w", err)
+		}
+	}
+
+	t := now()
+	if t.Before(leaf.NotBefore) {
+		return fmt.Errorf("not valid before %s", leaf.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if t.After(leaf.NotAfter) {
+		return fmt.Errorf("expired at %s", leaf.NotAfter.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+)
+
+func writeKeyPair(t *testing.T, dir string, notBefore, notAfter time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "namespacelabel-test"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath := filepath.Join(dir, "tls.crt")
+	keyPath := filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+func TestCertificateChecker(t *testing.T) {
+	start := time.Now()
+
+	dir := t.TempDir()
+	certPath, keyPath := writeKeyPair(t, dir, start.Add(-time.Hour), start.Add(time.Hour))
+	watcher, err := certwatcher.New(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := CertificateChecker(watcher, certPath, keyPath)
+
+	if err := check(nil); err != nil {
+		t.Fatalf("valid certificate: unexpected error: %v", err)
+	}
+
+	now = func() time.Time { return start.Add(2 * time.Hour) }
+	t.Cleanup(func() { now = time.Now })
+	if err := check(nil); err == nil {
+		t.Fatal("expired certificate: expected error")
+	}
+	now = time.Now
+
+	// Rotate only the certificate so it no longer matches the key on disk.
+	other := t.TempDir()
+	otherCert, _ := writeKeyPair(t, other, start.Add(-time.Hour), start.Add(time.Hour))
+	data, err := os.ReadFile(otherCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certPath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := check(nil); err == nil {
+		t.Fatal("mismatched key pair: expected error")
+	}
+}
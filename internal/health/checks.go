@@ -0,0 +1,64 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// cacheSyncTimeout bounds how long a readiness probe waits for the caches.
+const cacheSyncTimeout = time.Second
+
+// CRDChecker returns a checker that fails unless the API server serves
+// resource in groupVersion, for example because the CRD was never installed
+// or has been deleted.
+func CRDChecker(client discovery.DiscoveryInterface, groupVersion schema.GroupVersion, resource string) healthz.Checker {
+	return func(_ *http.Request) error {
+		resources, err := client.ServerResourcesForGroupVersion(groupVersion.String())
+		if err != nil {
+			return fmt.Errorf("discovering %s: %w", groupVersion, err)
+		}
+		for _, r := range resources.APIResources {
+			if r.Name == resource {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s is not served by %s", resource, groupVersion)
+	}
+}
+
+// CacheSyncChecker returns a checker that fails until every informer in c
+// has synced.
+func CacheSyncChecker(c cache.Cache) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), cacheSyncTimeout)
+		defer cancel()
+		if !c.WaitForCacheSync(ctx) {
+			return errors.New("informer caches have not synced")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Defaults for ReconcileTracker.
+const (
+	DefaultMaxConsecutiveErrors = 5
+	DefaultStuckAfter           = 5 * time.Minute
+)
+
+// ReconcileTracker follows the reconciles of a controller so that readiness
+// can reflect persistent API errors and liveness can detect a workqueue that
+// stopped making progress. The zero value is ready to use and a nil tracker
+// ignores all calls.
+type ReconcileTracker struct {
+	// MaxConsecutiveErrors is the number of reconciles in a row that must
+	// fail with an API error before the readiness check fails.
+	// DefaultMaxConsecutiveErrors is used when zero.
+	MaxConsecutiveErrors int
+	// StuckAfter is how long a reconcile may run, or queued work may wait
+	// without any reconcile starting, before the liveness check fails.
+	// DefaultStuckAfter is used when zero.
+	StuckAfter time.Duration
+	// QueueDepth returns the number of items waiting in the workqueue.
+	QueueDepth func() (int, error)
+
+	mu                sync.Mutex
+	inFlight          map[uint64]time.Time
+	nextID            uint64
+	lastStart         time.Time
+	queuedSince       time.Time
+	consecutiveErrors int
+	lastErr           error
+}
+
+// Start records the start of a reconcile. The returned function must be
+// called with the reconcile's error once it finishes.
+func (t *ReconcileTracker) Start() func(error) {
+	if t == nil {
+		return func(error) {}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.inFlight == nil {
+		t.inFlight = map[uint64]time.Time{}
+	}
+	id := t.nextID
+	t.nextID++
+	t.lastStart = now()
+	t.inFlight[id] = t.lastStart
+
+	return func(err error) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.inFlight, id)
+		if isAPIError(err) {
+			t.consecutiveErrors++
+			t.lastErr = err
+		} else {
+			t.consecutiveErrors = 0
+			t.lastErr = nil
+		}
+	}
+}
+
+// isAPIError reports whether err is a failure of the API server or of the
+// connection to it. Errors the API server returns for the request itself,
+// such as conflicts, missing objects or invalid and forbidden writes, show
+// that it is reachable: failing readiness for them would take the webhooks
+// down along with the operator.
+func isAPIError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsInternalError(err) || apierrors.IsServiceUnavailable(err) || errors.As(err, &netErr)
+}
+
+// ReadyChecker fails while the latest reconciles all failed with API errors.
+func (t *ReconcileTracker) ReadyChecker() healthz.Checker {
+	return func(_ *http.Request) error {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		limit := t.MaxConsecutiveErrors
+		if limit == 0 {
+			limit = DefaultMaxConsecutiveErrors
+		}
+		if t.consecutiveErrors >= limit {
+			return fmt.Errorf("last %d reconciles failed, latest error: %w", t.consecutiveErrors, t.lastErr)
+		}
+		return nil
+	}
+}
+
+// LiveChecker fails when a reconcile has been running for longer than
+// StuckAfter, or when work is queued but no reconcile started for that long.
+func (t *ReconcileTracker) LiveChecker() healthz.Checker {
+	return func(_ *http.Request) error {
+		t.mu.Lock()
+		stuckAfter := t.StuckAfter
+		if stuckAfter == 0 {
+			stuckAfter = DefaultStuckAfter
+		}
+		current := now()
+		for _, started := range t.inFlight {
+			if current.Sub(started) > stuckAfter {
+				t.mu.Unlock()
+				return fmt.Errorf("a reconcile has been running since %s", started.UTC().Format(time.RFC3339))
+			}
+		}
+		t.mu.Unlock()
+
+		if t.QueueDepth == nil {
+			return nil
+		}
+		depth, err := t.QueueDepth()
+		if err != nil {
+			return err
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		// queuedSince is when the checker first saw work waiting; it only
+		// counts as stuck if no reconcile started since then.
+		if depth == 0 || t.lastStart.After(t.queuedSince) {
+			t.queuedSince = time.Time{}
+		}
+		if depth == 0 {
+			return nil
+		}
+		if t.queuedSince.IsZero() {
+			t.queuedSince = current
+		}
+		if current.Sub(t.queuedSince) > stuckAfter {
+			return fmt.Errorf("%d items queued but no reconcile started since %s", depth,
+				t.queuedSince.UTC().Format(time.RFC3339))
+		}
+		return nil
+	}
+}
+
+// WorkqueueDepth returns a function reading the depth of the workqueue of the
+// named controller from the controller-runtime metrics registry. The depth is
+// zero until the controller has started.
+func WorkqueueDepth(controller string) func() (int, error) {
+	return func() (int, error) {
+		families, err := ctrlmetrics.Registry.Gather()
+		if err != nil {
+			return 0, err
+		}
+		for _, family := range families {
+			if family.GetName() != ctrlmetrics.WorkQueueSubsystem+"_"+ctrlmetrics.DepthKey {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "name" && label.GetValue() == controller {
+						return int(metric.GetGauge().GetValue()), nil
+					}
+				}
+			}
+		}
+		return 0, nil
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestReconcileTrackerReadiness(t *testing.T) {
+	tracker := &ReconcileTracker{MaxConsecutiveErrors: 3}
+	check := tracker.ReadyChecker()
+	apiErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	for i := 0; i < 2; i++ {
+		tracker.Start()(apiErr)
+	}
+	tracker.Start()(apierrors.NewConflict(schema.GroupResource{Resource: "namespaces"}, "ns", apiErr))
+	if err := check(nil); err != nil {
+		t.Fatalf("conflicts are transient and reset the streak: %v", err)
+	}
+
+	for _, err := range []error{
+		apierrors.NewInvalid(schema.GroupKind{Kind: "Namespace"}, "ns", nil),
+		apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "ns", errors.New("denied")),
+		errors.New("decoding annotation"),
+	} {
+		for i := 0; i < 3; i++ {
+			tracker.Start()(err)
+		}
+		if err := check(nil); err != nil {
+			t.Fatalf("errors caused by the request do not affect readiness: %v", err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		tracker.Start()(apierrors.NewServiceUnavailable("etcd"))
+	}
+	if err := check(nil); err == nil {
+		t.Fatal("expected readiness to fail after 3 server errors")
+	}
+	tracker.Start()(nil)
+
+	for i := 0; i < 3; i++ {
+		tracker.Start()(apiErr)
+	}
+	if err := check(nil); err == nil {
+		t.Fatal("expected readiness to fail after 3 API errors")
+	}
+
+	tracker.Start()(nil)
+	if err := check(nil); err != nil {
+		t.Fatalf("a successful reconcile restores readiness: %v", err)
+	}
+}
+
+func TestReconcileTrackerLiveness(t *testing.T) {
+	start := time.Now()
+	current := start
+	now = func() time.Time { return current }
+	t.Cleanup(func() { now = time.Now })
+
+	depth := 0
+	tracker := &ReconcileTracker{
+		StuckAfter: time.Minute,
+		QueueDepth: func() (int, error) { return depth, nil },
+	}
+	check := tracker.LiveChecker()
+
+	done := tracker.Start()
+	current = start.Add(2 * time.Minute)
+	if err := check(nil); err == nil {
+		t.Fatal("expected a long running reconcile to fail liveness")
+	}
+	done(nil)
+	if err := check(nil); err != nil {
+		t.Fatalf("idle queue: %v", err)
+	}
+
+	depth = 3
+	if err := check(nil); err != nil {
+		t.Fatalf("queue just filled up after a long idle period: %v", err)
+	}
+	current = current.Add(30 * time.Second)
+	tracker.Start()(nil)
+	current = current.Add(45 * time.Second)
+	if err := check(nil); err != nil {
+		t.Fatalf("reconciles are making progress: %v", err)
+	}
+	current = current.Add(2 * time.Minute)
+	if err := check(nil); err == nil {
+		t.Fatal("expected a queue without progress to fail liveness")
+	}
+}
+
+func TestCRDChecker(t *testing.T) {
+	gv := schema.GroupVersion{Group: "danateam.namespacelabel.io", Version: "v1"}
+	disco := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	check := CRDChecker(disco, gv, "namespacelabels")
+
+	if err := check(nil); err == nil {
+		t.Fatal("expected an error when the group version is not served")
+	}
+
+	disco.Resources = []*metav1.APIResourceList{{
+		GroupVersion: gv.String(),
+		APIResources: []metav1.APIResource{{Name: "namespacelabels", Kind: "NamespaceLabel"}},
+	}}
+	if err := check(nil); err != nil {
+		t.Fatalf("served CRD: %v", err)
+	}
+}
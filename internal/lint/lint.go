@@ -0,0 +1,391 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lint checks NamespaceLabel manifests without a cluster, so that
+// mistakes in a GitOps repository are found before the manifests are
+// applied: invalid manifests, labels the API server would reject on the
+// Namespace, labels denied by the policy, keys requested by several
+// NamespaceLabels of the same namespace, and name rules that are invalid or
+// derive nothing from the name of the namespace.
+package lint
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/internal/config"
+	"github.com/matanamar10/namesapcelabel/pkg/labelplan"
+	"github.com/matanamar10/namesapcelabel/pkg/namerule"
+)
+
+// Severities of findings.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Rules reported by the linter.
+const (
+	RuleSchema    = "schema"
+	RuleLabel     = "label"
+	RulePolicy    = "policy"
+	RuleConflict  = "conflict"
+	RuleDuplicate = "duplicate"
+	RuleNamespace = "namespace"
+	RuleConfig    = "config"
+	RuleNameRule  = "name-rule"
+)
+
+// RuleDescriptions describe every rule.
+var RuleDescriptions = map[string]string{
+	RuleSchema:    "The manifest does not match the NamespaceLabel schema.",
+	RuleLabel:     "A label key or value would be rejected by the API server and the admission webhook.",
+	RulePolicy:    "A label is denied or ignored by the manager's policy.",
+	RuleConflict:  "A label key is requested by several NamespaceLabels of the same namespace.",
+	RuleDuplicate: "The same NamespaceLabel is defined more than once.",
+	RuleNamespace: "The NamespaceLabel has no namespace, so namespace checks are skipped.",
+	RuleConfig:    "A ManagerConfig or policy ConfigMap is invalid.",
+	RuleNameRule:  "A name rule is invalid, or derives no valid labels from the name of the namespace.",
+}
+
+// Input is a file, or stdin, holding YAML documents.
+type Input struct {
+	Name string
+	Data []byte
+}
+
+// Finding is a problem found in an input.
+type Finding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	// Line is the first line of the YAML document, starting at 1.
+	Line int `json:"line"`
+	// Object is the NamespaceLabel, as namespace/name, when known.
+	Object  string `json:"object,omitempty"`
+	Message string `json:"message"`
+}
+
+// Report lists the findings of a run.
+type Report struct {
+	// NamespaceLabels is the number of NamespaceLabels checked.
+	NamespaceLabels int       `json:"namespaceLabels"`
+	Findings        []Finding `json:"findings"`
+}
+
+// Errors returns the number of findings with SeverityError.
+func (r *Report) Errors() int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Severity == SeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+// Linter checks NamespaceLabel manifests.
+type Linter struct {
+	// Policy is the policy NamespaceLabels are checked against, unless the
+	// inputs hold a ManagerConfig or policy ConfigMap.
+	Policy config.Policy
+	// ProtectedPrefixes, when not nil, replace the protected prefixes of the
+	// policy, like the --protected-prefixes flag of the manager.
+	ProtectedPrefixes []string
+}
+
+// document is a YAML document of an input.
+type document struct {
+	file string
+	line int
+	data []byte
+	meta metav1.TypeMeta
+}
+
+// manifest is a NamespaceLabel decoded from a document.
+type manifest struct {
+	nl  danateamv1.NamespaceLabel
+	doc *document
+}
+
+// Run checks the NamespaceLabels in inputs. A ManagerConfig among them
+// replaces the policy of l, and a ConfigMap with a policy.yaml key is
+// applied on top, as the manager does at runtime. Documents of other kinds
+// are skipped.
+func (l *Linter) Run(inputs []Input) *Report {
+	r := &Report{Findings: []Finding{}}
+	var docs []*document
+	for _, in := range inputs {
+		for _, doc := range split(in) {
+			if err := yaml.Unmarshal(doc.data, &doc.meta); err != nil {
+				r.add(RuleSchema, SeverityError, doc, "", "invalid YAML: %v", err)
+				continue
+			}
+			docs = append(docs, doc)
+		}
+	}
+
+	policy := l.policy(r, docs)
+
+	seen := map[string]*document{}
+	namespaces := map[string][]manifest{}
+	for _, doc := range docs {
+		if doc.meta.Kind != "NamespaceLabel" || !strings.HasPrefix(doc.meta.APIVersion, danateamv1.GroupVersion.Group+"/") {
+			continue
+		}
+		r.NamespaceLabels++
+		nl := &danateamv1.NamespaceLabel{}
+		if doc.meta.APIVersion != danateamv1.GroupVersion.String() {
+			r.add(RuleSchema, SeverityError, doc, "", "unsupported apiVersion %q, expected %q",
+				doc.meta.APIVersion, danateamv1.GroupVersion.String())
+			continue
+		}
+		if err := yaml.UnmarshalStrict(doc.data, nl); err != nil {
+			r.add(RuleSchema, SeverityError, doc, "", "%v", err)
+			continue
+		}
+		object := nl.Namespace + "/" + nl.Name
+		for _, err := range validateMetadata(nl) {
+			r.add(RuleSchema, SeverityError, doc, object, "%v", err)
+		}
+		for _, err := range labelplan.ValidateLabels(nl.Spec.Labels, field.NewPath("spec", "labels")) {
+			r.add(RuleLabel, SeverityError, doc, object, "%v", err)
+		}
+		nameRules(r, doc, object, nl)
+		if nl.Namespace == "" {
+			r.add(RuleNamespace, SeverityWarning, doc, object,
+				"metadata.namespace is not set, so conflicts and namespace policies are not checked")
+			plan(r, policy, []manifest{{nl: *nl, doc: doc}})
+			continue
+		}
+		if first, ok := seen[object]; ok {
+			r.add(RuleDuplicate, SeverityError, doc, object, "NamespaceLabel %s is already defined at %s:%d",
+				object, first.file, first.line)
+			continue
+		}
+		seen[object] = doc
+
+		if policy.Excludes(nl.Namespace) {
+			r.add(RulePolicy, SeverityWarning, doc, object,
+				"namespace %s is excluded by policy, so this NamespaceLabel is ignored", nl.Namespace)
+			continue
+		}
+		namespaces[nl.Namespace] = append(namespaces[nl.Namespace], manifest{nl: *nl, doc: doc})
+	}
+
+	for _, namespace := range sortedKeys(namespaces) {
+		plan(r, policy, namespaces[namespace])
+	}
+	return r
+}
+
+// plan reports the labels of the NamespaceLabels of a namespace that the
+// controller would not apply: those denied by policy, and those requested
+// by several NamespaceLabels, of which only one wins.
+func plan(r *Report, policy config.Policy, manifests []manifest) {
+	nls := make([]danateamv1.NamespaceLabel, 0, len(manifests))
+	named := map[string]*manifest{}
+	for i := range manifests {
+		nls = append(nls, manifests[i].nl)
+		named[manifests[i].nl.Name] = &manifests[i]
+	}
+	p := labelplan.Compute(labelplan.Input{
+		Namespace:     nls[0].Namespace,
+		Requests:      labelplan.Requests(nls),
+		Policy:        policy,
+		AdoptExisting: policy.AdoptExisting,
+	})
+	for _, step := range p.Steps {
+		m := &manifests[step.Index]
+		object := m.nl.Namespace + "/" + m.nl.Name
+		for _, d := range step.Denials {
+			r.add(RulePolicy, SeverityError, m.doc, object, "label %q is denied by policy: %s",
+				d.Key, denialMessages[d.Reason])
+		}
+		for _, c := range step.Conflicts {
+			owner := named[c.Owner]
+			r.add(RuleConflict, SeverityError, m.doc, object,
+				"label %q is also requested by NamespaceLabel %s/%s at %s:%d; only one of them is applied",
+				c.Key, owner.nl.Namespace, c.Owner, owner.doc.file, owner.doc.line)
+		}
+	}
+}
+
+// nameRules reports the name rules of nl that are invalid, and those that
+// derive no valid labels from the name of its namespace.
+func nameRules(r *Report, doc *document, object string, nl *danateamv1.NamespaceLabel) {
+	if len(nl.Spec.NameRules) == 0 {
+		return
+	}
+	rules, errs := namerule.Compile(nl.Spec.NameRules, field.NewPath("spec", "nameRules"))
+	for _, err := range errs {
+		r.add(RuleNameRule, SeverityError, doc, object, "%v", err)
+	}
+	if len(errs) > 0 || nl.Namespace == "" {
+		return
+	}
+	_, matched, err := rules.Derive(nl.Namespace)
+	switch {
+	case err != nil:
+		r.add(RuleNameRule, SeverityError, doc, object, "namespace name %q: %v", nl.Namespace, err)
+	case !matched:
+		r.add(RuleNameRule, SeverityWarning, doc, object, "namespace name %q matches none of the %d name rules",
+			nl.Namespace, len(nl.Spec.NameRules))
+	}
+}
+
+// denialMessages explain the config.Policy denial reasons, like the events of
+// the controller.
+var denialMessages = map[string]string{
+	config.DenialProtectedPrefix:    "the key has a protected prefix",
+	config.DenialProtectedLabel:     "the key is protected",
+	config.DenialProtectedNamespace: "the namespace is protected",
+}
+
+// policy returns the policy to check NamespaceLabels against, reporting
+// invalid ManagerConfigs and policy ConfigMaps among docs.
+func (l *Linter) policy(r *Report, docs []*document) config.Policy {
+	policy := l.Policy.DeepCopy()
+	for _, doc := range docs {
+		if doc.meta.APIVersion != config.APIVersion || doc.meta.Kind != config.Kind {
+			continue
+		}
+		cfg, err := config.Decode(doc.data)
+		if err == nil {
+			err = cfg.Validate()
+		}
+		if err != nil {
+			r.add(RuleConfig, SeverityError, doc, "", "invalid %s: %v", config.Kind, err)
+			continue
+		}
+		policy = cfg.Policy
+	}
+	for _, doc := range docs {
+		if doc.meta.APIVersion != "v1" || doc.meta.Kind != "ConfigMap" {
+			continue
+		}
+		cm := &corev1.ConfigMap{}
+		if err := yaml.Unmarshal(doc.data, cm); err != nil {
+			r.add(RuleConfig, SeverityError, doc, "", "invalid ConfigMap: %v", err)
+			continue
+		}
+		data, ok := cm.Data[config.PolicyConfigMapKey]
+		if !ok {
+			continue
+		}
+		p, err := config.DecodePolicy([]byte(data), policy)
+		if err == nil {
+			err = p.Validate()
+		}
+		if err != nil {
+			r.add(RuleConfig, SeverityError, doc, "", "invalid %s in ConfigMap %s: %v",
+				config.PolicyConfigMapKey, cm.Name, err)
+			continue
+		}
+		policy = p
+	}
+	if l.ProtectedPrefixes != nil {
+		policy.ProtectedPrefixes = append([]string(nil), l.ProtectedPrefixes...)
+	}
+	return policy
+}
+
+// validateMetadata returns the errors the API server would report for the
+// metadata of nl.
+func validateMetadata(nl *danateamv1.NamespaceLabel) field.ErrorList {
+	var errs field.ErrorList
+	path := field.NewPath("metadata")
+	if nl.Name == "" {
+		errs = append(errs, field.Required(path.Child("name"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(nl.Name) {
+			errs = append(errs, field.Invalid(path.Child("name"), nl.Name, msg))
+		}
+	}
+	if nl.Namespace != "" {
+		for _, msg := range validation.IsDNS1123Label(nl.Namespace) {
+			errs = append(errs, field.Invalid(path.Child("namespace"), nl.Namespace, msg))
+		}
+	}
+	return errs
+}
+
+func (r *Report) add(rule, severity string, doc *document, object, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, Finding{
+		Rule:     rule,
+		Severity: severity,
+		File:     doc.file,
+		Line:     doc.line,
+		Object:   object,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// split returns the non-empty YAML documents of in.
+func split(in Input) []*document {
+	var docs []*document
+	var buf bytes.Buffer
+	start, line := 1, 0
+	flush := func() {
+		if len(bytes.TrimSpace(buf.Bytes())) > 0 && !onlyComments(buf.Bytes()) {
+			docs = append(docs, &document{file: in.Name, line: start, data: append([]byte(nil), buf.Bytes()...)})
+		}
+		buf.Reset()
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(in.Data))
+	scanner.Buffer(nil, len(in.Data)+1)
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.HasPrefix(text, "---") && strings.TrimSpace(strings.TrimPrefix(text, "---")) == "" {
+			flush()
+			start = line + 1
+			continue
+		}
+		buf.WriteString(text)
+		buf.WriteByte('\n')
+	}
+	flush()
+	return docs
+}
+
+func onlyComments(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
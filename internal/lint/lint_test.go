@@ -0,0 +1,212 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/matanamar10/namesapcelabel/internal/config"
+)
+
+const teamA = `# NamespaceLabels of team-a
+apiVersion: danateam.namespacelabel.io/v1
+kind: NamespaceLabel
+metadata:
+  name: base
+  namespace: team-a
+spec:
+  labels:
+    team: platform
+    owner: alice
+---
+apiVersion: danateam.namespacelabel.io/v1
+kind: NamespaceLabel
+metadata:
+  name: payments
+  namespace: team-a
+spec:
+  labels:
+    team: payments
+    "bad key!": "ok"
+    tier: "-gold"
+---
+apiVersion: danateam.namespacelabel.io/v1
+kind: NamespaceLabel
+metadata:
+  name: typo
+  namespace: team-a
+spec:
+  lables:
+    team: platform
+`
+
+const cluster = `apiVersion: config.danateam.namespacelabel.io/v1alpha1
+kind: ManagerConfig
+policy:
+  protectedPrefixes: [kubernetes.io/]
+  protectedLabels: [owner]
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: namespacelabel-policy
+data:
+  policy.yaml: |
+    excludedNamespaces: [sandbox]
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ignored
+---
+apiVersion: danateam.namespacelabel.io/v1
+kind: NamespaceLabel
+metadata:
+  name: base
+  namespace: team-a
+spec:
+  labels:
+    example.com/cost-center: "42"
+---
+apiVersion: danateam.namespacelabel.io/v1
+kind: NamespaceLabel
+metadata:
+  name: base
+  namespace: sandbox
+---
+apiVersion: danateam.namespacelabel.io/v1
+kind: NamespaceLabel
+metadata:
+  name: unscoped
+spec:
+  labels:
+    example.com/team: platform
+`
+
+func TestRun(t *testing.T) {
+	l := &Linter{Policy: config.Default().Policy, ProtectedPrefixes: []string{"example.com/"}}
+	r := l.Run([]Input{{Name: "team-a.yaml", Data: []byte(teamA)}, {Name: "cluster.yaml", Data: []byte(cluster)}})
+
+	var got []string
+	for _, f := range r.Findings {
+		got = append(got, fmt.Sprintf("%s:%d %s %s %s", f.File, f.Line, f.Severity, f.Rule, f.Object))
+	}
+	want := []string{
+		"team-a.yaml:12 error label team-a/payments",
+		"team-a.yaml:12 error label team-a/payments",
+		"team-a.yaml:23 error schema ",
+		"cluster.yaml:20 error duplicate team-a/base",
+		"cluster.yaml:29 warning policy sandbox/base",
+		"cluster.yaml:35 warning namespace /unscoped",
+		"cluster.yaml:35 error policy /unscoped",
+		"team-a.yaml:1 error policy team-a/base",
+		"team-a.yaml:12 error conflict team-a/payments",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if r.NamespaceLabels != 6 || r.Errors() != 7 {
+		t.Errorf("got %d NamespaceLabels and %d errors, want 6 and 7", r.NamespaceLabels, r.Errors())
+	}
+	if msg := r.Findings[len(r.Findings)-2].Message; msg != `label "owner" is denied by policy: the key is protected` {
+		t.Errorf("policy message = %q", msg)
+	}
+	if msg := r.Findings[len(r.Findings)-1].Message; !strings.Contains(msg, "NamespaceLabel team-a/base at team-a.yaml:1") {
+		t.Errorf("conflict message = %q", msg)
+	}
+}
+
+func TestRunNameRules(t *testing.T) {
+	l := &Linter{Policy: config.Default().Policy}
+	r := l.Run([]Input{{Name: "rules.yaml", Data: []byte(`apiVersion: danateam.namespacelabel.io/v1
+kind: NamespaceLabel
+metadata:
+  name: derived
+  namespace: payments-api-prd
+spec:
+  nameRules:
+  - pattern: (?P<team>[a-z]+)-(?P<app>[a-z]+)-(?P<env>dev|stg|prd)
+    labels:
+    - key: team
+      group: team
+    - key: env
+      group: environment
+---
+apiVersion: danateam.namespacelabel.io/v1
+kind: NamespaceLabel
+metadata:
+  name: derived
+  namespace: sandbox
+spec:
+  nameRules:
+  - pattern: (?P<team>[a-z]+)-(?P<env>dev|prd)
+    labels:
+    - key: team
+      group: team
+`)}})
+
+	var got []string
+	for _, f := range r.Findings {
+		got = append(got, fmt.Sprintf("%d %s %s %s", f.Line, f.Severity, f.Rule, f.Object))
+	}
+	want := []string{
+		"1 error name-rule payments-api-prd/derived",
+		"15 warning name-rule sandbox/derived",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRunInvalidPolicy(t *testing.T) {
+	l := &Linter{Policy: config.Default().Policy}
+	r := l.Run([]Input{{Name: "policy.yaml", Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: namespacelabel-policy
+data:
+  policy.yaml: |
+    protectedNamespaces: [Not_A_Namespace]
+`)}})
+	if len(r.Findings) != 1 || r.Findings[0].Rule != RuleConfig {
+		t.Fatalf("findings = %+v, want one %s finding", r.Findings, RuleConfig)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	r := &Report{Findings: []Finding{{
+		Rule: RuleConflict, Severity: SeverityError, File: "a.yaml", Line: 3, Object: "team-a/base", Message: "boom",
+	}}}
+	var b strings.Builder
+	if err := r.WriteSARIF(&b, "kubectl-nslabel"); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal([]byte(b.String()), &log); err != nil {
+		t.Fatal(err)
+	}
+	result := log.Runs[0].Results[0]
+	if log.Version != "2.1.0" || len(log.Runs[0].Tool.Driver.Rules) != len(RuleDescriptions) ||
+		result.RuleID != RuleConflict || result.Level != "error" || result.Message.Text != "team-a/base: boom" ||
+		result.Locations[0].PhysicalLocation.Region.StartLine != 3 {
+		t.Errorf("unexpected SARIF log:\n%s", b.String())
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteText writes a line for every finding to w, followed by a summary.
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, f := range r.Findings {
+		fmt.Fprintf(&b, "%s:%d: %s: ", f.File, f.Line, f.Severity)
+		if f.Object != "" {
+			fmt.Fprintf(&b, "%s: ", f.Object)
+		}
+		fmt.Fprintf(&b, "%s [%s]\n", f.Message, f.Rule)
+	}
+	fmt.Fprintf(&b, "%d NamespaceLabels checked, %d errors, %d warnings\n",
+		r.NamespaceLabels, r.Errors(), len(r.Findings)-r.Errors())
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the report to w as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// The subset of SARIF 2.1.0 used by WriteSARIF.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name  string      `json:"name"`
+		Rules []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID               string       `json:"id"`
+		ShortDescription sarifMessage `json:"shortDescription"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region struct {
+				StartLine int `json:"startLine"`
+			} `json:"region"`
+		} `json:"physicalLocation"`
+	}
+)
+
+// WriteSARIF writes the report to w as a SARIF 2.1.0 log, which code
+// scanning tools can annotate pull requests with. Severities map to the
+// SARIF levels of the same name.
+func (r *Report) WriteSARIF(w io.Writer, tool string) error {
+	run := sarifRun{Tool: sarifTool{Driver: sarifDriver{Name: tool}}, Results: []sarifResult{}}
+	for _, id := range sortedKeys(RuleDescriptions) {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules,
+			sarifRule{ID: id, ShortDescription: sarifMessage{Text: RuleDescriptions[id]}})
+	}
+	for _, f := range r.Findings {
+		text := f.Message
+		if f.Object != "" {
+			text = f.Object + ": " + text
+		}
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = f.File
+		loc.PhysicalLocation.Region.StartLine = f.Line
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    f.Rule,
+			Level:     f.Severity,
+			Message:   sarifMessage{Text: text},
+			Locations: []sarifLocation{loc},
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics defines the Prometheus metrics exported by the
+// NamespaceLabel controllers. All collectors are registered on the
+// controller-runtime registry, so they are served next to the default
+// controller metrics on the manager's metrics endpoint.
+//
+// The namespace label is only used on series whose cardinality is bounded by
+// the number of namespaces that contain a NamespaceLabel; series are deleted
+// again once a namespace no longer has one, is deleted or ignored, or belongs
+// to a shard another replica took over. Likewise, the requirement label
+// names NamespaceLabelRequirements, whose series are deleted with them.
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const subsystem = "namespacelabel"
+
+var (
+	// ManagedLabels is the number of labels the operator owns on each namespace.
+	ManagedLabels = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: subsystem,
+		Name:      "managed_labels",
+		Help:      "Number of labels managed by the operator on a namespace.",
+	}, []string{"namespace"})
+
+	// NamespaceCompliant is 1 when every label requested for a namespace is
+	// applied and 0 when at least one is in conflict, denied or failed.
+	NamespaceCompliant = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: subsystem,
+		Name:      "namespace_compliant",
+		Help:      "Whether all labels requested for a namespace are applied (1) or not (0).",
+	}, []string{"namespace"})
+
+	// Conflicts counts label keys requested by more than one NamespaceLabel,
+	// or set outside the operator and not adopted. RecordFindings counts a
+	// conflict once, when it starts.
+	Conflicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "conflicts_total",
+		Help:      "Number of label conflicts that started, by label key.",
+	}, []string{"key"})
+
+	// DriftCorrections counts managed labels that were changed outside the
+	// operator and restored to their desired value.
+	DriftCorrections = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "drift_corrections_total",
+		Help:      "Number of managed labels restored after being changed outside the operator.",
+	})
+
+	// PolicyDenials counts labels that were not applied because a policy
+	// forbids them. RecordFindings counts a denial once, when it starts.
+	PolicyDenials = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "policy_denials_total",
+		Help:      "Number of labels that started to be rejected by policy, by reason.",
+	}, []string{"reason"})
+
+	// PolicyReloads counts attempts to put a policy ConfigMap into effect.
+	PolicyReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "policy_reloads_total",
+		Help:      "Number of policy ConfigMap changes put into effect or rejected, by result.",
+	}, []string{"result"})
+
+	// OwnedShards is the number of shards reconciled by this replica when
+	// namespaces are sharded across replicas.
+	OwnedShards = prometheus.NewGauge(prometheus.GaugeOpts{
+		Subsystem: subsystem,
+		Name:      "owned_shards",
+		Help:      "Number of namespace shards owned by this replica.",
+	})
+
+	// WriteBudgetWait observes how long writes wait for the client-side write budget.
+	WriteBudgetWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Subsystem: subsystem,
+		Name:      "write_budget_wait_seconds",
+		Help:      "Time API writes waited for the client-side write budget.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	})
+
+	// NamespaceWrites counts the Namespace writes the reconciler performed, and
+	// those it skipped because the desired state was already in place.
+	NamespaceWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "namespace_writes_total",
+		Help:      "Number of Namespace writes performed or skipped as unchanged, by result.",
+	}, []string{"result"})
+
+	// WriteDuration observes the latency of writes to Namespace objects.
+	WriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: subsystem,
+		Name:      "namespace_write_duration_seconds",
+		Help:      "Latency of label writes to Namespace objects, by result.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"result"})
+
+	// RequirementNamespaces is the number of namespaces selected by each
+	// NamespaceLabelRequirement.
+	RequirementNamespaces = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: subsystem,
+		Name:      "requirement_namespaces",
+		Help:      "Number of namespaces selected by a NamespaceLabelRequirement.",
+	}, []string{"requirement"})
+
+	// RequirementNonCompliant is the number of selected namespaces that do
+	// not carry the labels a NamespaceLabelRequirement requires.
+	RequirementNonCompliant = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: subsystem,
+		Name:      "requirement_noncompliant_namespaces",
+		Help:      "Number of namespaces selected by a NamespaceLabelRequirement that do not comply with it.",
+	}, []string{"requirement"})
+
+	// RequirementViolations is the number of selected namespaces missing each
+	// required label, or carrying a value that does not match its pattern.
+	RequirementViolations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: subsystem,
+		Name:      "requirement_violations",
+		Help:      "Number of namespaces violating a NamespaceLabelRequirement, by label key and reason.",
+	}, []string{"requirement", "key", "reason"})
+
+	// RequirementDefaultsApplied counts the default and derived values
+	// written to namespaces by NamespaceLabelRequirements.
+	RequirementDefaultsApplied = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "requirement_defaults_applied_total",
+		Help:      "Number of default and derived label values written by a NamespaceLabelRequirement.",
+	}, []string{"requirement"})
+
+	// AuditRecordsDropped counts audit records dropped because the audit
+	// queue was full.
+	AuditRecordsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "audit_records_dropped_total",
+		Help:      "Number of audit records dropped because the audit sinks did not keep up.",
+	})
+
+	// AuditSinkErrors counts audit records that could not be written to a sink.
+	AuditSinkErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "audit_sink_errors_total",
+		Help:      "Number of audit records that could not be written, by sink.",
+	}, []string{"sink"})
+)
+
+// Results used for the WriteDuration and PolicyReloads metrics.
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+// Reasons used for the RequirementViolations metric.
+const (
+	ViolationMissing = "missing"
+	ViolationInvalid = "invalid"
+)
+
+// Results used for the NamespaceWrites metric.
+const (
+	WritePerformed = "performed"
+	WriteSkipped   = "skipped"
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		ManagedLabels,
+		NamespaceCompliant,
+		Conflicts,
+		DriftCorrections,
+		PolicyDenials,
+		PolicyReloads,
+		OwnedShards,
+		WriteBudgetWait,
+		NamespaceWrites,
+		WriteDuration,
+		RequirementNamespaces,
+		RequirementNonCompliant,
+		RequirementViolations,
+		RequirementDefaultsApplied,
+		AuditRecordsDropped,
+		AuditSinkErrors,
+	)
+}
+
+// Finding is a label requested for a namespace that is not applied because
+// of a conflict or the policy.
+type Finding struct {
+	// Requester is the NamespaceLabel requesting the label, or the owner of
+	// inherited labels.
+	Requester string
+	Key       string
+	// Reason is the policy denial reason, or "" for a conflict.
+	Reason string
+}
+
+// namespaces holds what was recorded for each namespace, so that counters
+// only count changes and series can be deleted by shard.
+var namespaces = struct {
+	sync.Mutex
+	// gauges are the namespaces with per-namespace series.
+	gauges map[string]bool
+	// findings are the findings last recorded for each namespace.
+	findings map[string]map[Finding]bool
+}{gauges: map[string]bool{}, findings: map[string]map[Finding]bool{}}
+
+// RecordNamespace sets the per-namespace series of namespace.
+func RecordNamespace(namespace string, managed int, compliant bool) {
+	namespaces.Lock()
+	defer namespaces.Unlock()
+	namespaces.gauges[namespace] = true
+	ManagedLabels.WithLabelValues(namespace).Set(float64(managed))
+	if compliant {
+		NamespaceCompliant.WithLabelValues(namespace).Set(1)
+	} else {
+		NamespaceCompliant.WithLabelValues(namespace).Set(0)
+	}
+}
+
+// RecordFindings replaces the findings of namespace, and counts those that
+// were not recorded before in Conflicts or PolicyDenials, so that a label
+// that stays in conflict or denied is counted once however often it is
+// reconciled.
+func RecordFindings(namespace string, findings []Finding) {
+	namespaces.Lock()
+	defer namespaces.Unlock()
+	previous := namespaces.findings[namespace]
+	current := make(map[Finding]bool, len(findings))
+	for _, f := range findings {
+		if current[f] {
+			continue
+		}
+		current[f] = true
+		if previous[f] {
+			continue
+		}
+		if f.Reason == "" {
+			Conflicts.WithLabelValues(f.Key).Inc()
+		} else {
+			PolicyDenials.WithLabelValues(f.Reason).Inc()
+		}
+	}
+	if len(current) == 0 {
+		delete(namespaces.findings, namespace)
+		return
+	}
+	namespaces.findings[namespace] = current
+}
+
+// ForgetNamespace removes all per-namespace series for namespace, and its
+// findings, which are counted again once they are found again.
+func ForgetNamespace(namespace string) {
+	namespaces.Lock()
+	defer namespaces.Unlock()
+	forget(namespace)
+}
+
+// ForgetNamespaces calls ForgetNamespace for every namespace with series or
+// findings for which match returns true.
+func ForgetNamespaces(match func(namespace string) bool) {
+	namespaces.Lock()
+	defer namespaces.Unlock()
+	for namespace := range namespaces.gauges {
+		if match(namespace) {
+			forget(namespace)
+		}
+	}
+	for namespace := range namespaces.findings {
+		if match(namespace) {
+			forget(namespace)
+		}
+	}
+}
+
+func forget(namespace string) {
+	ManagedLabels.DeleteLabelValues(namespace)
+	NamespaceCompliant.DeleteLabelValues(namespace)
+	delete(namespaces.gauges, namespace)
+	delete(namespaces.findings, namespace)
+}
+
+// ForgetRequirement removes all series of the NamespaceLabelRequirement name.
+func ForgetRequirement(name string) {
+	RequirementNamespaces.DeleteLabelValues(name)
+	RequirementNonCompliant.DeleteLabelValues(name)
+	RequirementViolations.DeletePartialMatch(prometheus.Labels{"requirement": name})
+	RequirementDefaultsApplied.DeleteLabelValues(name)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecordFindingsCountsNewFindings(t *testing.T) {
+	defer ForgetNamespace("team-a")
+	conflict := Finding{Requester: "payments", Key: "metrics-test/team"}
+	denial := Finding{Requester: "payments", Key: "metrics-test/owner", Reason: "metrics-test"}
+	count := func() (float64, float64) {
+		return testutil.ToFloat64(Conflicts.WithLabelValues(conflict.Key)),
+			testutil.ToFloat64(PolicyDenials.WithLabelValues(denial.Reason))
+	}
+
+	RecordFindings("team-a", []Finding{conflict, denial})
+	RecordFindings("team-a", []Finding{conflict, denial})
+	if conflicts, denials := count(); conflicts != 1 || denials != 1 {
+		t.Fatalf("got %v conflicts and %v denials after two reconciles, want 1 and 1", conflicts, denials)
+	}
+
+	// A conflict that is resolved and comes back is counted again.
+	RecordFindings("team-a", []Finding{denial})
+	RecordFindings("team-a", []Finding{conflict, denial})
+	if conflicts, denials := count(); conflicts != 2 || denials != 1 {
+		t.Fatalf("got %v conflicts and %v denials, want 2 and 1", conflicts, denials)
+	}
+
+	// So is one found again after the namespace was forgotten.
+	ForgetNamespace("team-a")
+	RecordFindings("team-a", []Finding{conflict, denial})
+	if conflicts, denials := count(); conflicts != 3 || denials != 2 {
+		t.Fatalf("got %v conflicts and %v denials, want 3 and 2", conflicts, denials)
+	}
+}
+
+func TestForgetNamespacesDeletesSeries(t *testing.T) {
+	RecordNamespace("team-a", 3, true)
+	RecordNamespace("team-b", 1, false)
+	ForgetNamespaces(func(namespace string) bool { return namespace == "team-a" })
+	defer ForgetNamespace("team-b")
+
+	if n := testutil.CollectAndCount(ManagedLabels); n != 1 {
+		t.Errorf("got %d managed labels series, want 1", n)
+	}
+	if n := testutil.CollectAndCount(NamespaceCompliant); n != 1 {
+		t.Errorf("got %d compliance series, want 1", n)
+	}
+	if got := testutil.ToFloat64(NamespaceCompliant.WithLabelValues("team-b")); got != 0 {
+		t.Errorf("team-b is compliant: %v", got)
+	}
+}
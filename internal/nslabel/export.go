@@ -0,0 +1,190 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nslabel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/internal/config"
+)
+
+// ExportOptions select the labels Export turns into NamespaceLabels.
+type ExportOptions struct {
+	// Name is the name of the generated NamespaceLabels.
+	Name string
+	// Prefixes limits the export to keys with one of these prefixes. All
+	// keys are exported when empty.
+	Prefixes []string
+	// Namespaces limits the export to these namespaces. All namespaces are
+	// exported when empty.
+	Namespaces []string
+}
+
+// Manifest is a NamespaceLabel as written by Export, without the fields set
+// by the API server.
+type Manifest struct {
+	APIVersion string                        `json:"apiVersion"`
+	Kind       string                        `json:"kind"`
+	Metadata   ManifestMetadata              `json:"metadata"`
+	Spec       danateamv1.NamespaceLabelSpec `json:"spec"`
+}
+
+// ManifestMetadata is the metadata of a Manifest.
+type ManifestMetadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// Export returns a NamespaceLabel manifest for every Namespace with labels to
+// export, requesting the current values of those labels. Labels under the
+// prefixes reserved for Kubernetes and labels the operator already manages
+// are never exported. Applying the manifests with the policy's adoptExisting
+// set makes the operator take the labels over without rewriting them.
+func (p *Plugin) Export(ctx context.Context, opts ExportOptions) ([]Manifest, error) {
+	var namespaces []corev1.Namespace
+	if len(opts.Namespaces) == 0 {
+		list := &corev1.NamespaceList{}
+		if err := p.Client.List(ctx, list); err != nil {
+			return nil, err
+		}
+		namespaces = list.Items
+	}
+	for _, name := range opts.Namespaces {
+		ns := corev1.Namespace{}
+		if err := p.Client.Get(ctx, client.ObjectKey{Name: name}, &ns); err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, ns)
+	}
+
+	var manifests []Manifest
+	for i := range namespaces {
+		ns := &namespaces[i]
+		managed := map[string]string{}
+		if value := ns.Annotations[danateamv1.ManagedLabelsAnnotation]; value != "" {
+			// A malformed annotation is ignored, like the controller does.
+			_ = json.Unmarshal([]byte(value), &managed)
+		}
+		labels := map[string]string{}
+		for key, value := range ns.Labels {
+			if _, ok := managed[key]; ok || hasPrefix(key, config.DefaultProtectedPrefixes) {
+				continue
+			}
+			if len(opts.Prefixes) > 0 && !hasPrefix(key, opts.Prefixes) {
+				continue
+			}
+			labels[key] = value
+		}
+		if len(labels) == 0 {
+			continue
+		}
+		manifests = append(manifests, Manifest{
+			APIVersion: danateamv1.GroupVersion.String(),
+			Kind:       "NamespaceLabel",
+			Metadata:   ManifestMetadata{Name: opts.Name, Namespace: ns.Name},
+			Spec:       danateamv1.NamespaceLabelSpec{Labels: labels},
+		})
+	}
+	return manifests, nil
+}
+
+func hasPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteManifests writes manifests to w as a multi-document YAML stream.
+func WriteManifests(w io.Writer, manifests []Manifest) error {
+	for i, m := range manifests {
+		data, err := yaml.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFiles writes every manifest to dir/<namespace>.yaml. With kustomize,
+// it writes a kustomize tree instead: dir/<namespace>/ holds the manifest and
+// a kustomization, and dir/kustomization.yaml includes every namespace.
+func WriteFiles(dir string, manifests []Manifest, kustomize bool) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	var resources []string
+	for _, m := range manifests {
+		data, err := yaml.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if !kustomize {
+			if err := os.WriteFile(filepath.Join(dir, m.Metadata.Namespace+".yaml"), data, 0o644); err != nil {
+				return err
+			}
+			continue
+		}
+		nsDir := filepath.Join(dir, m.Metadata.Namespace)
+		if err := os.MkdirAll(nsDir, 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(nsDir, "namespacelabel.yaml"), data, 0o644); err != nil {
+			return err
+		}
+		if err := writeKustomization(nsDir, []string{"namespacelabel.yaml"}); err != nil {
+			return err
+		}
+		resources = append(resources, m.Metadata.Namespace)
+	}
+	if kustomize {
+		return writeKustomization(dir, resources)
+	}
+	return nil
+}
+
+func writeKustomization(dir string, resources []string) error {
+	data, err := yaml.Marshal(map[string]interface{}{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"resources":  resources,
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "kustomization.yaml"), data, 0o644)
+}
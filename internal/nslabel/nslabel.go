@@ -0,0 +1,523 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package nslabel implements the kubectl-nslabel plugin, which shows who
+// owns the labels of a Namespace and changes them through NamespaceLabels
+// instead of labeling the Namespace directly.
+package nslabel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/internal/config"
+	"github.com/matanamar10/namesapcelabel/pkg/labelplan"
+)
+
+// DefaultName is the name of the NamespaceLabel that Set creates for keys
+// no NamespaceLabel requests yet.
+const DefaultName = "kubectl-nslabel"
+
+// Plugin runs the commands of kubectl-nslabel against a cluster.
+type Plugin struct {
+	Client client.Client
+	// Out receives the output of the commands.
+	Out io.Writer
+	// Policy is the policy of the manager, against which the labels of the
+	// NamespaceLabels are resolved. LoadManagerConfig and LoadPolicy read it
+	// from the cluster.
+	Policy config.Policy
+}
+
+// ManagerConfigKey is the key of the configuration file of the manager in
+// the ConfigMap that config/manager generates and mounts for --config.
+const ManagerConfigKey = "controller_manager_config.yaml"
+
+// LoadManagerConfig returns the configuration of the manager stored in the
+// ManagerConfigKey of the ConfigMap key.
+func LoadManagerConfig(ctx context.Context, c client.Client, key client.ObjectKey) (*config.ManagerConfig, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, key, cm); err != nil {
+		return nil, fmt.Errorf("reading manager ConfigMap %s: %w", key, err)
+	}
+	data, ok := cm.Data[ManagerConfigKey]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %s has no %s", key, ManagerConfigKey)
+	}
+	cfg, err := config.Decode([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s of ConfigMap %s: %w", ManagerConfigKey, key, err)
+	}
+	return cfg, nil
+}
+
+// LoadPolicy returns the runtime policy of the manager stored in the
+// config.PolicyConfigMapKey of the ConfigMap key, on top of base, the policy
+// of the configuration file of the manager. base is returned when the
+// ConfigMap does not exist.
+func LoadPolicy(ctx context.Context, c client.Client, key client.ObjectKey, base config.Policy) (config.Policy, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, key, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return base, nil
+		}
+		return base, fmt.Errorf("reading policy ConfigMap %s: %w", key, err)
+	}
+	data, ok := cm.Data[config.PolicyConfigMapKey]
+	if !ok {
+		return base, nil
+	}
+	policy, err := config.DecodePolicy([]byte(data), base)
+	if err != nil {
+		return base, fmt.Errorf("decoding %s of ConfigMap %s: %w", config.PolicyConfigMapKey, key, err)
+	}
+	return policy, nil
+}
+
+// state is what the plugin knows about the labels of a Namespace.
+type state struct {
+	ns *corev1.Namespace
+	// owners maps the keys managed by the operator to the NamespaceLabel
+	// owning them, as recorded on the Namespace.
+	owners map[string]string
+	// locked maps the keys locked on the Namespace to their values.
+	locked map[string]string
+	// nls are the NamespaceLabels of the Namespace, oldest first.
+	nls []danateamv1.NamespaceLabel
+	// plan resolves the labels requested by nls the way the operator does.
+	plan labelplan.Plan
+	// excluded is set when the policy excludes the Namespace, so that the
+	// operator ignores nls and applies none of their labels.
+	excluded bool
+}
+
+func (p *Plugin) load(ctx context.Context, namespace string) (*state, error) {
+	s := &state{ns: &corev1.Namespace{}, owners: map[string]string{}}
+	if err := p.Client.Get(ctx, client.ObjectKey{Name: namespace}, s.ns); err != nil {
+		return nil, err
+	}
+	if value := s.ns.Annotations[danateamv1.ManagedLabelsAnnotation]; value != "" {
+		if err := json.Unmarshal([]byte(value), &s.owners); err != nil {
+			return nil, fmt.Errorf("decoding %s of namespace %s: %w", danateamv1.ManagedLabelsAnnotation, namespace, err)
+		}
+	}
+	locked, err := labelplan.Locks(s.ns.Annotations)
+	if err != nil {
+		return nil, fmt.Errorf("namespace %s: %w", namespace, err)
+	}
+	s.locked = locked
+	list := &danateamv1.NamespaceLabelList{}
+	if err := p.Client.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	s.nls = list.Items
+	sort.SliceStable(s.nls, func(i, j int) bool {
+		a, b := &s.nls[i], &s.nls[j]
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		return a.Name < b.Name
+	})
+	s.plan = labelplan.Compute(labelplan.Input{
+		Namespace:     s.ns.Name,
+		Live:          s.ns.Labels,
+		Owners:        s.owners,
+		Requests:      labelplan.Requests(s.nls),
+		Policy:        p.Policy,
+		AdoptExisting: p.Policy.AdoptExisting,
+		Unlocked:      labelplan.Unlocked(s.ns.Annotations),
+		Locked:        s.locked,
+	})
+	s.excluded = p.Policy.Excludes(namespace)
+	return s, nil
+}
+
+// requesters returns the active NamespaceLabels whose spec requests key.
+func (s *state) requesters(key string) []*danateamv1.NamespaceLabel {
+	var nls []*danateamv1.NamespaceLabel
+	for i := range s.nls {
+		if _, ok := labelplan.Labels(&s.nls[i])[key]; ok && s.nls[i].DeletionTimestamp.IsZero() {
+			nls = append(nls, &s.nls[i])
+		}
+	}
+	return nls
+}
+
+// immutable reports whether key is locked on the Namespace, or the
+// NamespaceLabel name applied it as an immutable label, and is not unlocked.
+func (s *state) immutable(name, key string) bool {
+	if contains(labelplan.Unlocked(s.ns.Annotations), key) {
+		return false
+	}
+	if _, ok := s.locked[key]; ok {
+		return true
+	}
+	for i := range s.nls {
+		nl := &s.nls[i]
+		if nl.Name != name {
+			continue
+		}
+		_, applied := nl.Status.AppliedLabels[key]
+		return applied && contains(labelplan.Immutable(nl), key) &&
+			!contains(labelplan.Unlocked(s.ns.Annotations), key)
+	}
+	return false
+}
+
+// desired maps every key applied by the plan to the winning NamespaceLabel.
+func (s *state) desired() map[string]*danateamv1.NamespaceLabel {
+	winners := map[string]*danateamv1.NamespaceLabel{}
+	if s.excluded {
+		return winners
+	}
+	for _, step := range s.plan.Steps {
+		for key := range step.Applied {
+			winners[key] = &s.nls[step.Index]
+		}
+	}
+	return winners
+}
+
+// notApplied explains why the plan does not apply the label key requested
+// by the NamespaceLabel of step, or returns "" when it is applied.
+func (s *state) notApplied(step *labelplan.Step, key string) string {
+	if s.excluded {
+		return "the namespace is excluded by the policy"
+	}
+	for _, l := range step.Locked {
+		if l.Key == key && !l.Removed {
+			return fmt.Sprintf("immutable and kept at %q", l.Value)
+		}
+	}
+	if _, ok := step.Applied[key]; ok {
+		return ""
+	}
+	for _, l := range step.Invalid {
+		if l.Key == key {
+			return "not a valid label: " + l.Message
+		}
+	}
+	for _, d := range step.Denials {
+		if d.Key == key {
+			return "denied by policy: " + denialMessages[d.Reason]
+		}
+	}
+	for _, c := range step.Conflicts {
+		if c.Key == key {
+			return "owned by NamespaceLabel " + c.Owner
+		}
+	}
+	if contains(step.Unadopted, key) {
+		return fmt.Sprintf("set to %q outside the operator and not adopted", s.ns.Labels[key])
+	}
+	return ""
+}
+
+// denialMessages explain the config.Policy denial reasons, like the events of
+// the controller.
+var denialMessages = map[string]string{
+	config.DenialProtectedPrefix:    "the key has a protected prefix",
+	config.DenialProtectedLabel:     "the key is protected",
+	config.DenialProtectedNamespace: "the namespace is protected",
+}
+
+// ownedOutsideNamespaceLabels reports whether owner is recorded for labels
+// that no NamespaceLabel requests: inherited labels and those filled in by a
+// NamespaceLabelRequirement.
+func ownedOutsideNamespaceLabels(owner string) bool {
+	return owner == danateamv1.InheritedOwner || strings.HasPrefix(owner, danateamv1.RequirementOwnerPrefix)
+}
+
+// Get prints every label of namespace with the NamespaceLabel owning it,
+// danateamv1.InheritedOwner for inherited labels, the NamespaceLabelRequirement
+// that filled it in, or "-" for labels set outside the operator.
+func (p *Plugin) Get(ctx context.Context, namespace string) error {
+	s, err := p.load(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(p.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tOWNER")
+	for _, key := range sortedKeys(s.ns.Labels) {
+		owner := s.owners[key]
+		if owner == "" {
+			owner = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, s.ns.Labels[key], owner)
+	}
+	return w.Flush()
+}
+
+// Why explains where the label key of namespace comes from: the
+// NamespaceLabel owning it, NamespaceLabels that request it without being
+// applied, and the field managers that wrote it.
+func (p *Plugin) Why(ctx context.Context, namespace, key string) error {
+	s, err := p.load(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	value, present := s.ns.Labels[key]
+	owner := s.owners[key]
+	switch {
+	case present && owner == danateamv1.InheritedOwner:
+		fmt.Fprintf(&b, "%s=%s is inherited from the parent namespaces.\n", key, value)
+	case present && strings.HasPrefix(owner, danateamv1.RequirementOwnerPrefix):
+		fmt.Fprintf(&b, "%s=%s is filled in by NamespaceLabelRequirement %s.\n", key, value,
+			strings.TrimPrefix(owner, danateamv1.RequirementOwnerPrefix))
+	case present && owner != "":
+		fmt.Fprintf(&b, "%s=%s is set by NamespaceLabel %s/%s.\n", key, value, namespace, owner)
+	case present:
+		fmt.Fprintf(&b, "%s=%s is not managed by a NamespaceLabel.\n", key, value)
+	default:
+		fmt.Fprintf(&b, "%s is not set on namespace %s.\n", key, namespace)
+	}
+	if present && s.immutable(owner, key) {
+		fmt.Fprintf(&b, "It is immutable until listed in the %s annotation of the namespace.\n",
+			danateamv1.UnlockImmutableLabelsAnnotation)
+	}
+
+	for i := range s.plan.Steps {
+		step := &s.plan.Steps[i]
+		nl := &s.nls[step.Index]
+		value, requested := labelplan.Labels(nl)[key]
+		reason := s.notApplied(step, key)
+		if !requested || (nl.Name == owner && reason == "") || !nl.DeletionTimestamp.IsZero() {
+			continue
+		}
+		fmt.Fprintf(&b, "NamespaceLabel %s requests %s=%s", nl.Name, key, value)
+		if reason != "" {
+			fmt.Fprintf(&b, ", not applied: %s", reason)
+		}
+		b.WriteString(".\n")
+	}
+
+	if managers := labelManagers(s.ns, key); len(managers) > 0 {
+		fmt.Fprintf(&b, "Written by field managers: %s.\n", strings.Join(managers, ", "))
+	}
+	_, err = io.WriteString(p.Out, b.String())
+	return err
+}
+
+// labelManagers returns the field managers of ns that own the label key.
+func labelManagers(ns *corev1.Namespace, key string) []string {
+	var managers []string
+	for _, entry := range ns.ManagedFields {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+		var fields struct {
+			Metadata struct {
+				Labels map[string]json.RawMessage `json:"f:labels"`
+			} `json:"f:metadata"`
+		}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if _, ok := fields.Metadata.Labels["f:"+key]; ok {
+			managers = append(managers, fmt.Sprintf("%s (%s)", entry.Manager, entry.Operation))
+		}
+	}
+	return managers
+}
+
+// Set requests labels for namespace. Each key is set on the NamespaceLabel
+// already requesting it, or on the NamespaceLabel name, which is created when
+// it does not exist. A Namespace excluded by the policy is reported, since
+// the operator ignores its NamespaceLabels.
+func (p *Plugin) Set(ctx context.Context, namespace, name string, labels map[string]string) error {
+	s, err := p.load(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	if s.excluded {
+		fmt.Fprintf(p.Out, "namespace %s is excluded by the policy, the operator will not apply these labels\n", namespace)
+	}
+	desired := s.desired()
+	changes := map[string]map[string]*string{}
+	for key, value := range labels {
+		target := name
+		if nl, ok := desired[key]; ok {
+			target = nl.Name
+		}
+		if changes[target] == nil {
+			changes[target] = map[string]*string{}
+		}
+		changes[target][key] = &value
+	}
+	return p.apply(ctx, namespace, changes)
+}
+
+// Unset stops requesting keys for namespace. They are removed from every
+// NamespaceLabel requesting them, or only from the NamespaceLabel name when it
+// is not empty. The operator then removes them from the Namespace.
+func (p *Plugin) Unset(ctx context.Context, namespace, name string, keys []string) error {
+	s, err := p.load(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	changes := map[string]map[string]*string{}
+	for _, key := range keys {
+		requesters := s.requesters(key)
+		if len(requesters) == 0 {
+			fmt.Fprintf(p.Out, "%s is not requested by any NamespaceLabel\n", key)
+		}
+		for _, nl := range requesters {
+			if name != "" && nl.Name != name {
+				continue
+			}
+			if changes[nl.Name] == nil {
+				changes[nl.Name] = map[string]*string{}
+			}
+			changes[nl.Name][key] = nil
+		}
+	}
+	return p.apply(ctx, namespace, changes)
+}
+
+// apply sets, or removes when nil, the labels of changes on the
+// NamespaceLabels they are keyed by.
+func (p *Plugin) apply(ctx context.Context, namespace string, changes map[string]map[string]*string) error {
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		nl := &danateamv1.NamespaceLabel{}
+		err := p.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, nl)
+		if apierrors.IsNotFound(err) {
+			nl = &danateamv1.NamespaceLabel{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+			setLabels(nl, changes[name])
+			if err := p.Client.Create(ctx, nl); err != nil {
+				return err
+			}
+			fmt.Fprintf(p.Out, "namespacelabel/%s created\n", name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		orig := nl.DeepCopy()
+		setLabels(nl, changes[name])
+		if err := p.Client.Patch(ctx, nl, client.MergeFrom(orig)); err != nil {
+			return err
+		}
+		fmt.Fprintf(p.Out, "namespacelabel/%s configured\n", name)
+	}
+	return nil
+}
+
+func setLabels(nl *danateamv1.NamespaceLabel, changes map[string]*string) {
+	if nl.Spec.Labels == nil {
+		nl.Spec.Labels = map[string]string{}
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(nl.Spec.Labels, key)
+		} else {
+			nl.Spec.Labels[key] = *value
+		}
+	}
+}
+
+// Diff prints the differences between the labels the NamespaceLabels of
+// namespace request and the live labels of the Namespace, and reports
+// whether there are any. Lines start with "+" for requested labels that are
+// missing, "~" for requested labels with another live value, "-" for
+// managed labels that are no longer requested, and "!" for requested labels
+// the operator does not apply because of the policy, another NamespaceLabel
+// or a lock on an immutable key, or because the policy excludes the
+// namespace. Inherited labels depend on the parent
+// namespaces, and labels filled in by a NamespaceLabelRequirement on its
+// defaults, so they are only listed when a NamespaceLabel requests them.
+func (p *Plugin) Diff(ctx context.Context, namespace string) (bool, error) {
+	s, err := p.load(ctx, namespace)
+	if err != nil {
+		return false, err
+	}
+	desired, labels := s.desired(), s.plan.Labels
+	keys := map[string]string{}
+	for key := range desired {
+		keys[key] = ""
+	}
+	if !s.excluded {
+		// The operator ignores the NamespaceLabels of an excluded Namespace
+		// and leaves the labels they applied in place.
+		for key := range s.owners {
+			keys[key] = ""
+		}
+	}
+
+	var b strings.Builder
+	for _, key := range sortedKeys(keys) {
+		live, present := s.ns.Labels[key]
+		nl, requested := desired[key]
+		switch {
+		case requested && !present:
+			fmt.Fprintf(&b, "+ %s=%s (NamespaceLabel %s)\n", key, labels[key], nl.Name)
+		case requested && live != labels[key]:
+			fmt.Fprintf(&b, "~ %s=%s -> %s (NamespaceLabel %s)\n", key, live, labels[key], nl.Name)
+		case !requested && present && !ownedOutsideNamespaceLabels(s.owners[key]):
+			fmt.Fprintf(&b, "- %s=%s (owned by %s, no longer requested)\n", key, live, s.owners[key])
+		}
+	}
+	for _, step := range s.plan.Steps {
+		nl := &s.nls[step.Index]
+		if !nl.DeletionTimestamp.IsZero() {
+			continue
+		}
+		requested := labelplan.Labels(nl)
+		for _, key := range sortedKeys(requested) {
+			if reason := s.notApplied(&step, key); reason != "" {
+				fmt.Fprintf(&b, "! %s=%s (NamespaceLabel %s, not applied: %s)\n", key, requested[key], nl.Name, reason)
+			}
+		}
+	}
+	if _, err := io.WriteString(p.Out, b.String()); err != nil {
+		return false, err
+	}
+	return b.Len() > 0, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
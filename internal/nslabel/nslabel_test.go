@@ -0,0 +1,399 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nslabel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/internal/config"
+)
+
+func newPlugin() (*Plugin, *strings.Builder) {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(danateamv1.AddToScheme(scheme))
+	older := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: "team-a",
+			Labels: map[string]string{
+				"team": "platform", "tier": "silver", "owner": "alice", "region": "eu", "env": "prod",
+			},
+			Annotations: map[string]string{
+				danateamv1.ManagedLabelsAnnotation: `{"team":"base","tier":"base","region":"(inherited)","env":"requirement/defaults"}`,
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{{
+				Manager: "kubectl-label", Operation: metav1.ManagedFieldsOperationUpdate, FieldsType: "FieldsV1",
+				FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:owner":{}}}}`)},
+			}},
+		}},
+		&danateamv1.NamespaceLabel{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "base", CreationTimestamp: older},
+			Spec:       danateamv1.NamespaceLabelSpec{Labels: map[string]string{"team": "platform", "tier": "gold"}},
+		},
+		&danateamv1.NamespaceLabel{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "payments"},
+			Spec:       danateamv1.NamespaceLabelSpec{Labels: map[string]string{"team": "payments", "cost-center": "42"}},
+			Status: danateamv1.NamespaceLabelStatus{Conditions: []metav1.Condition{{
+				Type: danateamv1.ConditionReady, Status: metav1.ConditionFalse, Reason: danateamv1.ReasonConflict,
+				Message: "labels owned by another NamespaceLabel are not applied: team",
+			}}},
+		},
+	).Build()
+	out := &strings.Builder{}
+	return &Plugin{Client: c, Out: out}, out
+}
+
+func TestGet(t *testing.T) {
+	p, out := newPlugin()
+	if err := p.Get(context.Background(), "team-a"); err != nil {
+		t.Fatal(err)
+	}
+	want := "KEY     VALUE     OWNER\n" +
+		"env     prod      requirement/defaults\n" +
+		"owner   alice     -\n" +
+		"region  eu        (inherited)\n" +
+		"team    platform  base\n" +
+		"tier    silver    base\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestWhy(t *testing.T) {
+	p, out := newPlugin()
+	ctx := context.Background()
+	if err := p.Why(ctx, "team-a", "team"); err != nil {
+		t.Fatal(err)
+	}
+	want := "team=platform is set by NamespaceLabel team-a/base.\n" +
+		"NamespaceLabel payments requests team=payments, not applied: owned by NamespaceLabel base.\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+
+	out.Reset()
+	if err := p.Why(ctx, "team-a", "owner"); err != nil {
+		t.Fatal(err)
+	}
+	want = "owner=alice is not managed by a NamespaceLabel.\n" +
+		"Written by field managers: kubectl-label (Update).\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+
+	out.Reset()
+	if err := p.Why(ctx, "team-a", "region"); err != nil {
+		t.Fatal(err)
+	}
+	if want = "region=eu is inherited from the parent namespaces.\n"; out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+
+	out.Reset()
+	if err := p.Why(ctx, "team-a", "env"); err != nil {
+		t.Fatal(err)
+	}
+	if want = "env=prod is filled in by NamespaceLabelRequirement defaults.\n"; out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	p, out := newPlugin()
+	differs, err := p.Diff(context.Background(), "team-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "+ cost-center=42 (NamespaceLabel payments)\n" +
+		"~ tier=silver -> gold (NamespaceLabel base)\n" +
+		"! team=payments (NamespaceLabel payments, not applied: owned by NamespaceLabel base)\n"
+	if !differs || out.String() != want {
+		t.Errorf("got %v\n%s\nwant\n%s", differs, out, want)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p, out := newPlugin()
+	ctx := context.Background()
+	if err := p.Client.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "namespacelabel-system", Name: "namespacelabel-policy"},
+		Data: map[string]string{
+			config.PolicyConfigMapKey: "protectedLabels: [cost-center]\nadoptExisting: true\n",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadPolicy(ctx, p.Client, client.ObjectKey{Namespace: "namespacelabel-system", Name: "namespacelabel-policy"},
+		config.Default().Policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !policy.AdoptExisting || !reflect.DeepEqual(policy.ProtectedLabels, []string{"cost-center"}) ||
+		!reflect.DeepEqual(policy.ProtectedPrefixes, config.DefaultProtectedPrefixes) {
+		t.Fatalf("unexpected policy %+v", policy)
+	}
+	if _, err := LoadPolicy(ctx, p.Client, client.ObjectKey{Namespace: "namespacelabel-system", Name: "missing"},
+		config.Default().Policy); err != nil {
+		t.Errorf("missing ConfigMap: %v", err)
+	}
+	p.Policy = policy
+
+	if err := p.Why(ctx, "team-a", "cost-center"); err != nil {
+		t.Fatal(err)
+	}
+	want := "cost-center is not set on namespace team-a.\n" +
+		"NamespaceLabel payments requests cost-center=42, not applied: denied by policy: the key is protected.\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+
+	out.Reset()
+	if _, err := p.Diff(ctx, "team-a"); err != nil {
+		t.Fatal(err)
+	}
+	want = "~ tier=silver -> gold (NamespaceLabel base)\n" +
+		"! cost-center=42 (NamespaceLabel payments, not applied: denied by policy: the key is protected)\n" +
+		"! team=payments (NamespaceLabel payments, not applied: owned by NamespaceLabel base)\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestManagerPolicy(t *testing.T) {
+	p, out := newPlugin()
+	ctx := context.Background()
+	key := func(name string) client.ObjectKey {
+		return client.ObjectKey{Namespace: "namespacelabel-system", Name: name}
+	}
+	for _, cm := range []*corev1.ConfigMap{{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "namespacelabel-system", Name: "namespacelabel-manager-config-5c7f"},
+		Data: map[string]string{ManagerConfigKey: "apiVersion: " + config.APIVersion + "\nkind: " + config.Kind +
+			"\npolicy:\n  protectedNamespaces: [team-a]\n"},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Namespace: "namespacelabel-system", Name: "namespacelabel-policy"},
+		Data:       map[string]string{config.PolicyConfigMapKey: "protectedLabels: [cost-center]\n"},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Namespace: "namespacelabel-system", Name: "excluded-policy"},
+		Data:       map[string]string{config.PolicyConfigMapKey: "protectedNamespaces: []\nexcludedNamespaces: [team-a]\n"},
+	}} {
+		if err := p.Client.Create(ctx, cm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := LoadManagerConfig(ctx, p.Client, key("namespacelabel-policy")); err == nil {
+		t.Error("expected an error for a ConfigMap without " + ManagerConfigKey)
+	}
+	cfg, err := LoadManagerConfig(ctx, p.Client, key("namespacelabel-manager-config-5c7f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The policy ConfigMap overrides the protected labels and keeps the
+	// protected namespaces of the configuration file.
+	p.Policy, err = LoadPolicy(ctx, p.Client, key("namespacelabel-policy"), cfg.Policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.Policy.ProtectedNamespaces, []string{"team-a"}) ||
+		!reflect.DeepEqual(p.Policy.ProtectedLabels, []string{"cost-center"}) {
+		t.Fatalf("unexpected policy %+v", p.Policy)
+	}
+	if _, err := p.Diff(ctx, "team-a"); err != nil {
+		t.Fatal(err)
+	}
+	want := "- team=platform (owned by base, no longer requested)\n" +
+		"- tier=silver (owned by base, no longer requested)\n" +
+		"! cost-center=42 (NamespaceLabel payments, not applied: denied by policy: the namespace is protected)\n" +
+		"! team=payments (NamespaceLabel payments, not applied: denied by policy: the namespace is protected)\n" +
+		"! team=platform (NamespaceLabel base, not applied: denied by policy: the namespace is protected)\n" +
+		"! tier=gold (NamespaceLabel base, not applied: denied by policy: the namespace is protected)\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+
+	out.Reset()
+	p.Policy, err = LoadPolicy(ctx, p.Client, key("excluded-policy"), cfg.Policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	differs, err := p.Diff(ctx, "team-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The operator ignores the NamespaceLabels and leaves their labels alone.
+	want = "! cost-center=42 (NamespaceLabel payments, not applied: the namespace is excluded by the policy)\n" +
+		"! team=payments (NamespaceLabel payments, not applied: the namespace is excluded by the policy)\n" +
+		"! team=platform (NamespaceLabel base, not applied: the namespace is excluded by the policy)\n" +
+		"! tier=gold (NamespaceLabel base, not applied: the namespace is excluded by the policy)\n"
+	if !differs || out.String() != want {
+		t.Errorf("got %v\n%s\nwant\n%s", differs, out, want)
+	}
+
+	out.Reset()
+	if err := p.Why(ctx, "team-a", "tier"); err != nil {
+		t.Fatal(err)
+	}
+	want = "tier=silver is set by NamespaceLabel team-a/base.\n" +
+		"NamespaceLabel base requests tier=gold, not applied: the namespace is excluded by the policy.\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestLocks(t *testing.T) {
+	p, out := newPlugin()
+	ctx := context.Background()
+	ns := &corev1.Namespace{}
+	if err := p.Client.Get(ctx, client.ObjectKey{Name: "team-a"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	ns.Annotations[danateamv1.ImmutableLabelsAnnotation] = `{"tier":"silver"}`
+	if err := p.Client.Update(ctx, ns); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Why(ctx, "team-a", "tier"); err != nil {
+		t.Fatal(err)
+	}
+	want := "tier=silver is set by NamespaceLabel team-a/base.\n" +
+		"It is immutable until listed in the danateam.namespacelabel.io/unlock-immutable-labels annotation of the namespace.\n" +
+		"NamespaceLabel base requests tier=gold, not applied: immutable and kept at \"silver\".\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+
+	out.Reset()
+	if _, err := p.Diff(ctx, "team-a"); err != nil {
+		t.Fatal(err)
+	}
+	want = "+ cost-center=42 (NamespaceLabel payments)\n" +
+		"! team=payments (NamespaceLabel payments, not applied: owned by NamespaceLabel base)\n" +
+		"! tier=gold (NamespaceLabel base, not applied: immutable and kept at \"silver\")\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestSetAndUnset(t *testing.T) {
+	p, out := newPlugin()
+	ctx := context.Background()
+	if err := p.Set(ctx, "team-a", DefaultName, map[string]string{"tier": "bronze", "env": "prod"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "namespacelabel/base configured\nnamespacelabel/kubectl-nslabel created\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+	expectLabels := func(name string, want map[string]string) {
+		t.Helper()
+		nl := &danateamv1.NamespaceLabel{}
+		if err := p.Client.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: name}, nl); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(nl.Spec.Labels, want) {
+			t.Errorf("%s requests %v, want %v", name, nl.Spec.Labels, want)
+		}
+	}
+	expectLabels("base", map[string]string{"team": "platform", "tier": "bronze"})
+	expectLabels(DefaultName, map[string]string{"env": "prod"})
+
+	if err := p.Unset(ctx, "team-a", "", []string{"team"}); err != nil {
+		t.Fatal(err)
+	}
+	expectLabels("base", map[string]string{"tier": "bronze"})
+	expectLabels("payments", map[string]string{"cost-center": "42"})
+}
+
+func TestExport(t *testing.T) {
+	p, _ := newPlugin()
+	ctx := context.Background()
+	if err := p.Client.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "legacy",
+		Labels: map[string]string{"kubernetes.io/metadata.name": "legacy", "team": "search", "example.com/tier": "gold"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	manifests, err := p.Export(ctx, ExportOptions{Name: "adopted"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := WriteManifests(&out, manifests); err != nil {
+		t.Fatal(err)
+	}
+	want := `apiVersion: danateam.namespacelabel.io/v1
+kind: NamespaceLabel
+metadata:
+  name: adopted
+  namespace: legacy
+spec:
+  labels:
+    example.com/tier: gold
+    team: search
+---
+apiVersion: danateam.namespacelabel.io/v1
+kind: NamespaceLabel
+metadata:
+  name: adopted
+  namespace: team-a
+spec:
+  labels:
+    owner: alice
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+
+	manifests, err = p.Export(ctx, ExportOptions{Name: "adopted", Prefixes: []string{"example.com/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := WriteFiles(dir, manifests, true); err != nil {
+		t.Fatal(err)
+	}
+	root, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n- legacy\n"; string(root) != want {
+		t.Errorf("kustomization.yaml = %q, want %q", root, want)
+	}
+	manifest, err := os.ReadFile(filepath.Join(dir, "legacy", "namespacelabel.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(manifest), "example.com/tier: gold") || strings.Contains(string(manifest), "team: search") {
+		t.Errorf("namespacelabel.yaml = %s", manifest)
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ratelimit limits how fast the controller retries reconciles and
+// writes to the API server.
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/matanamar10/namesapcelabel/internal/metrics"
+)
+
+// NewControllerRateLimiter returns the workqueue rate limiter of a
+// controller. A request is delayed by the larger of a per-item exponential
+// backoff between baseDelay and maxDelay, which grows with every failed
+// reconcile of that request, and an overall token bucket of qps and burst
+// shared by all requests.
+func NewControllerRateLimiter(baseDelay, maxDelay time.Duration, qps float64,
+	burst int) workqueue.TypedRateLimiter[reconcile.Request] {
+	return workqueue.NewTypedMaxOfRateLimiter(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](baseDelay, maxDelay),
+		&workqueue.TypedBucketRateLimiter[reconcile.Request]{Limiter: rate.NewLimiter(rate.Limit(qps), burst)},
+	)
+}
+
+// WrapClient returns a client whose writes, including status writes, share a
+// budget of qps writes per second with bursts of up to burst writes. Writes
+// block until the budget allows them or their context ends. Reads are not
+// limited. c is returned unchanged when qps is zero.
+func WrapClient(c client.Client, qps float64, burst int) client.Client {
+	if qps == 0 {
+		return c
+	}
+	return &limitedClient{Client: c, limiter: rate.NewLimiter(rate.Limit(qps), burst)}
+}
+
+type limitedClient struct {
+	client.Client
+	limiter *rate.Limiter
+}
+
+func (c *limitedClient) wait(ctx context.Context) error {
+	start := time.Now()
+	err := c.limiter.Wait(ctx)
+	metrics.WriteBudgetWait.Observe(time.Since(start).Seconds())
+	return err
+}
+
+func (c *limitedClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *limitedClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *limitedClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *limitedClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *limitedClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *limitedClient) Status() client.SubResourceWriter {
+	return &limitedStatusWriter{client: c, writer: c.Client.Status()}
+}
+
+type limitedStatusWriter struct {
+	client *limitedClient
+	writer client.SubResourceWriter
+}
+
+func (w *limitedStatusWriter) Create(ctx context.Context, obj client.Object, subResource client.Object,
+	opts ...client.SubResourceCreateOption) error {
+	if err := w.client.wait(ctx); err != nil {
+		return err
+	}
+	return w.writer.Create(ctx, obj, subResource, opts...)
+}
+
+func (w *limitedStatusWriter) Update(ctx context.Context, obj client.Object,
+	opts ...client.SubResourceUpdateOption) error {
+	if err := w.client.wait(ctx); err != nil {
+		return err
+	}
+	return w.writer.Update(ctx, obj, opts...)
+}
+
+func (w *limitedStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.SubResourcePatchOption) error {
+	if err := w.client.wait(ctx); err != nil {
+		return err
+	}
+	return w.writer.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestControllerRateLimiterBackoff(t *testing.T) {
+	limiter := NewControllerRateLimiter(10*time.Millisecond, 40*time.Millisecond, 1000, 1000)
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "team-a", Name: "labels"}}
+
+	var delays []time.Duration
+	for i := 0; i < 4; i++ {
+		delays = append(delays, limiter.When(req))
+	}
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond}
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Fatalf("delays = %v, want %v", delays, want)
+		}
+	}
+
+	limiter.Forget(req)
+	if d := limiter.When(req); d != 10*time.Millisecond {
+		t.Fatalf("delay after Forget = %v, want the base delay", d)
+	}
+}
+
+func TestWrapClientLimitsWrites(t *testing.T) {
+	c := WrapClient(fake.NewClientBuilder().Build(), 1, 1)
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+	if err := c.Create(context.Background(), ns); err != nil {
+		t.Fatalf("first write is within the burst: %v", err)
+	}
+
+	// Reads are not limited.
+	for i := 0; i < 5; i++ {
+		if err := c.Get(context.Background(), client.ObjectKeyFromObject(ns), ns); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	ns.Labels = map[string]string{"team": "a"}
+	if err := c.Update(ctx, ns); err == nil {
+		t.Fatal("expected the second write to wait beyond the deadline")
+	}
+}
+
+func TestWrapClientUnlimited(t *testing.T) {
+	base := fake.NewClientBuilder().Build()
+	if c := WrapClient(base, 0, 0); c != base {
+		t.Fatal("expected the client to be returned unchanged when qps is zero")
+	}
+}
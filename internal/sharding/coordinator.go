@@ -0,0 +1,396 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/matanamar10/namesapcelabel/internal/metrics"
+)
+
+// Labels of the Leases managed by a Coordinator.
+const (
+	// GroupLabel holds the Coordinator's Name on all of its Leases.
+	GroupLabel = "sharding.namespacelabel.io/group"
+	// RoleLabel tells shard Leases from the Leases replicas announce themselves with.
+	RoleLabel = "sharding.namespacelabel.io/role"
+
+	roleShard  = "shard"
+	roleMember = "member"
+)
+
+// releaseTimeout bounds how long a stopping Coordinator tries to hand its
+// shards over to the other replicas.
+const releaseTimeout = 5 * time.Second
+
+// now is replaced in tests.
+var now = time.Now
+
+// Coordinator shares Shards shards between the replicas that run one. Each
+// replica renews a member Lease; the live members split the shards evenly in
+// the order of their identities. A replica only owns a shard while it holds
+// the shard's Lease, which it takes once the previous holder released it or
+// stopped renewing it, so a shard is never owned by two replicas at once.
+//
+// When a replica stops, its shards are released; when it dies, they are taken
+// over once its Leases expire. A replica that fails to renew stops owning its
+// shards SafetyMargin before its Leases expire, so that clock skew between
+// replicas and the latency of the renewal cannot make two replicas own a
+// shard at once.
+type Coordinator struct {
+	// Client must not read from the manager's cache.
+	Client client.Client
+	// Namespace and Name locate the Leases: Name-<shard> for the shards and
+	// Name-member-<hash> for the replicas.
+	Namespace string
+	Name      string
+	// Identity is unique to this replica, such as its Pod name.
+	Identity string
+	Shards   int
+
+	// LeaseDuration is how long a Lease that is no longer renewed is
+	// respected. Leases are renewed and shards rebalanced every RenewPeriod.
+	LeaseDuration time.Duration
+	RenewPeriod   time.Duration
+	// SafetyMargin is how long before its Lease expires a shard is no longer
+	// owned. It must be less than LeaseDuration minus RenewPeriod.
+	SafetyMargin time.Duration
+
+	// OnAcquire is called with the shards this replica starts to own, so that
+	// their NamespaceLabels can be reconciled.
+	OnAcquire func(ctx context.Context, shards []int)
+	// OnRelease is called with the shards this replica stopped owning,
+	// including those it gives up when it stops.
+	OnRelease func(ctx context.Context, shards []int)
+
+	mu sync.RWMutex
+	// renewed holds, for each shard Lease held by this replica, when it was
+	// last renewed.
+	renewed map[int]time.Time
+	// owned are the shards owned after the last sync, from which OnRelease
+	// learns which ones were lost, including those whose renewal failed.
+	owned []int
+}
+
+// Owns implements Filter.
+func (c *Coordinator) Owns(namespace string) bool {
+	return c.owns(Of(namespace, c.Shards), now())
+}
+
+func (c *Coordinator) owns(shard int, at time.Time) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	renewed, ok := c.renewed[shard]
+	return ok && c.valid(renewed, at)
+}
+
+// valid reports whether a shard Lease renewed at renewed is still owned at
+// at: until SafetyMargin before it expires.
+func (c *Coordinator) valid(renewed, at time.Time) bool {
+	return at.Sub(renewed) < c.LeaseDuration-c.SafetyMargin
+}
+
+// Owned returns the shards owned by this replica.
+func (c *Coordinator) Owned() []int {
+	at := now()
+	var owned []int
+	for shard := 0; shard < c.Shards; shard++ {
+		if c.owns(shard, at) {
+			owned = append(owned, shard)
+		}
+	}
+	return owned
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every replica
+// runs a Coordinator.
+func (c *Coordinator) NeedLeaderElection() bool {
+	return false
+}
+
+// Start renews the Leases and rebalances the shards until ctx is done, then
+// releases the shards of this replica.
+func (c *Coordinator) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithValues("identity", c.Identity, "shards", c.Shards)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := c.sync(ctx); err != nil {
+			logger.Error(err, "failed to sync shard leases", "owned", c.Owned())
+		}
+	}, c.RenewPeriod)
+
+	releaseCtx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+	if err := c.release(releaseCtx); err != nil {
+		logger.Error(err, "failed to release shard leases; they are taken over once expired")
+	}
+	return nil
+}
+
+// sync renews the member Lease of this replica, then takes or renews the
+// shard Leases assigned to it and releases the others.
+func (c *Coordinator) sync(ctx context.Context) error {
+	at := now()
+	if err := c.renewMember(ctx, at); err != nil {
+		return err
+	}
+
+	list := &coordinationv1.LeaseList{}
+	if err := c.Client.List(ctx, list, client.InNamespace(c.Namespace), client.MatchingLabels{GroupLabel: c.Name}); err != nil {
+		return err
+	}
+	shards := map[string]*coordinationv1.Lease{}
+	var members []string
+	for i := range list.Items {
+		lease := &list.Items[i]
+		switch lease.Labels[RoleLabel] {
+		case roleShard:
+			shards[lease.Name] = lease
+		case roleMember:
+			if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == c.Identity {
+				continue
+			}
+			if c.expired(lease, at) {
+				// Replicas that died are forgotten; errors are retried on the next sync.
+				_ = client.IgnoreNotFound(c.Client.Delete(ctx, lease))
+				continue
+			}
+			members = append(members, *lease.Spec.HolderIdentity)
+		}
+	}
+	members = append(members, c.Identity)
+	sort.Strings(members)
+	self := sort.SearchStrings(members, c.Identity)
+
+	var acquired []int
+	var errs []error
+	for shard := 0; shard < c.Shards; shard++ {
+		assigned := shard%len(members) == self
+		held, err := c.syncShard(ctx, shard, shards[c.shardLeaseName(shard)], assigned, at)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		c.mu.Lock()
+		last, ok := c.renewed[shard]
+		wasOwned := ok && c.valid(last, at)
+		if held {
+			c.renewed[shard] = at
+		} else if err == nil {
+			// The shard was released or is held by another replica. A shard
+			// whose Lease failed to sync stays owned until SafetyMargin
+			// before the Lease expires.
+			delete(c.renewed, shard)
+		}
+		c.mu.Unlock()
+		if held && !wasOwned {
+			acquired = append(acquired, shard)
+		}
+	}
+
+	owned := c.Owned()
+	metrics.OwnedShards.Set(float64(len(owned)))
+	released := missing(c.owned, owned)
+	c.owned = owned
+	if len(released) > 0 {
+		log.FromContext(ctx).Info("released shards", "released", released, "owned", owned, "members", len(members))
+		if c.OnRelease != nil {
+			c.OnRelease(ctx, released)
+		}
+	}
+	if len(acquired) > 0 {
+		log.FromContext(ctx).Info("acquired shards", "acquired", acquired, "owned", owned, "members", len(members))
+		if c.OnAcquire != nil {
+			c.OnAcquire(ctx, acquired)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d shard leases failed to sync, first error: %w", len(errs), c.Shards, errs[0])
+	}
+	return nil
+}
+
+// syncShard takes or renews the Lease of shard when it is assigned to this
+// replica, and releases it otherwise. It reports whether this replica holds
+// the Lease afterwards.
+func (c *Coordinator) syncShard(ctx context.Context, shard int, lease *coordinationv1.Lease, assigned bool, at time.Time) (bool, error) {
+	if !assigned {
+		if lease == nil || !c.holds(lease) {
+			return false, nil
+		}
+		// Stop owning the shard before another replica can take it.
+		c.mu.Lock()
+		delete(c.renewed, shard)
+		c.mu.Unlock()
+		lease.Spec.HolderIdentity = nil
+		return false, c.Client.Update(ctx, lease)
+	}
+
+	if lease == nil {
+		lease = c.newLease(c.shardLeaseName(shard), roleShard, at)
+		lease.Spec.LeaseTransitions = ptr.To[int32](0)
+		if err := c.Client.Create(ctx, lease); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if !c.holds(lease) {
+		if lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity != "" && !c.expired(lease, at) {
+			// Wait until the current holder releases the shard or dies.
+			return false, nil
+		}
+		lease.Spec.HolderIdentity = ptr.To(c.Identity)
+		lease.Spec.AcquireTime = &metav1.MicroTime{Time: at}
+		lease.Spec.LeaseTransitions = ptr.To(ptr.Deref(lease.Spec.LeaseTransitions, 0) + 1)
+	}
+	lease.Spec.LeaseDurationSeconds = ptr.To(int32(c.LeaseDuration / time.Second))
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: at}
+	if err := c.Client.Update(ctx, lease); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// renewMember creates or renews the member Lease announcing this replica.
+func (c *Coordinator) renewMember(ctx context.Context, at time.Time) error {
+	c.mu.Lock()
+	if c.renewed == nil {
+		c.renewed = map[int]time.Time{}
+	}
+	c.mu.Unlock()
+
+	lease := &coordinationv1.Lease{}
+	err := c.Client.Get(ctx, client.ObjectKey{Namespace: c.Namespace, Name: c.memberLeaseName()}, lease)
+	if apierrors.IsNotFound(err) {
+		return c.Client.Create(ctx, c.newLease(c.memberLeaseName(), roleMember, at))
+	}
+	if err != nil {
+		return err
+	}
+	lease.Spec.HolderIdentity = ptr.To(c.Identity)
+	lease.Spec.LeaseDurationSeconds = ptr.To(int32(c.LeaseDuration / time.Second))
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: at}
+	return c.Client.Update(ctx, lease)
+}
+
+// release gives up the shards of this replica and removes its member Lease,
+// so the other replicas take its shards over without waiting for expiry.
+func (c *Coordinator) release(ctx context.Context) error {
+	c.mu.Lock()
+	held := c.renewed
+	c.renewed = map[int]time.Time{}
+	c.mu.Unlock()
+	metrics.OwnedShards.Set(0)
+	if released := c.owned; len(released) > 0 && c.OnRelease != nil {
+		c.OnRelease(ctx, released)
+	}
+	c.owned = nil
+
+	// Every Lease is released even if another one fails, so that as few
+	// shards as possible wait for expiry.
+	var errs []error
+	for shard := range held {
+		lease := &coordinationv1.Lease{}
+		if err := c.Client.Get(ctx, client.ObjectKey{Namespace: c.Namespace, Name: c.shardLeaseName(shard)}, lease); err != nil {
+			if !apierrors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if !c.holds(lease) {
+			continue
+		}
+		lease.Spec.HolderIdentity = nil
+		if err := c.Client.Update(ctx, lease); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	member := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: c.Namespace, Name: c.memberLeaseName()}}
+	if err := c.Client.Delete(ctx, member); client.IgnoreNotFound(err) != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Coordinator) newLease(name, role string, at time.Time) *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: c.Namespace,
+			Name:      name,
+			Labels:    map[string]string{GroupLabel: c.Name, RoleLabel: role},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       ptr.To(c.Identity),
+			LeaseDurationSeconds: ptr.To(int32(c.LeaseDuration / time.Second)),
+			AcquireTime:          &metav1.MicroTime{Time: at},
+			RenewTime:            &metav1.MicroTime{Time: at},
+		},
+	}
+}
+
+func (c *Coordinator) holds(lease *coordinationv1.Lease) bool {
+	return ptr.Deref(lease.Spec.HolderIdentity, "") == c.Identity
+}
+
+// expired reports whether lease has not been renewed within its duration.
+func (c *Coordinator) expired(lease *coordinationv1.Lease, at time.Time) bool {
+	if lease.Spec.RenewTime == nil {
+		return true
+	}
+	duration := time.Duration(ptr.Deref(lease.Spec.LeaseDurationSeconds, 0)) * time.Second
+	return !at.Before(lease.Spec.RenewTime.Add(duration))
+}
+
+func (c *Coordinator) shardLeaseName(shard int) string {
+	return c.Name + "-" + strconv.Itoa(shard)
+}
+
+// memberLeaseName hashes the identity, which need not be a valid object name.
+func (c *Coordinator) memberLeaseName() string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(c.Identity))
+	return fmt.Sprintf("%s-member-%08x", c.Name, h.Sum32())
+}
+
+// missing returns the shards of before that are not in after.
+func missing(before, after []int) []int {
+	var shards []int
+	for _, shard := range before {
+		found := false
+		for _, s := range after {
+			found = found || s == shard
+		}
+		if !found {
+			shards = append(shards, shard)
+		}
+	}
+	return shards
+}
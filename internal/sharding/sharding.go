@@ -0,0 +1,49 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sharding spreads the namespaces of a cluster across several active
+// manager replicas. Every namespace is hashed into one of a fixed number of
+// shards, and a replica only reconciles the namespaces of the shards it owns.
+//
+// A replica either owns one shard given by its configuration (Static) or
+// shares all shards with the other replicas through Leases (Coordinator).
+package sharding
+
+import "hash/fnv"
+
+// Filter decides which namespaces a replica reconciles.
+type Filter interface {
+	// Owns reports whether namespace belongs to a shard owned by this replica.
+	Owns(namespace string) bool
+}
+
+// Of returns the shard of namespace among shards.
+func Of(namespace string, shards int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(namespace))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// Static is a Filter for a replica that owns the single shard Index.
+type Static struct {
+	Index  int
+	Shards int
+}
+
+// Owns implements Filter.
+func (s Static) Owns(namespace string) bool {
+	return Of(namespace, s.Shards) == s.Index
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestStaticSpreadsNamespaces(t *testing.T) {
+	const shards = 4
+	owners := make([]int, shards)
+	for i := 0; i < 1000; i++ {
+		ns := fmt.Sprintf("tenant-%d", i)
+		owned := 0
+		for index := 0; index < shards; index++ {
+			if (Static{Index: index, Shards: shards}).Owns(ns) {
+				owners[index]++
+				owned++
+			}
+		}
+		if owned != 1 {
+			t.Fatalf("%s is owned by %d shards", ns, owned)
+		}
+	}
+	for index, count := range owners {
+		if count < 200 {
+			t.Errorf("shard %d owns only %d of 1000 namespaces: %v", index, count, owners)
+		}
+	}
+}
+
+func TestCoordinatorRebalances(t *testing.T) {
+	current := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	ctx := context.Background()
+	c := fake.NewClientBuilder().Build()
+	acquired, released := map[string][]int{}, map[string][]int{}
+	replica := func(identity string) *Coordinator {
+		return &Coordinator{
+			Client: c, Namespace: "system", Name: "shard", Identity: identity, Shards: 4,
+			LeaseDuration: 15 * time.Second, RenewPeriod: 5 * time.Second,
+			OnAcquire: func(_ context.Context, shards []int) {
+				acquired[identity] = append(acquired[identity], shards...)
+			},
+			OnRelease: func(_ context.Context, shards []int) {
+				released[identity] = append(released[identity], shards...)
+			},
+		}
+	}
+	sync := func(coordinators ...*Coordinator) {
+		t.Helper()
+		for _, coordinator := range coordinators {
+			if err := coordinator.sync(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		current = current.Add(5 * time.Second)
+	}
+	expectOwned := func(coordinator *Coordinator, want []int) {
+		t.Helper()
+		if got := coordinator.Owned(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s owns %v, want %v", coordinator.Identity, got, want)
+		}
+	}
+
+	a, b := replica("a"), replica("b")
+	sync(a)
+	expectOwned(a, []int{0, 1, 2, 3})
+
+	// b joins: a releases the shards assigned to b before b takes them.
+	sync(b)
+	expectOwned(b, nil)
+	sync(a, b)
+	expectOwned(a, []int{0, 2})
+	expectOwned(b, []int{1, 3})
+	if !reflect.DeepEqual(acquired["b"], []int{1, 3}) {
+		t.Fatalf("b acquired %v", acquired["b"])
+	}
+	if !reflect.DeepEqual(released["a"], []int{1, 3}) {
+		t.Fatalf("a released %v", released["a"])
+	}
+
+	// a dies: b takes its shards over once a's Leases expire.
+	sync(b)
+	sync(b)
+	expectOwned(b, []int{1, 3})
+	sync(b)
+	expectOwned(b, []int{0, 1, 2, 3})
+	if !reflect.DeepEqual(acquired["b"], []int{1, 3, 0, 2}) {
+		t.Fatalf("b acquired %v", acquired["b"])
+	}
+
+	// b stops: its shards are released immediately.
+	if err := b.release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expectOwned(b, nil)
+	if !reflect.DeepEqual(released["b"], []int{0, 1, 2, 3}) {
+		t.Fatalf("b released %v", released["b"])
+	}
+	a = replica("a")
+	sync(a)
+	expectOwned(a, []int{0, 1, 2, 3})
+
+	if !a.Owns("tenant-1") {
+		t.Errorf("a owns all shards but not tenant-1")
+	}
+}
+
+func TestCoordinatorSafetyMargin(t *testing.T) {
+	current := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	c := &Coordinator{
+		Client: fake.NewClientBuilder().Build(), Namespace: "system", Name: "shard", Identity: "a", Shards: 2,
+		LeaseDuration: 15 * time.Second, RenewPeriod: 5 * time.Second, SafetyMargin: 3 * time.Second,
+	}
+	if err := c.sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Renewals fail from now on: ownership ends before the Leases expire.
+	current = current.Add(11 * time.Second)
+	if got := c.Owned(); !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Fatalf("owned %v before the safety margin, want all shards", got)
+	}
+	current = current.Add(time.Second)
+	if got := c.Owned(); got != nil {
+		t.Fatalf("owned %v within the safety margin before expiry, want none", got)
+	}
+}
+
+func TestCoordinatorReleasesPastMissingLeases(t *testing.T) {
+	ctx := context.Background()
+	c := &Coordinator{
+		Client: fake.NewClientBuilder().Build(), Namespace: "system", Name: "shard", Identity: "a", Shards: 3,
+		LeaseDuration: 15 * time.Second, RenewPeriod: 5 * time.Second,
+	}
+	if err := c.sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	missing := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: "system", Name: c.shardLeaseName(0)}}
+	if err := c.Client.Delete(ctx, missing); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for shard := 1; shard < 3; shard++ {
+		lease := &coordinationv1.Lease{}
+		if err := c.Client.Get(ctx, client.ObjectKey{Namespace: "system", Name: c.shardLeaseName(shard)}, lease); err != nil {
+			t.Fatal(err)
+		}
+		if lease.Spec.HolderIdentity != nil {
+			t.Errorf("shard %d is still held by %s", shard, *lease.Spec.HolderIdentity)
+		}
+	}
+	member := &coordinationv1.Lease{}
+	err := c.Client.Get(ctx, client.ObjectKey{Namespace: "system", Name: c.memberLeaseName()}, member)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("member Lease: %v, want it deleted", err)
+	}
+}
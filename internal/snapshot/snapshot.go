@@ -0,0 +1,311 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package snapshot takes point-in-time copies of the labels and annotations
+// of Namespaces and restores them, to recover from labels being changed or
+// wiped by mistake.
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/pkg/labelplan"
+)
+
+// ConfigMapKey is the key of the snapshot in the ConfigMaps it is stored in.
+const ConfigMapKey = "snapshot.yaml"
+
+// Snapshot holds the labels and annotations of Namespaces at a point in time.
+type Snapshot struct {
+	Taken      metav1.Time `json:"taken"`
+	Namespaces []Namespace `json:"namespaces"`
+}
+
+// Namespace holds the labels and annotations of a single Namespace.
+type Namespace struct {
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Take returns a snapshot of the Namespaces named by names, or of those
+// matching selector when names is empty. The kubectl last-applied
+// configuration annotation is left out, since it is not meant to be restored.
+func Take(ctx context.Context, c client.Client, selector labels.Selector, names []string) (*Snapshot, error) {
+	var namespaces []metav1.PartialObjectMetadata
+	if len(names) == 0 {
+		list := &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("NamespaceList"))
+		if err := c.List(ctx, list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, fmt.Errorf("listing namespaces: %w", err)
+		}
+		namespaces = list.Items
+	}
+	for _, name := range names {
+		ns := metav1.PartialObjectMetadata{}
+		ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+		if err := c.Get(ctx, client.ObjectKey{Name: name}, &ns); err != nil {
+			return nil, fmt.Errorf("getting namespace %s: %w", name, err)
+		}
+		namespaces = append(namespaces, ns)
+	}
+
+	snap := &Snapshot{Taken: metav1.Now(), Namespaces: []Namespace{}}
+	for i := range namespaces {
+		ns := &namespaces[i]
+		annotations := copyMap(ns.Annotations)
+		delete(annotations, corev1.LastAppliedConfigAnnotation)
+		snap.Namespaces = append(snap.Namespaces, Namespace{
+			Name:        ns.Name,
+			Labels:      copyMap(ns.Labels),
+			Annotations: annotations,
+		})
+	}
+	sort.Slice(snap.Namespaces, func(i, j int) bool { return snap.Namespaces[i].Name < snap.Namespaces[j].Name })
+	return snap, nil
+}
+
+// Encode returns snap as YAML.
+func (s *Snapshot) Encode() ([]byte, error) {
+	return yaml.Marshal(s)
+}
+
+// Decode strictly decodes a snapshot encoded with Encode.
+func Decode(data []byte) (*Snapshot, error) {
+	snap := &Snapshot{}
+	if err := yaml.UnmarshalStrict(data, snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Save stores snap in the ConfigMap key, creating or replacing it.
+func Save(ctx context.Context, c client.Client, key client.ObjectKey, snap *Snapshot) error {
+	data, err := snap.Encode()
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{}
+	err = c.Get(ctx, key, cm)
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Data:       map[string]string{ConfigMapKey: string(data)},
+		}
+		return c.Create(ctx, cm)
+	}
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[ConfigMapKey] = string(data)
+	return c.Update(ctx, cm)
+}
+
+// Load reads the snapshot stored in the ConfigMap key.
+func Load(ctx context.Context, c client.Client, key client.ObjectKey) (*Snapshot, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, key, cm); err != nil {
+		return nil, err
+	}
+	data, ok := cm.Data[ConfigMapKey]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %s has no %s key", key, ConfigMapKey)
+	}
+	return Decode([]byte(data))
+}
+
+// Scope selects what Restore restores.
+type Scope string
+
+const (
+	// ScopeManaged restores the labels the operator managed when the snapshot
+	// was taken, and its annotations.
+	ScopeManaged Scope = "managed"
+	// ScopeAll restores every label and annotation of the snapshot.
+	ScopeAll Scope = "all"
+)
+
+// Change is a label or annotation Restore sets on a Namespace.
+type Change struct {
+	Namespace string `json:"namespace"`
+	// Field is "label" or "annotation".
+	Field string `json:"field"`
+	Key   string `json:"key"`
+	// Old is the live value, when Existed.
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new"`
+	Existed bool   `json:"existed"`
+}
+
+// Report describes what Restore changed, or would change in a dry run.
+type Report struct {
+	DryRun  bool     `json:"dryRun"`
+	Changes []Change `json:"changes"`
+	// Missing lists the Namespaces of the snapshot that no longer exist.
+	Missing []string `json:"missing"`
+}
+
+// Restore sets the labels and annotations of snap in scope that are missing
+// from the Namespaces or have another value. Keys added since the snapshot
+// was taken are left alone, and so are Namespaces that no longer exist.
+// Nothing is changed when dryRun is set.
+func Restore(ctx context.Context, c client.Client, snap *Snapshot, scope Scope, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun, Changes: []Change{}, Missing: []string{}}
+	for _, want := range snap.Namespaces {
+		ns := &metav1.PartialObjectMetadata{}
+		ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+		if err := c.Get(ctx, client.ObjectKey{Name: want.Name}, ns); apierrors.IsNotFound(err) {
+			report.Missing = append(report.Missing, want.Name)
+			continue
+		} else if err != nil {
+			return report, fmt.Errorf("getting namespace %s: %w", want.Name, err)
+		}
+
+		wantLabels, wantAnnotations := want.Labels, want.Annotations
+		if scope == ScopeManaged {
+			wantLabels, wantAnnotations = managed(want)
+		}
+		// Get may clear the type of metadata objects; patches need it.
+		ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+		orig := ns.DeepCopy()
+		changes := restore(ns.Name, "label", ns.Labels, wantLabels)
+		changes = append(changes, restore(ns.Name, "annotation", ns.Annotations, wantAnnotations)...)
+		if len(changes) == 0 {
+			continue
+		}
+		for _, change := range changes {
+			if change.Field == "label" {
+				ns.Labels = set(ns.Labels, change.Key, change.New)
+			} else {
+				ns.Annotations = set(ns.Annotations, change.Key, change.New)
+			}
+		}
+		if !dryRun {
+			if err := c.Patch(ctx, ns, client.MergeFrom(orig)); err != nil {
+				return report, fmt.Errorf("restoring namespace %s: %w", ns.Name, err)
+			}
+		}
+		report.Changes = append(report.Changes, changes...)
+	}
+	return report, nil
+}
+
+// managed returns the labels the operator managed according to the ownership
+// annotation of ns or locked according to its immutable labels annotation,
+// and the operator's annotations.
+func managed(ns Namespace) (map[string]string, map[string]string) {
+	labels, annotations := map[string]string{}, map[string]string{}
+	owners := map[string]string{}
+	if value := ns.Annotations[danateamv1.ManagedLabelsAnnotation]; value != "" {
+		// A malformed annotation is restored as is, but manages no labels.
+		_ = json.Unmarshal([]byte(value), &owners)
+	}
+	// Locked labels may have outlived their owners.
+	locked, _ := labelplan.Locks(ns.Annotations)
+	for key := range locked {
+		owners[key] = ""
+	}
+	for key := range owners {
+		if value, ok := ns.Labels[key]; ok {
+			labels[key] = value
+		}
+	}
+	for _, key := range []string{
+		danateamv1.ManagedLabelsAnnotation, danateamv1.DesiredStateAnnotation, danateamv1.ImmutableLabelsAnnotation,
+	} {
+		if value, ok := ns.Annotations[key]; ok {
+			annotations[key] = value
+		}
+	}
+	return labels, annotations
+}
+
+// restore returns the changes that set want on live.
+func restore(namespace, field string, live, want map[string]string) []Change {
+	var changes []Change
+	for _, key := range sortedKeys(want) {
+		old, existed := live[key]
+		if existed && old == want[key] {
+			continue
+		}
+		changes = append(changes, Change{
+			Namespace: namespace, Field: field, Key: key, Old: old, New: want[key], Existed: existed,
+		})
+	}
+	return changes
+}
+
+// WriteDiff writes a line for every change in the report to w: "+" for keys
+// that are added and "~" for keys that get another value.
+func (r *Report) WriteDiff(w io.Writer) error {
+	var b strings.Builder
+	for _, c := range r.Changes {
+		if c.Existed {
+			fmt.Fprintf(&b, "namespace/%s: ~ %s %s=%s -> %s\n", c.Namespace, c.Field, c.Key, c.Old, c.New)
+		} else {
+			fmt.Fprintf(&b, "namespace/%s: + %s %s=%s\n", c.Namespace, c.Field, c.Key, c.New)
+		}
+	}
+	for _, name := range r.Missing {
+		fmt.Fprintf(&b, "namespace/%s: not found, skipped\n", name)
+	}
+	if len(r.Changes) == 0 {
+		b.WriteString("nothing to restore\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func set(m map[string]string, key, value string) map[string]string {
+	if m == nil {
+		m = map[string]string{}
+	}
+	m[key] = value
+	return m
+}
+
+func copyMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
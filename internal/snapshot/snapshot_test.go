@@ -0,0 +1,155 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+)
+
+func newClient() client.Client {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "team-a",
+			Labels: map[string]string{"team": "platform", "tier": "gold", "owner": "alice", "environment": "prod"},
+			Annotations: map[string]string{
+				danateamv1.ManagedLabelsAnnotation:   `{"team":"labels","tier":"labels"}`,
+				danateamv1.ImmutableLabelsAnnotation: `{"environment":"prod"}`,
+				corev1.LastAppliedConfigAnnotation:   "{}",
+				"note":                               "kept",
+			},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "unselected",
+			Labels: map[string]string{"team": "payments"},
+		}},
+	).Build()
+}
+
+// wipe removes every label and annotation of the namespace team-a but one.
+func wipe(t *testing.T, c client.Client) {
+	t.Helper()
+	ns := &corev1.Namespace{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "team-a"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	ns.Labels = map[string]string{"tier": "bronze"}
+	ns.Annotations = nil
+	if err := c.Update(context.Background(), ns); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTakeAndRestore(t *testing.T) {
+	ctx := context.Background()
+	c := newClient()
+
+	snap, err := Take(ctx, c, labels.SelectorFromSet(labels.Set{"team": "platform"}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Namespace{{
+		Name:   "team-a",
+		Labels: map[string]string{"team": "platform", "tier": "gold", "owner": "alice", "environment": "prod"},
+		Annotations: map[string]string{
+			danateamv1.ManagedLabelsAnnotation:   `{"team":"labels","tier":"labels"}`,
+			danateamv1.ImmutableLabelsAnnotation: `{"environment":"prod"}`,
+			"note":                               "kept",
+		},
+	}}
+	if !reflect.DeepEqual(snap.Namespaces, want) {
+		t.Fatalf("snapshot = %+v, want %+v", snap.Namespaces, want)
+	}
+
+	key := client.ObjectKey{Namespace: "default", Name: "labels-snapshot"}
+	if err := Save(ctx, c, key, snap); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err = Load(ctx, c, key); err != nil {
+		t.Fatal(err)
+	}
+	wipe(t, c)
+
+	report, err := Restore(ctx, c, snap, ScopeManaged, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := report.WriteDiff(&out); err != nil {
+		t.Fatal(err)
+	}
+	wantDiff := "namespace/team-a: + label environment=prod\n" +
+		"namespace/team-a: + label team=platform\n" +
+		"namespace/team-a: ~ label tier=bronze -> gold\n" +
+		`namespace/team-a: + annotation danateam.namespacelabel.io/immutable-labels={"environment":"prod"}` + "\n" +
+		`namespace/team-a: + annotation danateam.namespacelabel.io/managed-labels={"team":"labels","tier":"labels"}` + "\n"
+	if out.String() != wantDiff {
+		t.Errorf("diff:\n%s\nwant:\n%s", out.String(), wantDiff)
+	}
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: "team-a"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ns.Labels, map[string]string{"tier": "bronze"}) {
+		t.Fatalf("dry run changed the labels to %v", ns.Labels)
+	}
+
+	if _, err := Restore(ctx, c, snap, ScopeManaged, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Name: "team-a"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ns.Labels, map[string]string{"team": "platform", "tier": "gold", "environment": "prod"}) ||
+		ns.Annotations[danateamv1.ImmutableLabelsAnnotation] != `{"environment":"prod"}` {
+		t.Errorf("managed restore set labels %v and annotations %v", ns.Labels, ns.Annotations)
+	}
+
+	if _, err := Restore(ctx, c, snap, ScopeAll, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Name: "team-a"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ns.Labels, want[0].Labels) || ns.Annotations["note"] != "kept" {
+		t.Errorf("full restore set labels %v and annotations %v", ns.Labels, ns.Annotations)
+	}
+
+	snap.Namespaces = append(snap.Namespaces, Namespace{Name: "deleted"})
+	report, err = Restore(ctx, c, snap, ScopeAll, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changes) != 0 || !reflect.DeepEqual(report.Missing, []string{"deleted"}) {
+		t.Errorf("report = %+v, want only deleted missing", report)
+	}
+}
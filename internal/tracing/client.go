@@ -0,0 +1,152 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/trace"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// WrapClient returns a client that creates a span for every call to c.
+// Reads are usually served from the informer cache, so their spans separate
+// cache latency from API server writes.
+func WrapClient(c client.Client) client.Client {
+	return &tracingClient{Client: c}
+}
+
+type tracingClient struct {
+	client.Client
+}
+
+func (c *tracingClient) start(ctx context.Context, op string, obj client.Object) (context.Context, trace.Span) {
+	kind := fmt.Sprintf("%T", obj)
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+	ctx, span := Tracer().Start(ctx, op+" "+kind, trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(AttrKind.String(kind))
+	if ns := obj.GetNamespace(); ns != "" {
+		span.SetAttributes(AttrNamespace.String(ns))
+	}
+	if name := obj.GetName(); name != "" {
+		span.SetAttributes(AttrName.String(name))
+	}
+	return ctx, span
+}
+
+// end ends span, treating NotFound as an expected outcome rather than an error.
+func end(span trace.Span, err error) {
+	if apierrors.IsNotFound(err) {
+		err = nil
+	}
+	End(span, err)
+}
+
+func (c *tracingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object,
+	opts ...client.GetOption) error {
+	ctx, span := c.start(ctx, "Get", obj)
+	span.SetAttributes(AttrName.String(key.Name))
+	if key.Namespace != "" {
+		span.SetAttributes(AttrNamespace.String(key.Namespace))
+	}
+	err := c.Client.Get(ctx, key, obj, opts...)
+	end(span, err)
+	return err
+}
+
+func (c *tracingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	kind := fmt.Sprintf("%T", list)
+	if gvk, err := apiutil.GVKForObject(list, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+	ctx, span := Tracer().Start(ctx, "List "+kind, trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(AttrKind.String(kind))
+	listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+	if listOpts.Namespace != "" {
+		span.SetAttributes(AttrNamespace.String(listOpts.Namespace))
+	}
+	err := c.Client.List(ctx, list, opts...)
+	end(span, err)
+	return err
+}
+
+func (c *tracingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	ctx, span := c.start(ctx, "Create", obj)
+	err := c.Client.Create(ctx, obj, opts...)
+	end(span, err)
+	return err
+}
+
+func (c *tracingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	ctx, span := c.start(ctx, "Update", obj)
+	err := c.Client.Update(ctx, obj, opts...)
+	end(span, err)
+	return err
+}
+
+func (c *tracingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	ctx, span := c.start(ctx, "Patch", obj)
+	err := c.Client.Patch(ctx, obj, patch, opts...)
+	end(span, err)
+	return err
+}
+
+func (c *tracingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	ctx, span := c.start(ctx, "Delete", obj)
+	err := c.Client.Delete(ctx, obj, opts...)
+	end(span, err)
+	return err
+}
+
+func (c *tracingClient) Status() client.SubResourceWriter {
+	return &tracingStatusWriter{client: c, writer: c.Client.Status()}
+}
+
+type tracingStatusWriter struct {
+	client *tracingClient
+	writer client.SubResourceWriter
+}
+
+func (w *tracingStatusWriter) Create(ctx context.Context, obj client.Object, subResource client.Object,
+	opts ...client.SubResourceCreateOption) error {
+	ctx, span := w.client.start(ctx, "CreateStatus", obj)
+	err := w.writer.Create(ctx, obj, subResource, opts...)
+	end(span, err)
+	return err
+}
+
+func (w *tracingStatusWriter) Update(ctx context.Context, obj client.Object,
+	opts ...client.SubResourceUpdateOption) error {
+	ctx, span := w.client.start(ctx, "UpdateStatus", obj)
+	err := w.writer.Update(ctx, obj, opts...)
+	end(span, err)
+	return err
+}
+
+func (w *tracingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.SubResourcePatchOption) error {
+	ctx, span := w.client.start(ctx, "PatchStatus", obj)
+	err := w.writer.Patch(ctx, obj, patch, opts...)
+	end(span, err)
+	return err
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// recordSpans installs a tracer provider recording spans in memory in place
+// of an OTLP collector.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestWrapClient(t *testing.T) {
+	recorder := recordSpans(t)
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments"}}
+	c := WrapClient(fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns).Build())
+	ctx := context.Background()
+
+	got := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "payments"}, got); err != nil {
+		t.Fatal(err)
+	}
+	orig := got.DeepCopy()
+	got.Labels = map[string]string{"team": "payments"}
+	if err := c.Patch(ctx, got, client.MergeFrom(orig)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: "missing"}, &corev1.Namespace{}); err == nil {
+		t.Fatal("expected NotFound")
+	}
+	if err := c.List(ctx, &corev1.ConfigMapList{}, client.InNamespace("payments")); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	want := []string{"Get Namespace", "Patch Namespace", "Get Namespace", "List ConfigMapList"}
+	if len(spans) != len(want) {
+		t.Fatalf("got %d spans, want %d", len(spans), len(want))
+	}
+	for i, span := range spans {
+		if span.Name() != want[i] {
+			t.Errorf("span %d: got name %q, want %q", i, span.Name(), want[i])
+		}
+		if span.Status().Code == codes.Error {
+			t.Errorf("span %q: unexpected error status", span.Name())
+		}
+	}
+	if !hasAttribute(spans[1].Attributes(), AttrName.String("payments")) {
+		t.Errorf("patch span is missing the object name: %v", spans[1].Attributes())
+	}
+	if !hasAttribute(spans[3].Attributes(), AttrNamespace.String("payments")) {
+		t.Errorf("list span is missing the namespace: %v", spans[3].Attributes())
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, attr := range attrs {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// QueueTimer measures how long requests wait in a controller's workqueue
+// before they are reconciled, so reconcile spans can report the wait that
+// precedes them. The workqueue_queue_duration_seconds metric of the
+// controller has the same measurement in aggregate. A QueueTimer serves a
+// single controller; its zero value is ready to use.
+type QueueTimer struct {
+	mu sync.Mutex
+	// ready is when each queued request became ready to be reconciled.
+	ready map[reconcile.Request]time.Time
+	// waited is how long each request handed to a worker waited.
+	waited map[reconcile.Request]time.Duration
+	now    func() time.Time
+}
+
+// NewQueue returns the controller's default rate limited workqueue, timing the
+// requests added to it. It is meant for controller.Options.NewQueue.
+func (t *QueueTimer) NewQueue(name string,
+	rateLimiter workqueue.TypedRateLimiter[reconcile.Request]) workqueue.TypedRateLimitingInterface[reconcile.Request] {
+	return &timedQueue{
+		TypedRateLimitingInterface: workqueue.NewTypedRateLimitingQueueWithConfig(rateLimiter,
+			workqueue.TypedRateLimitingQueueConfig[reconcile.Request]{Name: name}),
+		rateLimiter: rateLimiter,
+		timer:       t,
+	}
+}
+
+// Waited returns how long req waited in the workqueue before it was handed to
+// the worker now reconciling it. It reports false when the wait is unknown,
+// such as for a request requeued after a delay while it was still queued.
+func (t *QueueTimer) Waited(req reconcile.Request) (time.Duration, bool) {
+	if t == nil {
+		return 0, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	wait, ok := t.waited[req]
+	delete(t.waited, req)
+	return wait, ok
+}
+
+// readyAt records that req is ready to be reconciled at ready, unless it was
+// ready earlier already.
+func (t *QueueTimer) readyAt(req reconcile.Request, ready time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ready == nil {
+		t.ready = map[reconcile.Request]time.Time{}
+	}
+	if earlier, ok := t.ready[req]; !ok || ready.Before(earlier) {
+		t.ready[req] = ready
+	}
+}
+
+// dequeued records the wait of req when it is handed to a worker.
+func (t *QueueTimer) dequeued(req reconcile.Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ready, ok := t.ready[req]
+	if !ok {
+		return
+	}
+	delete(t.ready, req)
+	if t.waited == nil {
+		t.waited = map[reconcile.Request]time.Duration{}
+	}
+	t.waited[req] = max(t.clock().Sub(ready), 0)
+}
+
+func (t *QueueTimer) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// timedQueue reports the requests added to and taken from a workqueue to a
+// QueueTimer.
+type timedQueue struct {
+	workqueue.TypedRateLimitingInterface[reconcile.Request]
+	rateLimiter workqueue.TypedRateLimiter[reconcile.Request]
+	timer       *QueueTimer
+}
+
+func (q *timedQueue) Add(req reconcile.Request) {
+	q.timer.readyAt(req, q.timer.clock())
+	q.TypedRateLimitingInterface.Add(req)
+}
+
+func (q *timedQueue) AddAfter(req reconcile.Request, delay time.Duration) {
+	q.timer.readyAt(req, q.timer.clock().Add(delay))
+	q.TypedRateLimitingInterface.AddAfter(req, delay)
+}
+
+// AddRateLimited asks the rate limiter for the delay itself, as the wrapped
+// queue does, so the delayed request is timed from when it becomes ready.
+func (q *timedQueue) AddRateLimited(req reconcile.Request) {
+	q.AddAfter(req, q.rateLimiter.When(req))
+}
+
+func (q *timedQueue) Get() (reconcile.Request, bool) {
+	req, shutdown := q.TypedRateLimitingInterface.Get()
+	if !shutdown {
+		q.timer.dequeued(req)
+	}
+	return req, shutdown
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestQueueTimer(t *testing.T) {
+	now := time.Unix(1000, 0)
+	timer := &QueueTimer{now: func() time.Time { return now }}
+	queue := timer.NewQueue("", workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "team-a"}}
+	if _, ok := timer.Waited(req); ok {
+		t.Fatal("expected no wait before the request was queued")
+	}
+
+	queue.Add(req)
+	now = now.Add(2 * time.Second)
+	queue.Add(req)
+	now = now.Add(3 * time.Second)
+	got, _ := queue.Get()
+	if wait, ok := timer.Waited(got); !ok || wait != 5*time.Second {
+		t.Errorf("Waited = %v, %v; want 5s since the first add", wait, ok)
+	}
+	if _, ok := timer.Waited(got); ok {
+		t.Error("expected the wait to be reported once")
+	}
+	queue.Done(got)
+
+	// A delayed request waits from when its delay is over.
+	queue.AddAfter(req, time.Millisecond)
+	got, _ = queue.Get()
+	if wait, ok := timer.Waited(got); !ok || wait != 0 {
+		t.Errorf("Waited = %v, %v; want 0 as the fake clock did not pass the delay", wait, ok)
+	}
+	queue.Done(got)
+
+	var nilTimer *QueueTimer
+	if _, ok := nilTimer.Waited(req); ok {
+		t.Error("nil timer should report no wait")
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracing instruments the manager with OpenTelemetry. Spans are
+// created for every reconcile and every API call made through a client
+// returned by WrapClient, and are exported over OTLP once Setup is called.
+// Until then the global no-op tracer provider is used and instrumentation has
+// no effect.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const instrumentationName = "github.com/matanamar10/namesapcelabel"
+
+// Attribute keys set on spans.
+const (
+	AttrNamespace                 = attribute.Key("k8s.namespace.name")
+	AttrNamespaceLabel            = attribute.Key("danateam.namespacelabel.name")
+	AttrNamespaceLabelRequirement = attribute.Key("danateam.namespacelabelrequirement.name")
+	AttrKind                      = attribute.Key("k8s.object.kind")
+	AttrName                      = attribute.Key("k8s.object.name")
+	// AttrQueueWait is the time in seconds a request waited in the
+	// workqueue before its reconcile started.
+	AttrQueueWait = attribute.Key("workqueue.wait_seconds")
+)
+
+// Options configures the OTLP exporter.
+type Options struct {
+	// Endpoint is the host:port of the OTLP gRPC collector.
+	Endpoint string
+	// Insecure disables TLS towards the collector.
+	Insecure bool
+	// SampleRatio is the fraction of traces that are sampled. Traces whose
+	// parent is sampled, such as those started by admission requests, are
+	// always sampled.
+	SampleRatio float64
+	// ServiceName is reported as the service.name resource attribute.
+	ServiceName string
+}
+
+// Setup installs a global tracer provider exporting to opts.Endpoint and the
+// W3C trace context propagator. The returned function flushes pending spans
+// and must be called before the process exits.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	exporterOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		exporterOpts = append(exporterOpts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(opts.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("creating tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer used by the operator.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// End records err on span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// WrapWebhookServer returns a webhook server that extracts the trace context
+// of incoming admission requests, so webhook spans join the API server's
+// trace when the API server has tracing enabled.
+func WrapWebhookServer(server webhook.Server) webhook.Server {
+	return &webhookServer{Server: server}
+}
+
+type webhookServer struct {
+	webhook.Server
+}
+
+// Register wraps hook with trace context extraction before registering it.
+func (s *webhookServer) Register(path string, hook http.Handler) {
+	s.Server.Register(path, otelhttp.NewHandler(hook, "admission "+path))
+}
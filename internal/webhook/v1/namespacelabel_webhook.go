@@ -0,0 +1,266 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1 holds the admission webhooks of the danateam v1 API.
+package v1
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/pkg/labelplan"
+	"github.com/matanamar10/namesapcelabel/pkg/namerule"
+)
+
+// log is for logging in this package.
+var namespacelabellog = logf.Log.WithName("namespacelabel-resource")
+
+// SetupNamespaceLabelWebhookWithManager registers the webhook for NamespaceLabel in the manager.
+func SetupNamespaceLabelWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&danateamv1.NamespaceLabel{}).
+		WithValidator(&NamespaceLabelCustomValidator{Reader: mgr.GetAPIReader()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-danateam-namespacelabel-io-v1-namespacelabel,mutating=false,failurePolicy=fail,sideEffects=None,groups=danateam.namespacelabel.io,resources=namespacelabels,verbs=create;update,versions=v1,name=vnamespacelabel-v1.kb.io,admissionReviewVersions=v1
+
+// NamespaceLabelCustomValidator rejects invalid labels and name rules, and
+// changes to the immutable labels a NamespaceLabel applied or its Namespace
+// locks unless the Namespace unlocks them.
+type NamespaceLabelCustomValidator struct {
+	// Reader reads the Namespace of a NamespaceLabel. It is only used for
+	// NamespaceLabels that request labels, or change them, and should not be a
+	// cache, which may hold a subset of the Namespaces.
+	Reader client.Reader
+}
+
+var _ webhook.CustomValidator = &NamespaceLabelCustomValidator{}
+
+// ValidateCreate rejects invalid labels and name rules, and labels that
+// differ from the value their key is locked at on the Namespace, and warns
+// about immutable keys that are not requested.
+func (v *NamespaceLabelCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	nl, ok := obj.(*danateamv1.NamespaceLabel)
+	if !ok {
+		return nil, fmt.Errorf("expected a NamespaceLabel object but got %T", obj)
+	}
+	if errs := validateSpec(nl); len(errs) > 0 {
+		return nil, invalid(nl, errs)
+	}
+	if len(labelplan.Labels(nl)) == 0 {
+		return unrequested(nl), nil
+	}
+	ns, err := v.namespace(ctx, nl.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := lockedChanges(nil, nl, ns)
+	if err != nil {
+		return nil, err
+	}
+	var errs field.ErrorList
+	for _, key := range sortedKeys(locked) {
+		errs = append(errs, lockedError(key, locked[key], nl.Namespace))
+	}
+	if len(errs) > 0 {
+		return nil, invalid(nl, errs)
+	}
+	return unrequested(nl), nil
+}
+
+// ValidateUpdate rejects invalid labels and name rules, and an update that
+// changes or removes a label applied as immutable, or that removes it from spec.immutable, and one that requests
+// a new value for a key locked on the Namespace, unless the key is listed in
+// the danateamv1.UnlockImmutableLabelsAnnotation of the Namespace.
+func (v *NamespaceLabelCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	old, ok := oldObj.(*danateamv1.NamespaceLabel)
+	if !ok {
+		return nil, fmt.Errorf("expected a NamespaceLabel object for the oldObj but got %T", oldObj)
+	}
+	nl, ok := newObj.(*danateamv1.NamespaceLabel)
+	if !ok {
+		return nil, fmt.Errorf("expected a NamespaceLabel object for the newObj but got %T", newObj)
+	}
+
+	if errs := validateSpec(nl); len(errs) > 0 {
+		return nil, invalid(nl, errs)
+	}
+	changed := changedImmutable(old, nl)
+	if len(changed) == 0 && equality.Semantic.DeepEqual(labelplan.Labels(old), labelplan.Labels(nl)) {
+		return unrequested(nl), nil
+	}
+	namespacelabellog.V(1).Info("validating change to labels",
+		"namespace", nl.Namespace, "name", nl.Name, "immutable", changed)
+	ns, err := v.namespace(ctx, nl.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	unlocked := labelplan.Unlocked(ns.Annotations)
+
+	locked, err := lockedChanges(old, nl, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs field.ErrorList
+	for _, key := range sortedKeys(locked) {
+		if !contains(changed, key) {
+			errs = append(errs, lockedError(key, locked[key], nl.Namespace))
+		}
+	}
+	for _, key := range changed {
+		if contains(unlocked, key) {
+			continue
+		}
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "labels").Key(key), fmt.Sprintf(
+			"label is immutable once applied and must stay %q; list the key in the %s annotation of "+
+				"namespace %s to change it", old.Status.AppliedLabels[key], danateamv1.UnlockImmutableLabelsAnnotation,
+			nl.Namespace)))
+	}
+	if len(errs) > 0 {
+		return nil, invalid(nl, errs)
+	}
+	return unrequested(nl), nil
+}
+
+// ValidateDelete allows every deletion: the immutable labels of a deleted
+// NamespaceLabel stay on the Namespace.
+func (v *NamespaceLabelCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// namespace reads the Namespace name.
+func (v *NamespaceLabelCustomValidator) namespace(ctx context.Context, name string) (*metav1.PartialObjectMetadata, error) {
+	ns := &metav1.PartialObjectMetadata{}
+	ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+	if err := v.Reader.Get(ctx, client.ObjectKey{Name: name}, ns); err != nil {
+		return nil, fmt.Errorf("getting namespace %s: %w", name, err)
+	}
+	return ns, nil
+}
+
+// lockedChanges returns the keys nl requests with another value than the
+// one they are locked at in the danateamv1.ImmutableLabelsAnnotation of ns,
+// with their locked values, unless they are unlocked or old, if any, already
+// requested that value. The locks outlive the NamespaceLabels that applied
+// them, so they also hold for new ones.
+func lockedChanges(old, nl *danateamv1.NamespaceLabel, ns *metav1.PartialObjectMetadata) (map[string]string, error) {
+	locked, err := labelplan.Locks(ns.Annotations)
+	if err != nil {
+		return nil, fmt.Errorf("namespace %s: %w", ns.Name, err)
+	}
+	unlocked := labelplan.Unlocked(ns.Annotations)
+	requested := labelplan.Labels(nl)
+	var before map[string]string
+	if old != nil {
+		before = labelplan.Labels(old)
+	}
+
+	changes := map[string]string{}
+	for key, lockedValue := range locked {
+		value, ok := requested[key]
+		if !ok || value == lockedValue || contains(unlocked, key) {
+			continue
+		}
+		if previous, ok := before[key]; ok && previous == value {
+			continue
+		}
+		changes[key] = lockedValue
+	}
+	return changes, nil
+}
+
+func lockedError(key, value, namespace string) *field.Error {
+	return field.Forbidden(field.NewPath("spec", "labels").Key(key), fmt.Sprintf(
+		"label is locked at %q on namespace %s; list the key in the %s annotation of the namespace to change it",
+		value, namespace, danateamv1.UnlockImmutableLabelsAnnotation))
+}
+
+// validateSpec returns the errors in the labels and name rules of nl. The
+// labels are checked like the API server checks Namespace labels, since the
+// labels of every NamespaceLabel of a namespace are written together.
+func validateSpec(nl *danateamv1.NamespaceLabel) field.ErrorList {
+	errs := labelplan.ValidateLabels(nl.Spec.Labels, field.NewPath("spec", "labels"))
+	_, ruleErrs := namerule.Compile(nl.Spec.NameRules, field.NewPath("spec", "nameRules"))
+	return append(errs, ruleErrs...)
+}
+
+func invalid(nl *danateamv1.NamespaceLabel, errs field.ErrorList) error {
+	return apierrors.NewInvalid(danateamv1.GroupVersion.WithKind("NamespaceLabel").GroupKind(), nl.Name, errs)
+}
+
+// changedImmutable returns the keys old applied as immutable that nl no
+// longer requests with the same value, or removes from spec.immutable.
+func changedImmutable(old, nl *danateamv1.NamespaceLabel) []string {
+	var keys []string
+	for _, key := range labelplan.Immutable(old) {
+		applied, ok := old.Status.AppliedLabels[key]
+		if !ok {
+			continue
+		}
+		value, ok := labelplan.Labels(nl)[key]
+		unlisted := contains(old.Spec.Immutable, key) && !contains(nl.Spec.Immutable, key)
+		if !ok || value != applied || unlisted {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// unrequested returns a warning for every immutable key of nl that its
+// labels do not request.
+func unrequested(nl *danateamv1.NamespaceLabel) admission.Warnings {
+	var warnings admission.Warnings
+	for _, key := range nl.Spec.Immutable {
+		if _, ok := labelplan.Labels(nl)[key]; !ok {
+			warnings = append(warnings, fmt.Sprintf("spec.immutable: key %q is not in spec.labels", key))
+		}
+	}
+	return warnings
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
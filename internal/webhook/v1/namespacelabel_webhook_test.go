@@ -0,0 +1,199 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+)
+
+func newValidator(annotations map[string]string) *NamespaceLabelCustomValidator {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	return &NamespaceLabelCustomValidator{Reader: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: annotations}},
+	).Build()}
+}
+
+// applied returns a NamespaceLabel that applied environment=prod as immutable
+// and team=platform.
+func applied() *danateamv1.NamespaceLabel {
+	return &danateamv1.NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "base"},
+		Spec: danateamv1.NamespaceLabelSpec{
+			Labels:    map[string]string{"environment": "prod", "team": "platform"},
+			Immutable: []string{"environment"},
+		},
+		Status: danateamv1.NamespaceLabelStatus{
+			AppliedLabels:   map[string]string{"environment": "prod", "team": "platform"},
+			ImmutableLabels: []string{"environment"},
+		},
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		update func(nl *danateamv1.NamespaceLabel)
+		// unapplied clears the status of the old object.
+		unapplied   bool
+		annotations map[string]string
+		wantErr     string
+	}{{
+		name:   "allows changes to other labels",
+		update: func(nl *danateamv1.NamespaceLabel) { nl.Spec.Labels["team"] = "payments" },
+	}, {
+		name:    "rejects changing an immutable label",
+		update:  func(nl *danateamv1.NamespaceLabel) { nl.Spec.Labels["environment"] = "dev" },
+		wantErr: `spec.labels[environment]: Forbidden: label is immutable once applied and must stay "prod"`,
+	}, {
+		name:    "rejects removing an immutable label",
+		update:  func(nl *danateamv1.NamespaceLabel) { delete(nl.Spec.Labels, "environment") },
+		wantErr: "spec.labels[environment]: Forbidden",
+	}, {
+		name:    "rejects removing a key from spec.immutable",
+		update:  func(nl *danateamv1.NamespaceLabel) { nl.Spec.Immutable = nil },
+		wantErr: "spec.labels[environment]: Forbidden",
+	}, {
+		name:        "allows changing unlocked labels",
+		update:      func(nl *danateamv1.NamespaceLabel) { nl.Spec.Labels["environment"] = "dev" },
+		annotations: map[string]string{danateamv1.UnlockImmutableLabelsAnnotation: "data-classification, environment"},
+	}, {
+		name:      "allows changing labels that were not applied yet",
+		update:    func(nl *danateamv1.NamespaceLabel) { nl.Spec.Labels["environment"] = "dev" },
+		unapplied: true,
+	}, {
+		name:        "rejects changing a label locked on the namespace",
+		update:      func(nl *danateamv1.NamespaceLabel) { nl.Spec.Labels["environment"] = "dev" },
+		unapplied:   true,
+		annotations: map[string]string{danateamv1.ImmutableLabelsAnnotation: `{"environment":"prod"}`},
+		wantErr:     `spec.labels[environment]: Forbidden: label is locked at "prod" on namespace team-a`,
+	}, {
+		name:        "reports an immutable label locked on the namespace once",
+		update:      func(nl *danateamv1.NamespaceLabel) { nl.Spec.Labels["environment"] = "dev" },
+		annotations: map[string]string{danateamv1.ImmutableLabelsAnnotation: `{"environment":"prod"}`},
+		wantErr:     `spec.labels[environment]: Forbidden: label is immutable once applied and must stay "prod"`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			old := applied()
+			if tc.unapplied {
+				old.Status = danateamv1.NamespaceLabelStatus{}
+			}
+			nl := old.DeepCopy()
+			tc.update(nl)
+			_, err := newValidator(tc.annotations).ValidateUpdate(context.Background(), old, nl)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.wantErr != "" && (!apierrors.IsInvalid(err) || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Fatalf("error = %v, want an Invalid error containing %q", err, tc.wantErr)
+			}
+			if err != nil && strings.Count(err.Error(), "spec.labels[environment]") != 1 {
+				t.Errorf("error = %v, want a single error for environment", err)
+			}
+		})
+	}
+}
+
+func TestValidateCreateWarnsAboutUnrequestedKeys(t *testing.T) {
+	nl := applied()
+	nl.Spec.Immutable = append(nl.Spec.Immutable, "data-classification")
+	warnings, err := newValidator(nil).ValidateCreate(context.Background(), nl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `"data-classification"`) {
+		t.Errorf("warnings = %v, want one for data-classification", warnings)
+	}
+}
+
+func TestValidateCreateChecksNamespaceLocks(t *testing.T) {
+	locked := map[string]string{danateamv1.ImmutableLabelsAnnotation: `{"environment":"prod"}`}
+	for _, tc := range []struct {
+		name        string
+		environment string
+		annotations map[string]string
+		wantErr     string
+	}{{
+		name:        "rejects another value for a locked key",
+		environment: "dev",
+		annotations: locked,
+		wantErr:     `spec.labels[environment]: Forbidden: label is locked at "prod" on namespace team-a`,
+	}, {
+		name:        "allows the locked value",
+		environment: "prod",
+		annotations: locked,
+	}, {
+		name:        "allows another value for an unlocked key",
+		environment: "dev",
+		annotations: map[string]string{
+			danateamv1.ImmutableLabelsAnnotation:       `{"environment":"prod"}`,
+			danateamv1.UnlockImmutableLabelsAnnotation: "environment",
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			nl := applied()
+			nl.Status = danateamv1.NamespaceLabelStatus{}
+			nl.Spec.Labels["environment"] = tc.environment
+			_, err := newValidator(tc.annotations).ValidateCreate(context.Background(), nl)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.wantErr != "" && (!apierrors.IsInvalid(err) || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Fatalf("error = %v, want an Invalid error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsInvalidLabels(t *testing.T) {
+	nl := applied()
+	nl.Spec.Labels["bad key"] = "x"
+	nl.Spec.Labels["tier"] = strings.Repeat("a", 64)
+	v := newValidator(nil)
+	_, createErr := v.ValidateCreate(context.Background(), nl)
+	_, updateErr := v.ValidateUpdate(context.Background(), applied(), nl)
+	for _, err := range []error{createErr, updateErr} {
+		if !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "spec.labels[bad key]") ||
+			!strings.Contains(err.Error(), "spec.labels[tier]") {
+			t.Errorf("error = %v, want an Invalid error for bad key and tier", err)
+		}
+	}
+}
+
+func TestValidateCreateRejectsInvalidNameRules(t *testing.T) {
+	nl := applied()
+	nl.Spec.NameRules = []danateamv1.NameRule{{
+		Pattern: `(?P<team>[a-z]+)-(?P<env>[a-z]+)`,
+		Labels:  []danateamv1.DerivedLabel{{Key: "environment", Group: "environment"}},
+	}}
+	_, err := newValidator(nil).ValidateCreate(context.Background(), nl)
+	if !apierrors.IsInvalid(err) || !strings.Contains(err.Error(), "spec.nameRules[0].labels[0].group") {
+		t.Errorf("error = %v, want an Invalid error for the group", err)
+	}
+}
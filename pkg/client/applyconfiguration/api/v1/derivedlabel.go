@@ -0,0 +1,62 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1
+
+// DerivedLabelApplyConfiguration represents a declarative configuration of the DerivedLabel type for use
+// with apply.
+type DerivedLabelApplyConfiguration struct {
+	Key    *string           `json:"key,omitempty"`
+	Group  *string           `json:"group,omitempty"`
+	Values map[string]string `json:"values,omitempty"`
+}
+
+// DerivedLabelApplyConfiguration constructs a declarative configuration of the DerivedLabel type for use with
+// apply.
+func DerivedLabel() *DerivedLabelApplyConfiguration {
+	return &DerivedLabelApplyConfiguration{}
+}
+
+// WithKey sets the Key field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Key field is set to the value of the last call.
+func (b *DerivedLabelApplyConfiguration) WithKey(value string) *DerivedLabelApplyConfiguration {
+	b.Key = &value
+	return b
+}
+
+// WithGroup sets the Group field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Group field is set to the value of the last call.
+func (b *DerivedLabelApplyConfiguration) WithGroup(value string) *DerivedLabelApplyConfiguration {
+	b.Group = &value
+	return b
+}
+
+// WithValues puts the entries into the Values field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, the entries provided by each call will be put on the Values field,
+// overwriting an existing map entries in Values field with the same key.
+func (b *DerivedLabelApplyConfiguration) WithValues(entries map[string]string) *DerivedLabelApplyConfiguration {
+	if b.Values == nil && len(entries) > 0 {
+		b.Values = make(map[string]string, len(entries))
+	}
+	for k, v := range entries {
+		b.Values[k] = v
+	}
+	return b
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1
+
+// NameRuleApplyConfiguration represents a declarative configuration of the NameRule type for use
+// with apply.
+type NameRuleApplyConfiguration struct {
+	Pattern *string                          `json:"pattern,omitempty"`
+	Labels  []DerivedLabelApplyConfiguration `json:"labels,omitempty"`
+}
+
+// NameRuleApplyConfiguration constructs a declarative configuration of the NameRule type for use with
+// apply.
+func NameRule() *NameRuleApplyConfiguration {
+	return &NameRuleApplyConfiguration{}
+}
+
+// WithPattern sets the Pattern field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Pattern field is set to the value of the last call.
+func (b *NameRuleApplyConfiguration) WithPattern(value string) *NameRuleApplyConfiguration {
+	b.Pattern = &value
+	return b
+}
+
+// WithLabels adds the given value to the Labels field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Labels field.
+func (b *NameRuleApplyConfiguration) WithLabels(values ...*DerivedLabelApplyConfiguration) *NameRuleApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithLabels")
+		}
+		b.Labels = append(b.Labels, *values[i])
+	}
+	return b
+}
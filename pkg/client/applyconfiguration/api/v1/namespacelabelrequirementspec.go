@@ -0,0 +1,79 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	v1 "k8s.io/client-go/applyconfigurations/meta/v1"
+)
+
+// NamespaceLabelRequirementSpecApplyConfiguration represents a declarative configuration of the NamespaceLabelRequirementSpec type for use
+// with apply.
+type NamespaceLabelRequirementSpecApplyConfiguration struct {
+	NamespaceSelector *v1.LabelSelectorApplyConfiguration `json:"namespaceSelector,omitempty"`
+	Labels            []RequiredLabelApplyConfiguration   `json:"labels,omitempty"`
+	NameRules         []NameRuleApplyConfiguration        `json:"nameRules,omitempty"`
+	AutoFill          *bool                               `json:"autoFill,omitempty"`
+}
+
+// NamespaceLabelRequirementSpecApplyConfiguration constructs a declarative configuration of the NamespaceLabelRequirementSpec type for use with
+// apply.
+func NamespaceLabelRequirementSpec() *NamespaceLabelRequirementSpecApplyConfiguration {
+	return &NamespaceLabelRequirementSpecApplyConfiguration{}
+}
+
+// WithNamespaceSelector sets the NamespaceSelector field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the NamespaceSelector field is set to the value of the last call.
+func (b *NamespaceLabelRequirementSpecApplyConfiguration) WithNamespaceSelector(value *v1.LabelSelectorApplyConfiguration) *NamespaceLabelRequirementSpecApplyConfiguration {
+	b.NamespaceSelector = value
+	return b
+}
+
+// WithLabels adds the given value to the Labels field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Labels field.
+func (b *NamespaceLabelRequirementSpecApplyConfiguration) WithLabels(values ...*RequiredLabelApplyConfiguration) *NamespaceLabelRequirementSpecApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithLabels")
+		}
+		b.Labels = append(b.Labels, *values[i])
+	}
+	return b
+}
+
+// WithNameRules adds the given value to the NameRules field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the NameRules field.
+func (b *NamespaceLabelRequirementSpecApplyConfiguration) WithNameRules(values ...*NameRuleApplyConfiguration) *NamespaceLabelRequirementSpecApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithNameRules")
+		}
+		b.NameRules = append(b.NameRules, *values[i])
+	}
+	return b
+}
+
+// WithAutoFill sets the AutoFill field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the AutoFill field is set to the value of the last call.
+func (b *NamespaceLabelRequirementSpecApplyConfiguration) WithAutoFill(value bool) *NamespaceLabelRequirementSpecApplyConfiguration {
+	b.AutoFill = &value
+	return b
+}
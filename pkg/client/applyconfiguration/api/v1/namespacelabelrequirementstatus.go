@@ -0,0 +1,108 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	metav1 "k8s.io/client-go/applyconfigurations/meta/v1"
+)
+
+// NamespaceLabelRequirementStatusApplyConfiguration represents a declarative configuration of the NamespaceLabelRequirementStatus type for use
+// with apply.
+type NamespaceLabelRequirementStatusApplyConfiguration struct {
+	ObservedGeneration     *int64                                    `json:"observedGeneration,omitempty"`
+	MatchedNamespaces      *int32                                    `json:"matchedNamespaces,omitempty"`
+	NonCompliantNamespaces *int32                                    `json:"nonCompliantNamespaces,omitempty"`
+	NonCompliant           []NonCompliantNamespaceApplyConfiguration `json:"nonCompliant,omitempty"`
+	UnmatchedNamespaces    *int32                                    `json:"unmatchedNamespaces,omitempty"`
+	Unmatched              []string                                  `json:"unmatched,omitempty"`
+	Conditions             []metav1.ConditionApplyConfiguration      `json:"conditions,omitempty"`
+}
+
+// NamespaceLabelRequirementStatusApplyConfiguration constructs a declarative configuration of the NamespaceLabelRequirementStatus type for use with
+// apply.
+func NamespaceLabelRequirementStatus() *NamespaceLabelRequirementStatusApplyConfiguration {
+	return &NamespaceLabelRequirementStatusApplyConfiguration{}
+}
+
+// WithObservedGeneration sets the ObservedGeneration field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ObservedGeneration field is set to the value of the last call.
+func (b *NamespaceLabelRequirementStatusApplyConfiguration) WithObservedGeneration(value int64) *NamespaceLabelRequirementStatusApplyConfiguration {
+	b.ObservedGeneration = &value
+	return b
+}
+
+// WithMatchedNamespaces sets the MatchedNamespaces field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the MatchedNamespaces field is set to the value of the last call.
+func (b *NamespaceLabelRequirementStatusApplyConfiguration) WithMatchedNamespaces(value int32) *NamespaceLabelRequirementStatusApplyConfiguration {
+	b.MatchedNamespaces = &value
+	return b
+}
+
+// WithNonCompliantNamespaces sets the NonCompliantNamespaces field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the NonCompliantNamespaces field is set to the value of the last call.
+func (b *NamespaceLabelRequirementStatusApplyConfiguration) WithNonCompliantNamespaces(value int32) *NamespaceLabelRequirementStatusApplyConfiguration {
+	b.NonCompliantNamespaces = &value
+	return b
+}
+
+// WithNonCompliant adds the given value to the NonCompliant field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the NonCompliant field.
+func (b *NamespaceLabelRequirementStatusApplyConfiguration) WithNonCompliant(values ...*NonCompliantNamespaceApplyConfiguration) *NamespaceLabelRequirementStatusApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithNonCompliant")
+		}
+		b.NonCompliant = append(b.NonCompliant, *values[i])
+	}
+	return b
+}
+
+// WithUnmatchedNamespaces sets the UnmatchedNamespaces field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the UnmatchedNamespaces field is set to the value of the last call.
+func (b *NamespaceLabelRequirementStatusApplyConfiguration) WithUnmatchedNamespaces(value int32) *NamespaceLabelRequirementStatusApplyConfiguration {
+	b.UnmatchedNamespaces = &value
+	return b
+}
+
+// WithUnmatched adds the given value to the Unmatched field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Unmatched field.
+func (b *NamespaceLabelRequirementStatusApplyConfiguration) WithUnmatched(values ...string) *NamespaceLabelRequirementStatusApplyConfiguration {
+	for i := range values {
+		b.Unmatched = append(b.Unmatched, values[i])
+	}
+	return b
+}
+
+// WithConditions adds the given value to the Conditions field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Conditions field.
+func (b *NamespaceLabelRequirementStatusApplyConfiguration) WithConditions(values ...*metav1.ConditionApplyConfiguration) *NamespaceLabelRequirementStatusApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithConditions")
+		}
+		b.Conditions = append(b.Conditions, *values[i])
+	}
+	return b
+}
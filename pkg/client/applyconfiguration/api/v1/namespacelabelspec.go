@@ -0,0 +1,69 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1
+
+// NamespaceLabelSpecApplyConfiguration represents a declarative configuration of the NamespaceLabelSpec type for use
+// with apply.
+type NamespaceLabelSpecApplyConfiguration struct {
+	Labels    map[string]string            `json:"labels,omitempty"`
+	Immutable []string                     `json:"immutable,omitempty"`
+	NameRules []NameRuleApplyConfiguration `json:"nameRules,omitempty"`
+}
+
+// NamespaceLabelSpecApplyConfiguration constructs a declarative configuration of the NamespaceLabelSpec type for use with
+// apply.
+func NamespaceLabelSpec() *NamespaceLabelSpecApplyConfiguration {
+	return &NamespaceLabelSpecApplyConfiguration{}
+}
+
+// WithLabels puts the entries into the Labels field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, the entries provided by each call will be put on the Labels field,
+// overwriting an existing map entries in Labels field with the same key.
+func (b *NamespaceLabelSpecApplyConfiguration) WithLabels(entries map[string]string) *NamespaceLabelSpecApplyConfiguration {
+	if b.Labels == nil && len(entries) > 0 {
+		b.Labels = make(map[string]string, len(entries))
+	}
+	for k, v := range entries {
+		b.Labels[k] = v
+	}
+	return b
+}
+
+// WithImmutable adds the given value to the Immutable field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Immutable field.
+func (b *NamespaceLabelSpecApplyConfiguration) WithImmutable(values ...string) *NamespaceLabelSpecApplyConfiguration {
+	for i := range values {
+		b.Immutable = append(b.Immutable, values[i])
+	}
+	return b
+}
+
+// WithNameRules adds the given value to the NameRules field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the NameRules field.
+func (b *NamespaceLabelSpecApplyConfiguration) WithNameRules(values ...*NameRuleApplyConfiguration) *NamespaceLabelSpecApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithNameRules")
+		}
+		b.NameRules = append(b.NameRules, *values[i])
+	}
+	return b
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	v1 "k8s.io/client-go/applyconfigurations/meta/v1"
+)
+
+// NamespaceLabelStatusApplyConfiguration represents a declarative configuration of the NamespaceLabelStatus type for use
+// with apply.
+type NamespaceLabelStatusApplyConfiguration struct {
+	AppliedLabels      map[string]string                `json:"appliedLabels,omitempty"`
+	ImmutableLabels    []string                         `json:"immutableLabels,omitempty"`
+	DerivedLabels      map[string]string                `json:"derivedLabels,omitempty"`
+	ObservedGeneration *int64                           `json:"observedGeneration,omitempty"`
+	PolicyRevision     *string                          `json:"policyRevision,omitempty"`
+	Conditions         []v1.ConditionApplyConfiguration `json:"conditions,omitempty"`
+}
+
+// NamespaceLabelStatusApplyConfiguration constructs a declarative configuration of the NamespaceLabelStatus type for use with
+// apply.
+func NamespaceLabelStatus() *NamespaceLabelStatusApplyConfiguration {
+	return &NamespaceLabelStatusApplyConfiguration{}
+}
+
+// WithAppliedLabels puts the entries into the AppliedLabels field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, the entries provided by each call will be put on the AppliedLabels field,
+// overwriting an existing map entries in AppliedLabels field with the same key.
+func (b *NamespaceLabelStatusApplyConfiguration) WithAppliedLabels(entries map[string]string) *NamespaceLabelStatusApplyConfiguration {
+	if b.AppliedLabels == nil && len(entries) > 0 {
+		b.AppliedLabels = make(map[string]string, len(entries))
+	}
+	for k, v := range entries {
+		b.AppliedLabels[k] = v
+	}
+	return b
+}
+
+// WithImmutableLabels adds the given value to the ImmutableLabels field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the ImmutableLabels field.
+func (b *NamespaceLabelStatusApplyConfiguration) WithImmutableLabels(values ...string) *NamespaceLabelStatusApplyConfiguration {
+	for i := range values {
+		b.ImmutableLabels = append(b.ImmutableLabels, values[i])
+	}
+	return b
+}
+
+// WithDerivedLabels puts the entries into the DerivedLabels field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, the entries provided by each call will be put on the DerivedLabels field,
+// overwriting an existing map entries in DerivedLabels field with the same key.
+func (b *NamespaceLabelStatusApplyConfiguration) WithDerivedLabels(entries map[string]string) *NamespaceLabelStatusApplyConfiguration {
+	if b.DerivedLabels == nil && len(entries) > 0 {
+		b.DerivedLabels = make(map[string]string, len(entries))
+	}
+	for k, v := range entries {
+		b.DerivedLabels[k] = v
+	}
+	return b
+}
+
+// WithObservedGeneration sets the ObservedGeneration field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ObservedGeneration field is set to the value of the last call.
+func (b *NamespaceLabelStatusApplyConfiguration) WithObservedGeneration(value int64) *NamespaceLabelStatusApplyConfiguration {
+	b.ObservedGeneration = &value
+	return b
+}
+
+// WithPolicyRevision sets the PolicyRevision field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the PolicyRevision field is set to the value of the last call.
+func (b *NamespaceLabelStatusApplyConfiguration) WithPolicyRevision(value string) *NamespaceLabelStatusApplyConfiguration {
+	b.PolicyRevision = &value
+	return b
+}
+
+// WithConditions adds the given value to the Conditions field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Conditions field.
+func (b *NamespaceLabelStatusApplyConfiguration) WithConditions(values ...*v1.ConditionApplyConfiguration) *NamespaceLabelStatusApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithConditions")
+		}
+		b.Conditions = append(b.Conditions, *values[i])
+	}
+	return b
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1
+
+// NonCompliantNamespaceApplyConfiguration represents a declarative configuration of the NonCompliantNamespace type for use
+// with apply.
+type NonCompliantNamespaceApplyConfiguration struct {
+	Namespace *string  `json:"namespace,omitempty"`
+	Missing   []string `json:"missing,omitempty"`
+	Invalid   []string `json:"invalid,omitempty"`
+}
+
+// NonCompliantNamespaceApplyConfiguration constructs a declarative configuration of the NonCompliantNamespace type for use with
+// apply.
+func NonCompliantNamespace() *NonCompliantNamespaceApplyConfiguration {
+	return &NonCompliantNamespaceApplyConfiguration{}
+}
+
+// WithNamespace sets the Namespace field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Namespace field is set to the value of the last call.
+func (b *NonCompliantNamespaceApplyConfiguration) WithNamespace(value string) *NonCompliantNamespaceApplyConfiguration {
+	b.Namespace = &value
+	return b
+}
+
+// WithMissing adds the given value to the Missing field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Missing field.
+func (b *NonCompliantNamespaceApplyConfiguration) WithMissing(values ...string) *NonCompliantNamespaceApplyConfiguration {
+	for i := range values {
+		b.Missing = append(b.Missing, values[i])
+	}
+	return b
+}
+
+// WithInvalid adds the given value to the Invalid field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Invalid field.
+func (b *NonCompliantNamespaceApplyConfiguration) WithInvalid(values ...string) *NonCompliantNamespaceApplyConfiguration {
+	for i := range values {
+		b.Invalid = append(b.Invalid, values[i])
+	}
+	return b
+}
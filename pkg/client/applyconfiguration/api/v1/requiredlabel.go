@@ -0,0 +1,56 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1
+
+// RequiredLabelApplyConfiguration represents a declarative configuration of the RequiredLabel type for use
+// with apply.
+type RequiredLabelApplyConfiguration struct {
+	Key     *string `json:"key,omitempty"`
+	Pattern *string `json:"pattern,omitempty"`
+	Default *string `json:"default,omitempty"`
+}
+
+// RequiredLabelApplyConfiguration constructs a declarative configuration of the RequiredLabel type for use with
+// apply.
+func RequiredLabel() *RequiredLabelApplyConfiguration {
+	return &RequiredLabelApplyConfiguration{}
+}
+
+// WithKey sets the Key field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Key field is set to the value of the last call.
+func (b *RequiredLabelApplyConfiguration) WithKey(value string) *RequiredLabelApplyConfiguration {
+	b.Key = &value
+	return b
+}
+
+// WithPattern sets the Pattern field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Pattern field is set to the value of the last call.
+func (b *RequiredLabelApplyConfiguration) WithPattern(value string) *RequiredLabelApplyConfiguration {
+	b.Pattern = &value
+	return b
+}
+
+// WithDefault sets the Default field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Default field is set to the value of the last call.
+func (b *RequiredLabelApplyConfiguration) WithDefault(value string) *RequiredLabelApplyConfiguration {
+	b.Default = &value
+	return b
+}
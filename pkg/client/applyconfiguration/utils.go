@@ -0,0 +1,61 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package applyconfiguration
+
+import (
+	v1 "github.com/matanamar10/namesapcelabel/api/v1"
+	apiv1 "github.com/matanamar10/namesapcelabel/pkg/client/applyconfiguration/api/v1"
+	internal "github.com/matanamar10/namesapcelabel/pkg/client/applyconfiguration/internal"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	testing "k8s.io/client-go/testing"
+)
+
+// ForKind returns an apply configuration type for the given GroupVersionKind, or nil if no
+// apply configuration type exists for the given GroupVersionKind.
+func ForKind(kind schema.GroupVersionKind) interface{} {
+	switch kind {
+	// Group=danateam.namespacelabel.io, Version=v1
+	case v1.SchemeGroupVersion.WithKind("DerivedLabel"):
+		return &apiv1.DerivedLabelApplyConfiguration{}
+	case v1.SchemeGroupVersion.WithKind("NameRule"):
+		return &apiv1.NameRuleApplyConfiguration{}
+	case v1.SchemeGroupVersion.WithKind("NamespaceLabel"):
+		return &apiv1.NamespaceLabelApplyConfiguration{}
+	case v1.SchemeGroupVersion.WithKind("NamespaceLabelRequirement"):
+		return &apiv1.NamespaceLabelRequirementApplyConfiguration{}
+	case v1.SchemeGroupVersion.WithKind("NamespaceLabelRequirementSpec"):
+		return &apiv1.NamespaceLabelRequirementSpecApplyConfiguration{}
+	case v1.SchemeGroupVersion.WithKind("NamespaceLabelRequirementStatus"):
+		return &apiv1.NamespaceLabelRequirementStatusApplyConfiguration{}
+	case v1.SchemeGroupVersion.WithKind("NamespaceLabelSpec"):
+		return &apiv1.NamespaceLabelSpecApplyConfiguration{}
+	case v1.SchemeGroupVersion.WithKind("NamespaceLabelStatus"):
+		return &apiv1.NamespaceLabelStatusApplyConfiguration{}
+	case v1.SchemeGroupVersion.WithKind("NonCompliantNamespace"):
+		return &apiv1.NonCompliantNamespaceApplyConfiguration{}
+	case v1.SchemeGroupVersion.WithKind("RequiredLabel"):
+		return &apiv1.RequiredLabelApplyConfiguration{}
+
+	}
+	return nil
+}
+
+func NewTypeConverter(scheme *runtime.Scheme) *testing.TypeConverter {
+	return &testing.TypeConverter{Scheme: scheme, TypeResolver: internal.Parser()}
+}
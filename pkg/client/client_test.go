@@ -0,0 +1,71 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/pkg/client/clientset/versioned/fake"
+	"github.com/matanamar10/namesapcelabel/pkg/client/informers/externalversions"
+)
+
+// TestClientset checks that the generated clientset, informers and listers
+// work together without controller-runtime.
+func TestClientset(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cs := fake.NewSimpleClientset(&danateamv1.NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "base"},
+		Spec:       danateamv1.NamespaceLabelSpec{Labels: map[string]string{"team": "platform"}},
+	})
+	if _, err := cs.DanateamV1().NamespaceLabels("team-b").Create(ctx, &danateamv1.NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "base"},
+		Spec:       danateamv1.NamespaceLabelSpec{Labels: map[string]string{"team": "search"}},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	factory := externalversions.NewSharedInformerFactory(cs, 0)
+	lister := factory.Danateam().V1().NamespaceLabels().Lister()
+	factory.Start(ctx.Done())
+	for informer, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			t.Fatalf("%v did not sync", informer)
+		}
+	}
+
+	all, err := lister.List(labels.Everything())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Errorf("listed %d NamespaceLabels, want 2", len(all))
+	}
+	nl, err := lister.NamespaceLabels("team-b").Get("base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"team": "search"}; !reflect.DeepEqual(nl.Spec.Labels, want) {
+		t.Errorf("team-b/base requests %v, want %v", nl.Spec.Labels, want)
+	}
+}
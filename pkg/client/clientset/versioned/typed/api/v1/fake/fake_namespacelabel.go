@@ -0,0 +1,196 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+	json "encoding/json"
+	"fmt"
+
+	v1 "github.com/matanamar10/namesapcelabel/api/v1"
+	apiv1 "github.com/matanamar10/namesapcelabel/pkg/client/applyconfiguration/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeNamespaceLabels implements NamespaceLabelInterface
+type FakeNamespaceLabels struct {
+	Fake *FakeDanateamV1
+	ns   string
+}
+
+var namespacelabelsResource = v1.SchemeGroupVersion.WithResource("namespacelabels")
+
+var namespacelabelsKind = v1.SchemeGroupVersion.WithKind("NamespaceLabel")
+
+// Get takes name of the namespaceLabel, and returns the corresponding namespaceLabel object, and an error if there is any.
+func (c *FakeNamespaceLabels) Get(ctx context.Context, name string, options metav1.GetOptions) (result *v1.NamespaceLabel, err error) {
+	emptyResult := &v1.NamespaceLabel{}
+	obj, err := c.Fake.
+		Invokes(testing.NewGetActionWithOptions(namespacelabelsResource, c.ns, name, options), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabel), err
+}
+
+// List takes label and field selectors, and returns the list of NamespaceLabels that match those selectors.
+func (c *FakeNamespaceLabels) List(ctx context.Context, opts metav1.ListOptions) (result *v1.NamespaceLabelList, err error) {
+	emptyResult := &v1.NamespaceLabelList{}
+	obj, err := c.Fake.
+		Invokes(testing.NewListActionWithOptions(namespacelabelsResource, namespacelabelsKind, c.ns, opts), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1.NamespaceLabelList{ListMeta: obj.(*v1.NamespaceLabelList).ListMeta}
+	for _, item := range obj.(*v1.NamespaceLabelList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested namespaceLabels.
+func (c *FakeNamespaceLabels) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchActionWithOptions(namespacelabelsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a namespaceLabel and creates it.  Returns the server's representation of the namespaceLabel, and an error, if there is any.
+func (c *FakeNamespaceLabels) Create(ctx context.Context, namespaceLabel *v1.NamespaceLabel, opts metav1.CreateOptions) (result *v1.NamespaceLabel, err error) {
+	emptyResult := &v1.NamespaceLabel{}
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateActionWithOptions(namespacelabelsResource, c.ns, namespaceLabel, opts), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabel), err
+}
+
+// Update takes the representation of a namespaceLabel and updates it. Returns the server's representation of the namespaceLabel, and an error, if there is any.
+func (c *FakeNamespaceLabels) Update(ctx context.Context, namespaceLabel *v1.NamespaceLabel, opts metav1.UpdateOptions) (result *v1.NamespaceLabel, err error) {
+	emptyResult := &v1.NamespaceLabel{}
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateActionWithOptions(namespacelabelsResource, c.ns, namespaceLabel, opts), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabel), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeNamespaceLabels) UpdateStatus(ctx context.Context, namespaceLabel *v1.NamespaceLabel, opts metav1.UpdateOptions) (result *v1.NamespaceLabel, err error) {
+	emptyResult := &v1.NamespaceLabel{}
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceActionWithOptions(namespacelabelsResource, "status", c.ns, namespaceLabel, opts), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabel), err
+}
+
+// Delete takes name of the namespaceLabel and deletes it. Returns an error if one occurs.
+func (c *FakeNamespaceLabels) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteActionWithOptions(namespacelabelsResource, c.ns, name, opts), &v1.NamespaceLabel{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeNamespaceLabels) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	action := testing.NewDeleteCollectionActionWithOptions(namespacelabelsResource, c.ns, opts, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1.NamespaceLabelList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched namespaceLabel.
+func (c *FakeNamespaceLabels) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.NamespaceLabel, err error) {
+	emptyResult := &v1.NamespaceLabel{}
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceActionWithOptions(namespacelabelsResource, c.ns, name, pt, data, opts, subresources...), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabel), err
+}
+
+// Apply takes the given apply declarative configuration, applies it and returns the applied namespaceLabel.
+func (c *FakeNamespaceLabels) Apply(ctx context.Context, namespaceLabel *apiv1.NamespaceLabelApplyConfiguration, opts metav1.ApplyOptions) (result *v1.NamespaceLabel, err error) {
+	if namespaceLabel == nil {
+		return nil, fmt.Errorf("namespaceLabel provided to Apply must not be nil")
+	}
+	data, err := json.Marshal(namespaceLabel)
+	if err != nil {
+		return nil, err
+	}
+	name := namespaceLabel.Name
+	if name == nil {
+		return nil, fmt.Errorf("namespaceLabel.Name must be provided to Apply")
+	}
+	emptyResult := &v1.NamespaceLabel{}
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceActionWithOptions(namespacelabelsResource, c.ns, *name, types.ApplyPatchType, data, opts.ToPatchOptions()), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabel), err
+}
+
+// ApplyStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating ApplyStatus().
+func (c *FakeNamespaceLabels) ApplyStatus(ctx context.Context, namespaceLabel *apiv1.NamespaceLabelApplyConfiguration, opts metav1.ApplyOptions) (result *v1.NamespaceLabel, err error) {
+	if namespaceLabel == nil {
+		return nil, fmt.Errorf("namespaceLabel provided to Apply must not be nil")
+	}
+	data, err := json.Marshal(namespaceLabel)
+	if err != nil {
+		return nil, err
+	}
+	name := namespaceLabel.Name
+	if name == nil {
+		return nil, fmt.Errorf("namespaceLabel.Name must be provided to Apply")
+	}
+	emptyResult := &v1.NamespaceLabel{}
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceActionWithOptions(namespacelabelsResource, c.ns, *name, types.ApplyPatchType, data, opts.ToPatchOptions(), "status"), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabel), err
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+	json "encoding/json"
+	"fmt"
+
+	v1 "github.com/matanamar10/namesapcelabel/api/v1"
+	apiv1 "github.com/matanamar10/namesapcelabel/pkg/client/applyconfiguration/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeNamespaceLabelRequirements implements NamespaceLabelRequirementInterface
+type FakeNamespaceLabelRequirements struct {
+	Fake *FakeDanateamV1
+}
+
+var namespacelabelrequirementsResource = v1.SchemeGroupVersion.WithResource("namespacelabelrequirements")
+
+var namespacelabelrequirementsKind = v1.SchemeGroupVersion.WithKind("NamespaceLabelRequirement")
+
+// Get takes name of the namespaceLabelRequirement, and returns the corresponding namespaceLabelRequirement object, and an error if there is any.
+func (c *FakeNamespaceLabelRequirements) Get(ctx context.Context, name string, options metav1.GetOptions) (result *v1.NamespaceLabelRequirement, err error) {
+	emptyResult := &v1.NamespaceLabelRequirement{}
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetActionWithOptions(namespacelabelrequirementsResource, name, options), emptyResult)
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabelRequirement), err
+}
+
+// List takes label and field selectors, and returns the list of NamespaceLabelRequirements that match those selectors.
+func (c *FakeNamespaceLabelRequirements) List(ctx context.Context, opts metav1.ListOptions) (result *v1.NamespaceLabelRequirementList, err error) {
+	emptyResult := &v1.NamespaceLabelRequirementList{}
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListActionWithOptions(namespacelabelrequirementsResource, namespacelabelrequirementsKind, opts), emptyResult)
+	if obj == nil {
+		return emptyResult, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1.NamespaceLabelRequirementList{ListMeta: obj.(*v1.NamespaceLabelRequirementList).ListMeta}
+	for _, item := range obj.(*v1.NamespaceLabelRequirementList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested namespaceLabelRequirements.
+func (c *FakeNamespaceLabelRequirements) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchActionWithOptions(namespacelabelrequirementsResource, opts))
+}
+
+// Create takes the representation of a namespaceLabelRequirement and creates it.  Returns the server's representation of the namespaceLabelRequirement, and an error, if there is any.
+func (c *FakeNamespaceLabelRequirements) Create(ctx context.Context, namespaceLabelRequirement *v1.NamespaceLabelRequirement, opts metav1.CreateOptions) (result *v1.NamespaceLabelRequirement, err error) {
+	emptyResult := &v1.NamespaceLabelRequirement{}
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateActionWithOptions(namespacelabelrequirementsResource, namespaceLabelRequirement, opts), emptyResult)
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabelRequirement), err
+}
+
+// Update takes the representation of a namespaceLabelRequirement and updates it. Returns the server's representation of the namespaceLabelRequirement, and an error, if there is any.
+func (c *FakeNamespaceLabelRequirements) Update(ctx context.Context, namespaceLabelRequirement *v1.NamespaceLabelRequirement, opts metav1.UpdateOptions) (result *v1.NamespaceLabelRequirement, err error) {
+	emptyResult := &v1.NamespaceLabelRequirement{}
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateActionWithOptions(namespacelabelrequirementsResource, namespaceLabelRequirement, opts), emptyResult)
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabelRequirement), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeNamespaceLabelRequirements) UpdateStatus(ctx context.Context, namespaceLabelRequirement *v1.NamespaceLabelRequirement, opts metav1.UpdateOptions) (result *v1.NamespaceLabelRequirement, err error) {
+	emptyResult := &v1.NamespaceLabelRequirement{}
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateSubresourceActionWithOptions(namespacelabelrequirementsResource, "status", namespaceLabelRequirement, opts), emptyResult)
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabelRequirement), err
+}
+
+// Delete takes name of the namespaceLabelRequirement and deletes it. Returns an error if one occurs.
+func (c *FakeNamespaceLabelRequirements) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteActionWithOptions(namespacelabelrequirementsResource, name, opts), &v1.NamespaceLabelRequirement{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeNamespaceLabelRequirements) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionActionWithOptions(namespacelabelrequirementsResource, opts, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1.NamespaceLabelRequirementList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched namespaceLabelRequirement.
+func (c *FakeNamespaceLabelRequirements) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.NamespaceLabelRequirement, err error) {
+	emptyResult := &v1.NamespaceLabelRequirement{}
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceActionWithOptions(namespacelabelrequirementsResource, name, pt, data, opts, subresources...), emptyResult)
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabelRequirement), err
+}
+
+// Apply takes the given apply declarative configuration, applies it and returns the applied namespaceLabelRequirement.
+func (c *FakeNamespaceLabelRequirements) Apply(ctx context.Context, namespaceLabelRequirement *apiv1.NamespaceLabelRequirementApplyConfiguration, opts metav1.ApplyOptions) (result *v1.NamespaceLabelRequirement, err error) {
+	if namespaceLabelRequirement == nil {
+		return nil, fmt.Errorf("namespaceLabelRequirement provided to Apply must not be nil")
+	}
+	data, err := json.Marshal(namespaceLabelRequirement)
+	if err != nil {
+		return nil, err
+	}
+	name := namespaceLabelRequirement.Name
+	if name == nil {
+		return nil, fmt.Errorf("namespaceLabelRequirement.Name must be provided to Apply")
+	}
+	emptyResult := &v1.NamespaceLabelRequirement{}
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceActionWithOptions(namespacelabelrequirementsResource, *name, types.ApplyPatchType, data, opts.ToPatchOptions()), emptyResult)
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabelRequirement), err
+}
+
+// ApplyStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating ApplyStatus().
+func (c *FakeNamespaceLabelRequirements) ApplyStatus(ctx context.Context, namespaceLabelRequirement *apiv1.NamespaceLabelRequirementApplyConfiguration, opts metav1.ApplyOptions) (result *v1.NamespaceLabelRequirement, err error) {
+	if namespaceLabelRequirement == nil {
+		return nil, fmt.Errorf("namespaceLabelRequirement provided to Apply must not be nil")
+	}
+	data, err := json.Marshal(namespaceLabelRequirement)
+	if err != nil {
+		return nil, err
+	}
+	name := namespaceLabelRequirement.Name
+	if name == nil {
+		return nil, fmt.Errorf("namespaceLabelRequirement.Name must be provided to Apply")
+	}
+	emptyResult := &v1.NamespaceLabelRequirement{}
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceActionWithOptions(namespacelabelrequirementsResource, *name, types.ApplyPatchType, data, opts.ToPatchOptions(), "status"), emptyResult)
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1.NamespaceLabelRequirement), err
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+
+	v1 "github.com/matanamar10/namesapcelabel/api/v1"
+	apiv1 "github.com/matanamar10/namesapcelabel/pkg/client/applyconfiguration/api/v1"
+	scheme "github.com/matanamar10/namesapcelabel/pkg/client/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// NamespaceLabelsGetter has a method to return a NamespaceLabelInterface.
+// A group's client should implement this interface.
+type NamespaceLabelsGetter interface {
+	NamespaceLabels(namespace string) NamespaceLabelInterface
+}
+
+// NamespaceLabelInterface has methods to work with NamespaceLabel resources.
+type NamespaceLabelInterface interface {
+	Create(ctx context.Context, namespaceLabel *v1.NamespaceLabel, opts metav1.CreateOptions) (*v1.NamespaceLabel, error)
+	Update(ctx context.Context, namespaceLabel *v1.NamespaceLabel, opts metav1.UpdateOptions) (*v1.NamespaceLabel, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, namespaceLabel *v1.NamespaceLabel, opts metav1.UpdateOptions) (*v1.NamespaceLabel, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.NamespaceLabel, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.NamespaceLabelList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.NamespaceLabel, err error)
+	Apply(ctx context.Context, namespaceLabel *apiv1.NamespaceLabelApplyConfiguration, opts metav1.ApplyOptions) (result *v1.NamespaceLabel, err error)
+	// Add a +genclient:noStatus comment above the type to avoid generating ApplyStatus().
+	ApplyStatus(ctx context.Context, namespaceLabel *apiv1.NamespaceLabelApplyConfiguration, opts metav1.ApplyOptions) (result *v1.NamespaceLabel, err error)
+	NamespaceLabelExpansion
+}
+
+// namespaceLabels implements NamespaceLabelInterface
+type namespaceLabels struct {
+	*gentype.ClientWithListAndApply[*v1.NamespaceLabel, *v1.NamespaceLabelList, *apiv1.NamespaceLabelApplyConfiguration]
+}
+
+// newNamespaceLabels returns a NamespaceLabels
+func newNamespaceLabels(c *DanateamV1Client, namespace string) *namespaceLabels {
+	return &namespaceLabels{
+		gentype.NewClientWithListAndApply[*v1.NamespaceLabel, *v1.NamespaceLabelList, *apiv1.NamespaceLabelApplyConfiguration](
+			"namespacelabels",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			namespace,
+			func() *v1.NamespaceLabel { return &v1.NamespaceLabel{} },
+			func() *v1.NamespaceLabelList { return &v1.NamespaceLabelList{} }),
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+
+	v1 "github.com/matanamar10/namesapcelabel/api/v1"
+	apiv1 "github.com/matanamar10/namesapcelabel/pkg/client/applyconfiguration/api/v1"
+	scheme "github.com/matanamar10/namesapcelabel/pkg/client/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// NamespaceLabelRequirementsGetter has a method to return a NamespaceLabelRequirementInterface.
+// A group's client should implement this interface.
+type NamespaceLabelRequirementsGetter interface {
+	NamespaceLabelRequirements() NamespaceLabelRequirementInterface
+}
+
+// NamespaceLabelRequirementInterface has methods to work with NamespaceLabelRequirement resources.
+type NamespaceLabelRequirementInterface interface {
+	Create(ctx context.Context, namespaceLabelRequirement *v1.NamespaceLabelRequirement, opts metav1.CreateOptions) (*v1.NamespaceLabelRequirement, error)
+	Update(ctx context.Context, namespaceLabelRequirement *v1.NamespaceLabelRequirement, opts metav1.UpdateOptions) (*v1.NamespaceLabelRequirement, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, namespaceLabelRequirement *v1.NamespaceLabelRequirement, opts metav1.UpdateOptions) (*v1.NamespaceLabelRequirement, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.NamespaceLabelRequirement, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.NamespaceLabelRequirementList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.NamespaceLabelRequirement, err error)
+	Apply(ctx context.Context, namespaceLabelRequirement *apiv1.NamespaceLabelRequirementApplyConfiguration, opts metav1.ApplyOptions) (result *v1.NamespaceLabelRequirement, err error)
+	// Add a +genclient:noStatus comment above the type to avoid generating ApplyStatus().
+	ApplyStatus(ctx context.Context, namespaceLabelRequirement *apiv1.NamespaceLabelRequirementApplyConfiguration, opts metav1.ApplyOptions) (result *v1.NamespaceLabelRequirement, err error)
+	NamespaceLabelRequirementExpansion
+}
+
+// namespaceLabelRequirements implements NamespaceLabelRequirementInterface
+type namespaceLabelRequirements struct {
+	*gentype.ClientWithListAndApply[*v1.NamespaceLabelRequirement, *v1.NamespaceLabelRequirementList, *apiv1.NamespaceLabelRequirementApplyConfiguration]
+}
+
+// newNamespaceLabelRequirements returns a NamespaceLabelRequirements
+func newNamespaceLabelRequirements(c *DanateamV1Client) *namespaceLabelRequirements {
+	return &namespaceLabelRequirements{
+		gentype.NewClientWithListAndApply[*v1.NamespaceLabelRequirement, *v1.NamespaceLabelRequirementList, *apiv1.NamespaceLabelRequirementApplyConfiguration](
+			"namespacelabelrequirements",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			"",
+			func() *v1.NamespaceLabelRequirement { return &v1.NamespaceLabelRequirement{} },
+			func() *v1.NamespaceLabelRequirementList { return &v1.NamespaceLabelRequirementList{} }),
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	time "time"
+
+	apiv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	versioned "github.com/matanamar10/namesapcelabel/pkg/client/clientset/versioned"
+	internalinterfaces "github.com/matanamar10/namesapcelabel/pkg/client/informers/externalversions/internalinterfaces"
+	v1 "github.com/matanamar10/namesapcelabel/pkg/client/listers/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// NamespaceLabelRequirementInformer provides access to a shared informer and lister for
+// NamespaceLabelRequirements.
+type NamespaceLabelRequirementInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() v1.NamespaceLabelRequirementLister
+}
+
+type namespaceLabelRequirementInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+}
+
+// NewNamespaceLabelRequirementInformer constructs a new informer for NamespaceLabelRequirement type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewNamespaceLabelRequirementInformer(client versioned.Interface, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredNamespaceLabelRequirementInformer(client, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredNamespaceLabelRequirementInformer constructs a new informer for NamespaceLabelRequirement type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredNamespaceLabelRequirementInformer(client versioned.Interface, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.DanateamV1().NamespaceLabelRequirements().List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.DanateamV1().NamespaceLabelRequirements().Watch(context.TODO(), options)
+			},
+		},
+		&apiv1.NamespaceLabelRequirement{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *namespaceLabelRequirementInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredNamespaceLabelRequirementInformer(client, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *namespaceLabelRequirementInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&apiv1.NamespaceLabelRequirement{}, f.defaultInformer)
+}
+
+func (f *namespaceLabelRequirementInformer) Lister() v1.NamespaceLabelRequirementLister {
+	return v1.NewNamespaceLabelRequirementLister(f.Informer().GetIndexer())
+}
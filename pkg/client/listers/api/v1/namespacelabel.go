@@ -0,0 +1,69 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	v1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/listers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NamespaceLabelLister helps list NamespaceLabels.
+// All objects returned here must be treated as read-only.
+type NamespaceLabelLister interface {
+	// List lists all NamespaceLabels in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1.NamespaceLabel, err error)
+	// NamespaceLabels returns an object that can list and get NamespaceLabels.
+	NamespaceLabels(namespace string) NamespaceLabelNamespaceLister
+	NamespaceLabelListerExpansion
+}
+
+// namespaceLabelLister implements the NamespaceLabelLister interface.
+type namespaceLabelLister struct {
+	listers.ResourceIndexer[*v1.NamespaceLabel]
+}
+
+// NewNamespaceLabelLister returns a new NamespaceLabelLister.
+func NewNamespaceLabelLister(indexer cache.Indexer) NamespaceLabelLister {
+	return &namespaceLabelLister{listers.New[*v1.NamespaceLabel](indexer, v1.Resource("namespacelabel"))}
+}
+
+// NamespaceLabels returns an object that can list and get NamespaceLabels.
+func (s *namespaceLabelLister) NamespaceLabels(namespace string) NamespaceLabelNamespaceLister {
+	return namespaceLabelNamespaceLister{listers.NewNamespaced[*v1.NamespaceLabel](s.ResourceIndexer, namespace)}
+}
+
+// NamespaceLabelNamespaceLister helps list and get NamespaceLabels.
+// All objects returned here must be treated as read-only.
+type NamespaceLabelNamespaceLister interface {
+	// List lists all NamespaceLabels in the indexer for a given namespace.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1.NamespaceLabel, err error)
+	// Get retrieves the NamespaceLabel from the indexer for a given namespace and name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1.NamespaceLabel, error)
+	NamespaceLabelNamespaceListerExpansion
+}
+
+// namespaceLabelNamespaceLister implements the NamespaceLabelNamespaceLister
+// interface.
+type namespaceLabelNamespaceLister struct {
+	listers.ResourceIndexer[*v1.NamespaceLabel]
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	v1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/listers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NamespaceLabelRequirementLister helps list NamespaceLabelRequirements.
+// All objects returned here must be treated as read-only.
+type NamespaceLabelRequirementLister interface {
+	// List lists all NamespaceLabelRequirements in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1.NamespaceLabelRequirement, err error)
+	// Get retrieves the NamespaceLabelRequirement from the index for a given name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1.NamespaceLabelRequirement, error)
+	NamespaceLabelRequirementListerExpansion
+}
+
+// namespaceLabelRequirementLister implements the NamespaceLabelRequirementLister interface.
+type namespaceLabelRequirementLister struct {
+	listers.ResourceIndexer[*v1.NamespaceLabelRequirement]
+}
+
+// NewNamespaceLabelRequirementLister returns a new NamespaceLabelRequirementLister.
+func NewNamespaceLabelRequirementLister(indexer cache.Indexer) NamespaceLabelRequirementLister {
+	return &namespaceLabelRequirementLister{listers.New[*v1.NamespaceLabelRequirement](indexer, v1.Resource("namespacelabelrequirement"))}
+}
@@ -0,0 +1,537 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package labelplan computes how the labels requested for a Namespace by
+// several requesters, such as NamespaceLabels, combine with its live labels:
+// which labels are added, updated and removed, which keys conflict between
+// requesters, and which a policy denies.
+//
+// Compute is a pure function of its Input, so the controller, the kubectl
+// plugin, the linter and any admission webhook resolve precedence and
+// ownership the same way.
+//
+// A key belongs to at most one requester, its owner, recorded next to the
+// labels between plans. A key requested by several requesters goes to its
+// current owner while that one still requests it, and otherwise to the
+// oldest requester; the others report a conflict. Requesters being deleted
+// release the keys they own.
+//
+// Labels a Namespace inherits from its parent namespaces are requested by
+// the request returned by Inherited, which never takes a key requested by
+// another requester.
+//
+// A key a requester applied as immutable is locked: it keeps its applied
+// value, whatever the requester or the policy now ask for, and stays on the
+// Namespace when the requester is deleted, until it is unlocked. Locks are
+// recorded on the Namespace, so they also hold against requesters created
+// after the one that applied the key.
+package labelplan
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+	"github.com/matanamar10/namesapcelabel/pkg/namerule"
+)
+
+// Op is the kind of a Change.
+type Op string
+
+const (
+	OpAdd    Op = "add"
+	OpUpdate Op = "update"
+	OpRemove Op = "remove"
+)
+
+// Request is the set of labels one requester asks for.
+type Request struct {
+	// Name identifies the requester among the requests of the Namespace.
+	Name string
+	// Labels are the requested labels.
+	Labels map[string]string
+	// Created orders the requests; older requests win keys without an owner.
+	// Requests created at the same time are ordered by Name.
+	Created time.Time
+	// Deleting is set when the requester is being removed. It requests
+	// nothing and releases the keys it owns.
+	Deleting bool
+	// Applied are the labels the requester owned after the previous plan. A
+	// key it still owns whose live value no longer matches Applied was
+	// changed by someone else, and is reported as drifted.
+	Applied map[string]string
+	// Immutable are the keys whose Applied value cannot change.
+	Immutable []string
+}
+
+// Policy decides which labels may be written. config.Policy implements it.
+type Policy interface {
+	// Denies reports whether the label key may not be written to namespace,
+	// and why.
+	Denies(namespace, key string) (reason string, denied bool)
+}
+
+// Input is the state a plan is computed from.
+type Input struct {
+	// Namespace is passed to Policy.
+	Namespace string
+	// Live are the current labels of the Namespace.
+	Live map[string]string
+	// Owners maps the keys owned by a requester to its name.
+	Owners map[string]string
+	// Requests are the requests for the Namespace, in any order.
+	Requests []Request
+	// Policy denies labels. Every label is allowed when nil.
+	Policy Policy
+	// AdoptExisting leaves labels set outside of any request alone unless
+	// they already have the requested value, instead of overwriting them.
+	AdoptExisting bool
+	// Unlocked are the immutable keys that may change.
+	Unlocked []string
+	// Locked maps the keys locked on the Namespace to their values, as
+	// recorded by the previous plan.
+	Locked map[string]string
+}
+
+// Plan is the outcome of applying every request of an Input in turn.
+type Plan struct {
+	// Labels and Owners are the labels and ownership of the Namespace after
+	// the plan is applied.
+	Labels map[string]string
+	Owners map[string]string
+	// Locked maps the keys locked on the Namespace after the plan to their
+	// values: those of Input.Locked and the immutable keys applied by the
+	// requests, except the unlocked ones.
+	Locked map[string]string
+	// Steps holds a step for every request, in the order they were applied.
+	Steps []Step
+}
+
+// Step is the part of a Plan contributed by one request.
+type Step struct {
+	// Index is the position of the request in Input.Requests.
+	Index int
+	Name  string
+	// Before are the labels of the Namespace before the step, and Labels
+	// and Owners its labels and ownership after it.
+	Before map[string]string
+	Labels map[string]string
+	Owners map[string]string
+	// Applied are the labels the request owns after the step.
+	Applied map[string]string
+
+	// Changes lists the label writes of the step, ordered by key; removals
+	// of released keys come last.
+	Changes []Change
+	// Conflicts are the requested keys owned by another request.
+	Conflicts []Conflict
+	// Denials are the requested keys denied by the policy.
+	Denials []Denial
+	// Drifted are the owned keys whose live value was restored.
+	Drifted []string
+	// Adopted are the keys set outside of any request with the requested
+	// value, which the request takes over without changing them.
+	Adopted []string
+	// Unadopted are the keys set outside of any request with another value,
+	// left alone because of Input.AdoptExisting.
+	Unadopted []string
+	// Locked are the immutable keys kept at their applied value instead of
+	// being changed or removed.
+	Locked []Lock
+	// Invalid are the requested labels the API server would reject, left out
+	// so that the labels of the other requests can still be written.
+	Invalid []Invalid
+}
+
+// Change is a label written to or removed from the Namespace.
+type Change struct {
+	Op  Op
+	Key string
+	// Old is the value before the change, unless Op is OpAdd.
+	Old string
+	// New is the value after the change, unless Op is OpRemove.
+	New string
+	// Drifted is set when the change restores a value changed outside of
+	// any request.
+	Drifted bool
+}
+
+// Conflict is a requested key owned by another request.
+type Conflict struct {
+	Key   string
+	Owner string
+}
+
+// Denial is a requested key denied by the policy.
+type Denial struct {
+	Key    string
+	Reason string
+}
+
+// Invalid is a requested label that is not a valid Kubernetes label.
+type Invalid struct {
+	Key   string
+	Value string
+	// Message says why, as reported by the API server.
+	Message string
+}
+
+// Lock is an immutable key kept at its applied value.
+type Lock struct {
+	Key   string
+	Value string
+	// Requested is the value now requested, unless Removed.
+	Requested string
+	// Removed is set when the key is no longer requested, or the request is
+	// being deleted.
+	Removed bool
+}
+
+// Compute applies the requests of in in turn: requests being deleted first,
+// then the others oldest first.
+func Compute(in Input) Plan {
+	order := make([]int, len(in.Requests))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := &in.Requests[order[i]], &in.Requests[order[j]]
+		if a.Deleting != b.Deleting {
+			return a.Deleting
+		}
+		if !a.Created.Equal(b.Created) {
+			return a.Created.Before(b.Created)
+		}
+		return a.Name < b.Name
+	})
+	claimed := map[string][]string{}
+	for _, r := range in.Requests {
+		if r.Deleting {
+			continue
+		}
+		for key := range r.Labels {
+			claimed[key] = append(claimed[key], r.Name)
+		}
+	}
+
+	p := Plan{Labels: copyMap(in.Live), Owners: copyMap(in.Owners), Locked: map[string]string{}}
+	for key, value := range in.Locked {
+		if !contains(in.Unlocked, key) {
+			p.Locked[key] = value
+		}
+	}
+	for _, i := range order {
+		r := &in.Requests[i]
+		var step Step
+		if r.Deleting {
+			step = release(in, r, p.Labels, p.Owners)
+		} else {
+			step = apply(in, r, p.Labels, p.Owners, claimed)
+		}
+		step.Index, step.Name = i, r.Name
+		p.Labels, p.Owners = step.Labels, step.Owners
+		for _, l := range step.Locked {
+			p.Locked[l.Key] = l.Value
+		}
+		for _, key := range r.Immutable {
+			if value, ok := step.Applied[key]; ok && !contains(in.Unlocked, key) {
+				p.Locked[key] = value
+			}
+		}
+		p.Steps = append(p.Steps, step)
+	}
+	return p
+}
+
+// apply computes the labels and ownership of a Namespace labeled with live
+// and owned according to owners once r is applied. claimed maps every key
+// requested by an active request to the requests' names.
+func apply(in Input, r *Request, live, owners map[string]string, claimed map[string][]string) Step {
+	s := Step{
+		Before:  live,
+		Labels:  copyMap(live),
+		Owners:  copyMap(owners),
+		Applied: map[string]string{},
+	}
+	requested := r.Labels
+	locked := lockedKeys(in, r, owners, claimed)
+	for _, key := range sortedKeys(locked) {
+		value, ok := requested[key]
+		if ok && value == locked[key] {
+			continue
+		}
+		s.Locked = append(s.Locked, Lock{Key: key, Value: locked[key], Requested: value, Removed: !ok})
+		if len(s.Locked) == 1 {
+			requested = copyMap(r.Labels)
+		}
+		requested[key] = locked[key]
+	}
+	for _, key := range sortedKeys(requested) {
+		value := requested[key]
+		if _, ok := locked[key]; !ok {
+			if msgs := append(validation.IsQualifiedName(key), validation.IsValidLabelValue(value)...); len(msgs) > 0 {
+				s.Invalid = append(s.Invalid, Invalid{Key: key, Value: value, Message: strings.Join(msgs, "; ")})
+				continue
+			}
+		}
+		if _, ok := locked[key]; !ok && in.Policy != nil {
+			if reason, denied := in.Policy.Denies(in.Namespace, key); denied {
+				s.Denials = append(s.Denials, Denial{Key: key, Reason: reason})
+				continue
+			}
+		}
+		if owner, ok := s.Owners[key]; ok && owner != r.Name && contains(claimed[key], owner) {
+			s.Conflicts = append(s.Conflicts, Conflict{Key: key, Owner: owner})
+			continue
+		}
+
+		current, exists := s.Labels[key]
+		if _, managed := s.Owners[key]; exists && !managed {
+			if current != value && in.AdoptExisting {
+				s.Unadopted = append(s.Unadopted, key)
+				continue
+			}
+			if current == value {
+				s.Adopted = append(s.Adopted, key)
+			}
+		}
+		if !exists || current != value {
+			change := Change{Op: OpAdd, Key: key, New: value}
+			if exists {
+				change.Op, change.Old = OpUpdate, current
+			}
+			if exists && s.Owners[key] == r.Name && r.Applied[key] == value {
+				change.Drifted = true
+				s.Drifted = append(s.Drifted, key)
+			}
+			s.Changes = append(s.Changes, change)
+		}
+		s.Labels[key] = value
+		s.Owners[key] = r.Name
+		s.Applied[key] = value
+	}
+
+	// Release keys the request owned but no longer applies.
+	for _, key := range sortedKeys(owners) {
+		if owners[key] != r.Name {
+			continue
+		}
+		if _, ok := s.Applied[key]; ok {
+			continue
+		}
+		if old, ok := s.Labels[key]; ok {
+			s.Changes = append(s.Changes, Change{Op: OpRemove, Key: key, Old: old})
+		}
+		delete(s.Labels, key)
+		delete(s.Owners, key)
+	}
+	return s
+}
+
+// release computes the labels and ownership of a Namespace labeled with live
+// and owned according to owners once every label owned by r is removed.
+// Locked keys are released but stay on the Namespace.
+func release(in Input, r *Request, live, owners map[string]string) Step {
+	s := Step{Before: live, Labels: copyMap(live), Owners: copyMap(owners)}
+	locked := lockedKeys(in, r, owners, nil)
+	for _, key := range sortedKeys(owners) {
+		if owners[key] != r.Name {
+			continue
+		}
+		if value, ok := locked[key]; ok {
+			s.Locked = append(s.Locked, Lock{Key: key, Value: value, Removed: true})
+			delete(s.Owners, key)
+			continue
+		}
+		if old, ok := s.Labels[key]; ok {
+			s.Changes = append(s.Changes, Change{Op: OpRemove, Key: key, Old: old})
+		}
+		delete(s.Labels, key)
+		delete(s.Owners, key)
+	}
+	return s
+}
+
+// lockedKeys returns the keys locked for r with their values, unless they
+// are unlocked: the immutable keys r applied and still owns, and the keys
+// locked on the Namespace that r owns or requests. Keys owned by another
+// request in claimed are left to the conflict they cause.
+func lockedKeys(in Input, r *Request, owners map[string]string, claimed map[string][]string) map[string]string {
+	locked := map[string]string{}
+	for key, value := range in.Locked {
+		if contains(in.Unlocked, key) {
+			continue
+		}
+		owner, owned := owners[key]
+		if owned && owner != r.Name && contains(claimed[key], owner) {
+			continue
+		}
+		if _, ok := r.Labels[key]; ok || owner == r.Name {
+			locked[key] = value
+		}
+	}
+	for _, key := range r.Immutable {
+		value, applied := r.Applied[key]
+		if applied && owners[key] == r.Name && !contains(in.Unlocked, key) {
+			locked[key] = value
+		}
+	}
+	return locked
+}
+
+func copyMap(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Requests returns the requests of nls. They request the labels of the spec
+// and those the name rules derive from the name of their namespace.
+func Requests(nls []danateamv1.NamespaceLabel) []Request {
+	requests := make([]Request, 0, len(nls))
+	for i := range nls {
+		nl := &nls[i]
+		requests = append(requests, Request{
+			Name:      nl.Name,
+			Labels:    Labels(nl),
+			Created:   nl.CreationTimestamp.Time,
+			Deleting:  !nl.DeletionTimestamp.IsZero(),
+			Applied:   nl.Status.AppliedLabels,
+			Immutable: Immutable(nl),
+		})
+	}
+	return requests
+}
+
+// Labels returns the labels nl requests: those derived by its name rules,
+// unless they fail, and those of its spec, which take precedence.
+func Labels(nl *danateamv1.NamespaceLabel) map[string]string {
+	if len(nl.Spec.NameRules) == 0 {
+		return nl.Spec.Labels
+	}
+	derived, _, err := namerule.Derive(nl.Spec.NameRules, nl.Namespace)
+	if err != nil || len(derived) == 0 {
+		return nl.Spec.Labels
+	}
+	for key, value := range nl.Spec.Labels {
+		derived[key] = value
+	}
+	return derived
+}
+
+// Inherited returns the request for the labels a Namespace inherits from
+// its parent namespaces, named danateamv1.InheritedOwner. It leaves out the
+// keys requested by requests, so that inherited labels rank below those of
+// every other requester, and takes the keys back once no other requester asks
+// for them. Without labels, it releases the keys inherited before.
+func Inherited(labels map[string]string, requests []Request) Request {
+	inherited := map[string]string{}
+	for key, value := range labels {
+		if !requested(requests, key) {
+			inherited[key] = value
+		}
+	}
+	return Request{Name: danateamv1.InheritedOwner, Labels: inherited}
+}
+
+// requested reports whether any active request of requests asks for key.
+func requested(requests []Request, key string) bool {
+	for i := range requests {
+		if _, ok := requests[i].Labels[key]; ok && !requests[i].Deleting {
+			return true
+		}
+	}
+	return false
+}
+
+// Immutable returns the keys nl requests as immutable, or applied as
+// immutable before.
+func Immutable(nl *danateamv1.NamespaceLabel) []string {
+	keys := append([]string(nil), nl.Spec.Immutable...)
+	for _, key := range nl.Status.ImmutableLabels {
+		if !contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Locks decodes the danateamv1.ImmutableLabelsAnnotation of a Namespace
+// with annotations into Input.Locked.
+func Locks(annotations map[string]string) (map[string]string, error) {
+	locked := map[string]string{}
+	value := annotations[danateamv1.ImmutableLabelsAnnotation]
+	if value == "" {
+		return locked, nil
+	}
+	if err := json.Unmarshal([]byte(value), &locked); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", danateamv1.ImmutableLabelsAnnotation, err)
+	}
+	return locked, nil
+}
+
+// ValidateLabels returns the errors the API server would report when labels
+// are written to a Namespace, with paths below path.
+func ValidateLabels(labels map[string]string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, key := range sortedKeys(labels) {
+		for _, msg := range validation.IsQualifiedName(key) {
+			errs = append(errs, field.Invalid(path.Key(key), key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(labels[key]) {
+			errs = append(errs, field.Invalid(path.Key(key), labels[key], msg))
+		}
+	}
+	return errs
+}
+
+// Unlocked returns the keys listed in the UnlockImmutableLabelsAnnotation of
+// a Namespace with annotations.
+func Unlocked(annotations map[string]string) []string {
+	var keys []string
+	for _, key := range strings.Split(annotations[danateamv1.UnlockImmutableLabelsAnnotation], ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,516 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package labelplan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// prefixPolicy denies keys with a prefix, and every key in a namespace.
+type prefixPolicy struct {
+	prefix, namespace string
+}
+
+func (p prefixPolicy) Denies(namespace, key string) (string, bool) {
+	if namespace == p.namespace {
+		return "protected_namespace", true
+	}
+	if strings.HasPrefix(key, p.prefix) {
+		return "protected_prefix", true
+	}
+	return "", false
+}
+
+var (
+	older = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer = older.Add(time.Hour)
+)
+
+func TestCompute(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		in         Input
+		wantLabels map[string]string
+		wantOwners map[string]string
+		wantLocked map[string]string
+		// wantSteps are the steps expected, without Before, Labels, Owners
+		// and Applied, which are checked through the plan.
+		wantSteps []Step
+	}{{
+		name: "adds requested labels",
+		in: Input{
+			Live:     map[string]string{"kubernetes.io/metadata.name": "team-a"},
+			Requests: []Request{{Name: "base", Labels: map[string]string{"team": "platform", "tier": "gold"}}},
+		},
+		wantLabels: map[string]string{"kubernetes.io/metadata.name": "team-a", "team": "platform", "tier": "gold"},
+		wantOwners: map[string]string{"team": "base", "tier": "base"},
+		wantSteps: []Step{{Name: "base", Changes: []Change{
+			{Op: OpAdd, Key: "team", New: "platform"},
+			{Op: OpAdd, Key: "tier", New: "gold"},
+		}}},
+	}, {
+		name: "overwrites and adopts labels set outside of requests",
+		in: Input{
+			Live:     map[string]string{"team": "platform", "tier": "silver"},
+			Requests: []Request{{Name: "base", Labels: map[string]string{"team": "platform", "tier": "gold"}}},
+		},
+		wantLabels: map[string]string{"team": "platform", "tier": "gold"},
+		wantOwners: map[string]string{"team": "base", "tier": "base"},
+		wantSteps: []Step{{
+			Name:    "base",
+			Changes: []Change{{Op: OpUpdate, Key: "tier", Old: "silver", New: "gold"}},
+			Adopted: []string{"team"},
+		}},
+	}, {
+		name: "leaves labels with another value alone when adopting existing labels",
+		in: Input{
+			Live:          map[string]string{"team": "platform", "tier": "silver"},
+			Requests:      []Request{{Name: "base", Labels: map[string]string{"team": "platform", "tier": "gold"}}},
+			AdoptExisting: true,
+		},
+		wantLabels: map[string]string{"team": "platform", "tier": "silver"},
+		wantOwners: map[string]string{"team": "base"},
+		wantSteps:  []Step{{Name: "base", Adopted: []string{"team"}, Unadopted: []string{"tier"}}},
+	}, {
+		name: "keeps keys with their owner",
+		in: Input{
+			Live:   map[string]string{"team": "payments"},
+			Owners: map[string]string{"team": "payments"},
+			Requests: []Request{
+				{Name: "payments", Labels: map[string]string{"team": "payments"}, Created: newer},
+				{Name: "base", Labels: map[string]string{"team": "platform"}, Created: older},
+			},
+		},
+		wantLabels: map[string]string{"team": "payments"},
+		wantOwners: map[string]string{"team": "payments"},
+		wantSteps: []Step{
+			{Index: 1, Name: "base", Conflicts: []Conflict{{Key: "team", Owner: "payments"}}},
+			{Index: 0, Name: "payments"},
+		},
+	}, {
+		name: "gives unowned keys to the oldest request, then by name",
+		in: Input{
+			Requests: []Request{
+				{Name: "c", Labels: map[string]string{"team": "c"}, Created: newer},
+				{Name: "b", Labels: map[string]string{"team": "b"}, Created: older},
+				{Name: "a", Labels: map[string]string{"team": "a"}, Created: newer},
+			},
+		},
+		wantLabels: map[string]string{"team": "b"},
+		wantOwners: map[string]string{"team": "b"},
+		wantSteps: []Step{
+			{Index: 1, Name: "b", Changes: []Change{{Op: OpAdd, Key: "team", New: "b"}}},
+			{Index: 2, Name: "a", Conflicts: []Conflict{{Key: "team", Owner: "b"}}},
+			{Index: 0, Name: "c", Conflicts: []Conflict{{Key: "team", Owner: "b"}}},
+		},
+	}, {
+		name: "hands keys over when their owner is deleted",
+		in: Input{
+			Live:   map[string]string{"team": "platform", "tier": "gold"},
+			Owners: map[string]string{"team": "base", "tier": "base"},
+			Requests: []Request{
+				{Name: "payments", Labels: map[string]string{"team": "payments"}, Created: newer},
+				{Name: "base", Labels: map[string]string{"team": "platform"}, Created: older, Deleting: true},
+			},
+		},
+		wantLabels: map[string]string{"team": "payments"},
+		wantOwners: map[string]string{"team": "payments"},
+		wantSteps: []Step{
+			{Index: 1, Name: "base", Changes: []Change{
+				{Op: OpRemove, Key: "team", Old: "platform"},
+				{Op: OpRemove, Key: "tier", Old: "gold"},
+			}},
+			{Index: 0, Name: "payments", Changes: []Change{{Op: OpAdd, Key: "team", New: "payments"}}},
+		},
+	}, {
+		name: "removes keys no longer requested and restores drifted ones",
+		in: Input{
+			Live:   map[string]string{"team": "someone-else", "tier": "gold"},
+			Owners: map[string]string{"team": "base", "tier": "base"},
+			Requests: []Request{{
+				Name:    "base",
+				Labels:  map[string]string{"team": "platform"},
+				Applied: map[string]string{"team": "platform", "tier": "gold"},
+			}},
+		},
+		wantLabels: map[string]string{"team": "platform"},
+		wantOwners: map[string]string{"team": "base"},
+		wantSteps: []Step{{
+			Name: "base",
+			Changes: []Change{
+				{Op: OpUpdate, Key: "team", Old: "someone-else", New: "platform", Drifted: true},
+				{Op: OpRemove, Key: "tier", Old: "gold"},
+			},
+			Drifted: []string{"team"},
+		}},
+	}, {
+		name: "denies keys refused by the policy",
+		in: Input{
+			Namespace: "team-a",
+			Requests:  []Request{{Name: "base", Labels: map[string]string{"k8s.io/spoofed": "x", "team": "platform"}}},
+			Policy:    prefixPolicy{prefix: "k8s.io/"},
+		},
+		wantLabels: map[string]string{"team": "platform"},
+		wantOwners: map[string]string{"team": "base"},
+		wantSteps: []Step{{
+			Name:    "base",
+			Changes: []Change{{Op: OpAdd, Key: "team", New: "platform"}},
+			Denials: []Denial{{Key: "k8s.io/spoofed", Reason: "protected_prefix"}},
+		}},
+	}, {
+		name: "releases owned keys once the namespace is protected",
+		in: Input{
+			Namespace: "kube-system",
+			Live:      map[string]string{"team": "platform"},
+			Owners:    map[string]string{"team": "base"},
+			Requests:  []Request{{Name: "base", Labels: map[string]string{"team": "platform"}}},
+			Policy:    prefixPolicy{namespace: "kube-system"},
+		},
+		wantLabels: map[string]string{},
+		wantOwners: map[string]string{},
+		wantSteps: []Step{{
+			Name:    "base",
+			Changes: []Change{{Op: OpRemove, Key: "team", Old: "platform"}},
+			Denials: []Denial{{Key: "team", Reason: "protected_namespace"}},
+		}},
+	}, {
+		name: "keeps the applied value of immutable keys",
+		in: Input{
+			Namespace: "kube-system",
+			Live:      map[string]string{"environment": "prod", "data-classification": "internal"},
+			Owners:    map[string]string{"environment": "base", "data-classification": "base"},
+			Requests: []Request{{
+				Name:      "base",
+				Labels:    map[string]string{"environment": "dev"},
+				Applied:   map[string]string{"environment": "prod", "data-classification": "internal"},
+				Immutable: []string{"environment", "data-classification"},
+			}},
+			Policy: prefixPolicy{namespace: "kube-system"},
+		},
+		wantLabels: map[string]string{"environment": "prod", "data-classification": "internal"},
+		wantOwners: map[string]string{"environment": "base", "data-classification": "base"},
+		wantLocked: map[string]string{"environment": "prod", "data-classification": "internal"},
+		wantSteps: []Step{{Name: "base", Locked: []Lock{
+			{Key: "data-classification", Value: "internal", Removed: true},
+			{Key: "environment", Value: "prod", Requested: "dev"},
+		}}},
+	}, {
+		name: "changes unlocked immutable keys",
+		in: Input{
+			Live:   map[string]string{"environment": "prod"},
+			Owners: map[string]string{"environment": "base"},
+			Requests: []Request{{
+				Name:      "base",
+				Labels:    map[string]string{"environment": "dev"},
+				Applied:   map[string]string{"environment": "prod"},
+				Immutable: []string{"environment"},
+			}},
+			Unlocked: []string{"environment"},
+		},
+		wantLabels: map[string]string{"environment": "dev"},
+		wantOwners: map[string]string{"environment": "base"},
+		wantSteps: []Step{{Name: "base", Changes: []Change{
+			{Op: OpUpdate, Key: "environment", Old: "prod", New: "dev"},
+		}}},
+	}, {
+		name: "leaves immutable keys in place when their owner is deleted",
+		in: Input{
+			Live:   map[string]string{"environment": "prod", "team": "platform"},
+			Owners: map[string]string{"environment": "base", "team": "base"},
+			Requests: []Request{{
+				Name:      "base",
+				Labels:    map[string]string{"environment": "prod", "team": "platform"},
+				Applied:   map[string]string{"environment": "prod", "team": "platform"},
+				Immutable: []string{"environment"},
+				Deleting:  true,
+			}},
+		},
+		wantLabels: map[string]string{"environment": "prod"},
+		wantOwners: map[string]string{},
+		wantLocked: map[string]string{"environment": "prod"},
+		wantSteps: []Step{{
+			Name:    "base",
+			Changes: []Change{{Op: OpRemove, Key: "team", Old: "platform"}},
+			Locked:  []Lock{{Key: "environment", Value: "prod", Removed: true}},
+		}},
+	}, {
+		name: "keeps keys locked on the namespace for later requests",
+		in: Input{
+			Live:     map[string]string{"environment": "prod"},
+			Requests: []Request{{Name: "recreated", Labels: map[string]string{"environment": "dev"}}},
+			Locked:   map[string]string{"environment": "prod"},
+		},
+		wantLabels: map[string]string{"environment": "prod"},
+		wantOwners: map[string]string{"environment": "recreated"},
+		wantLocked: map[string]string{"environment": "prod"},
+		wantSteps: []Step{{
+			Name:    "recreated",
+			Adopted: []string{"environment"},
+			Locked:  []Lock{{Key: "environment", Value: "prod", Requested: "dev"}},
+		}},
+	}, {
+		name: "leaves keys locked on the namespace to their owner",
+		in: Input{
+			Live:   map[string]string{"environment": "prod"},
+			Owners: map[string]string{"environment": "base"},
+			Requests: []Request{
+				{Name: "base", Labels: map[string]string{"environment": "prod"}, Created: older},
+				{Name: "other", Labels: map[string]string{"environment": "dev"}, Created: newer},
+			},
+			Locked: map[string]string{"environment": "prod"},
+		},
+		wantLabels: map[string]string{"environment": "prod"},
+		wantOwners: map[string]string{"environment": "base"},
+		wantLocked: map[string]string{"environment": "prod"},
+		wantSteps: []Step{
+			{Name: "base"},
+			{Index: 1, Name: "other", Conflicts: []Conflict{{Key: "environment", Owner: "base"}}},
+		},
+	}, {
+		name: "unlocks keys locked on the namespace",
+		in: Input{
+			Live:     map[string]string{"environment": "prod"},
+			Requests: []Request{{Name: "recreated", Labels: map[string]string{"environment": "dev"}}},
+			Locked:   map[string]string{"environment": "prod"},
+			Unlocked: []string{"environment"},
+		},
+		wantLabels: map[string]string{"environment": "dev"},
+		wantOwners: map[string]string{"environment": "recreated"},
+		wantSteps: []Step{{Name: "recreated", Changes: []Change{
+			{Op: OpUpdate, Key: "environment", Old: "prod", New: "dev"},
+		}}},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := Compute(tc.in)
+			if !reflect.DeepEqual(p.Labels, tc.wantLabels) {
+				t.Errorf("labels = %v, want %v", p.Labels, tc.wantLabels)
+			}
+			if !reflect.DeepEqual(p.Owners, tc.wantOwners) {
+				t.Errorf("owners = %v, want %v", p.Owners, tc.wantOwners)
+			}
+			if (len(p.Locked) > 0 || len(tc.wantLocked) > 0) && !reflect.DeepEqual(p.Locked, tc.wantLocked) {
+				t.Errorf("locked = %v, want %v", p.Locked, tc.wantLocked)
+			}
+			var steps []Step
+			for _, step := range p.Steps {
+				step.Before, step.Labels, step.Owners, step.Applied = nil, nil, nil, nil
+				steps = append(steps, step)
+			}
+			if !reflect.DeepEqual(steps, tc.wantSteps) {
+				t.Errorf("steps = %+v, want %+v", steps, tc.wantSteps)
+			}
+		})
+	}
+}
+
+func TestComputeLeavesOutInvalidLabels(t *testing.T) {
+	p := Compute(Input{Requests: []Request{
+		{Name: "base", Labels: map[string]string{"team": "platform", "bad key": "x"}, Created: older},
+		{Name: "other", Labels: map[string]string{"tier": strings.Repeat("a", 64)}, Created: newer},
+	}})
+	if want := map[string]string{"team": "platform"}; !reflect.DeepEqual(p.Labels, want) {
+		t.Errorf("labels = %v, want %v", p.Labels, want)
+	}
+	for i, key := range []string{"bad key", "tier"} {
+		invalid := p.Steps[i].Invalid
+		if len(invalid) != 1 || invalid[0].Key != key || invalid[0].Message == "" {
+			t.Errorf("step %s: invalid = %+v, want %q", p.Steps[i].Name, invalid, key)
+		}
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	errs := ValidateLabels(map[string]string{
+		"team": "platform", "bad key": "x", "tier": strings.Repeat("a", 64),
+	}, field.NewPath("spec", "labels"))
+	if len(errs) != 2 || errs[0].Field != "spec.labels[bad key]" || errs[1].Field != "spec.labels[tier]" {
+		t.Errorf("errors = %v, want one for bad key and one for tier", errs)
+	}
+}
+
+func TestComputeDoesNotModifyInput(t *testing.T) {
+	in := Input{
+		Live:     map[string]string{"team": "payments"},
+		Owners:   map[string]string{"team": "old"},
+		Requests: []Request{{Name: "base", Labels: map[string]string{"team": "platform"}}},
+	}
+	Compute(in)
+	if !reflect.DeepEqual(in.Live, map[string]string{"team": "payments"}) ||
+		!reflect.DeepEqual(in.Owners, map[string]string{"team": "old"}) {
+		t.Errorf("Compute modified its input: %+v", in)
+	}
+}
+
+func TestInherited(t *testing.T) {
+	requests := []Request{
+		{Name: "base", Labels: map[string]string{"team": "payments"}, Created: older},
+		{Name: "old", Labels: map[string]string{"cost-center": "1234"}, Deleting: true},
+	}
+	in := Input{
+		Live:   map[string]string{"team": "platform", "cost-center": "42"},
+		Owners: map[string]string{"team": "(inherited)", "cost-center": "old"},
+		Requests: append(requests, Inherited(map[string]string{
+			"team": "platform", "cost-center": "1234", "tier": "gold",
+		}, requests)),
+	}
+	p := Compute(in)
+	want := map[string]string{"team": "payments", "cost-center": "1234", "tier": "gold"}
+	if !reflect.DeepEqual(p.Labels, want) {
+		t.Errorf("labels = %v, want %v", p.Labels, want)
+	}
+	want = map[string]string{"team": "base", "cost-center": "(inherited)", "tier": "(inherited)"}
+	if !reflect.DeepEqual(p.Owners, want) {
+		t.Errorf("owners = %v, want %v", p.Owners, want)
+	}
+
+	// Without labels, the inherited keys are released.
+	active := requests[:1]
+	in = Input{Live: p.Labels, Owners: p.Owners, Requests: []Request{active[0], Inherited(nil, active)}}
+	p = Compute(in)
+	if want := map[string]string{"team": "payments"}; !reflect.DeepEqual(p.Labels, want) {
+		t.Errorf("labels = %v, want %v", p.Labels, want)
+	}
+}
+
+// fuzzInput decodes data into a small Input, so that the fuzzer explores
+// keys shared between the live labels, the owners and several requests.
+func fuzzInput(data []byte) Input {
+	keys := []string{"a", "b", "c", "k8s.io/d"}
+	values := []string{"x", "y"}
+	next := func() int {
+		if len(data) == 0 {
+			return 0
+		}
+		b := int(data[0])
+		data = data[1:]
+		return b
+	}
+	in := Input{
+		Namespace:     "ns",
+		Live:          map[string]string{},
+		Owners:        map[string]string{},
+		Policy:        prefixPolicy{prefix: "k8s.io/"},
+		AdoptExisting: next()%2 == 1,
+	}
+	names := []string{"r0", "r1", "r2"}
+	for _, name := range names[:1+next()%len(names)] {
+		r := Request{
+			Name:     name,
+			Labels:   map[string]string{},
+			Applied:  map[string]string{},
+			Created:  older.Add(time.Duration(next()%2) * time.Hour),
+			Deleting: next()%4 == 0,
+		}
+		for _, key := range keys {
+			switch b := next(); b % 3 {
+			case 1:
+				r.Labels[key] = values[b/3%2]
+			case 2:
+				r.Applied[key] = values[b/3%2]
+			}
+		}
+		in.Requests = append(in.Requests, r)
+	}
+	for _, key := range keys {
+		b := next()
+		if b%2 == 1 {
+			in.Live[key] = values[b/2%2]
+		}
+		if b%3 == 1 {
+			in.Owners[key] = names[b/3%len(names)]
+		}
+	}
+	return in
+}
+
+func FuzzCompute(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 2, 1, 1, 1, 4, 7, 1, 0, 1, 5, 3, 3, 9, 2})
+	f.Add([]byte{1, 1, 0, 1, 4, 4, 4, 4, 1, 1, 1, 1, 1, 7, 7, 7, 7, 7})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		in := fuzzInput(data)
+		p := Compute(in)
+
+		requests := map[string]Request{}
+		for _, r := range in.Requests {
+			requests[r.Name] = r
+		}
+		for key, owner := range p.Owners {
+			r, ok := requests[owner]
+			if !ok {
+				// Keys of requesters that are gone are left as they are.
+				if in.Owners[key] != owner || p.Labels[key] != in.Live[key] {
+					t.Fatalf("key %q changed while owned by %q, which is gone: %+v", key, owner, in)
+				}
+				continue
+			}
+			if r.Deleting {
+				t.Fatalf("key %q is owned by %q, which is being deleted: %+v", key, owner, in)
+			}
+			if value, ok := p.Labels[key]; !ok || value != r.Labels[key] {
+				t.Fatalf("owned key %q is %q, but %s requests %q: %+v", key, value, owner, r.Labels[key], in)
+			}
+		}
+		for key, value := range in.Live {
+			if _, owned := p.Owners[key]; owned {
+				continue
+			}
+			if _, wasOwned := in.Owners[key]; !wasOwned && p.Labels[key] != value {
+				t.Fatalf("unowned key %q changed from %q to %q: %+v", key, value, p.Labels[key], in)
+			}
+		}
+		if len(p.Steps) != len(in.Requests) {
+			t.Fatalf("got %d steps for %d requests", len(p.Steps), len(in.Requests))
+		}
+
+		// The plan does not depend on the order of the requests.
+		reversed := in
+		reversed.Requests = nil
+		for i := len(in.Requests) - 1; i >= 0; i-- {
+			reversed.Requests = append(reversed.Requests, in.Requests[i])
+		}
+		if q := Compute(reversed); !reflect.DeepEqual(q.Labels, p.Labels) || !reflect.DeepEqual(q.Owners, p.Owners) {
+			t.Fatalf("reversing the requests changed the plan from %v/%v to %v/%v", p.Labels, p.Owners, q.Labels, q.Owners)
+		}
+
+		// Applying the plan again changes nothing.
+		again := Input{Namespace: in.Namespace, Live: p.Labels, Owners: p.Owners, Policy: in.Policy,
+			AdoptExisting: in.AdoptExisting}
+		for _, step := range p.Steps {
+			r := in.Requests[step.Index]
+			if r.Deleting {
+				continue
+			}
+			r.Applied = step.Applied
+			again.Requests = append(again.Requests, r)
+		}
+		q := Compute(again)
+		if !reflect.DeepEqual(q.Labels, p.Labels) || !reflect.DeepEqual(q.Owners, p.Owners) {
+			t.Fatalf("the plan is not stable: %v/%v, then %v/%v", p.Labels, p.Owners, q.Labels, q.Owners)
+		}
+		for _, step := range q.Steps {
+			if len(step.Changes) > 0 {
+				t.Fatalf("applying the plan again changes %+v", step.Changes)
+			}
+		}
+	})
+}
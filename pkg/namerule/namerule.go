@@ -0,0 +1,127 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package namerule derives labels from the name of a Namespace, as described
+// by the name rules of NamespaceLabels and NamespaceLabelRequirements, so
+// that namespaces following a naming convention such as <team>-<app>-<env>
+// are labeled without any YAML of their own.
+package namerule
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+)
+
+// Rules are compiled name rules.
+type Rules struct {
+	rules []rule
+}
+
+type rule struct {
+	pattern *regexp.Regexp
+	labels  []danateamv1.DerivedLabel
+}
+
+// Compile compiles rules, or returns every error in them, reported under
+// path. Patterns must match the whole name, every group must be a named
+// capture group of its pattern, and keys and translated values must be valid
+// label keys and values.
+func Compile(rules []danateamv1.NameRule, path *field.Path) (*Rules, field.ErrorList) {
+	var errs field.ErrorList
+	compiled := &Rules{}
+	for i, r := range rules {
+		rulePath := path.Index(i)
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			errs = append(errs, field.Invalid(rulePath.Child("pattern"), r.Pattern, err.Error()))
+			continue
+		}
+		pattern := regexp.MustCompile("^(?:" + r.Pattern + ")$")
+		for j, l := range r.Labels {
+			labelPath := rulePath.Child("labels").Index(j)
+			for _, msg := range validation.IsQualifiedName(l.Key) {
+				errs = append(errs, field.Invalid(labelPath.Child("key"), l.Key, msg))
+			}
+			if l.Group == "" || pattern.SubexpIndex(l.Group) < 0 {
+				errs = append(errs, field.Invalid(labelPath.Child("group"), l.Group,
+					"must be a named capture group of the pattern"))
+			}
+			for _, from := range sortedKeys(l.Values) {
+				for _, msg := range validation.IsValidLabelValue(l.Values[from]) {
+					errs = append(errs, field.Invalid(labelPath.Child("values").Key(from), l.Values[from], msg))
+				}
+			}
+		}
+		compiled.rules = append(compiled.rules, rule{pattern: pattern, labels: r.Labels})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return compiled, nil
+}
+
+// Derive returns the labels derived from name by the first rule whose
+// pattern matches it, and whether a rule matched. It fails when a derived
+// value is not a valid label value, which a captured value starting or
+// ending with a dash is not.
+func (r *Rules) Derive(name string) (map[string]string, bool, error) {
+	if r == nil {
+		return nil, false, nil
+	}
+	for _, rule := range r.rules {
+		match := rule.pattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		labels := make(map[string]string, len(rule.labels))
+		for _, l := range rule.labels {
+			value := match[rule.pattern.SubexpIndex(l.Group)]
+			if translated, ok := l.Values[value]; ok {
+				value = translated
+			}
+			if msgs := validation.IsValidLabelValue(value); len(msgs) > 0 {
+				return nil, true, fmt.Errorf("label %q: derived value %q is invalid: %s", l.Key, value, msgs[0])
+			}
+			labels[l.Key] = value
+		}
+		return labels, true, nil
+	}
+	return nil, false, nil
+}
+
+// Derive compiles rules and derives the labels of the Namespace name with
+// them. Invalid rules derive nothing.
+func Derive(rules []danateamv1.NameRule, name string) (map[string]string, bool, error) {
+	compiled, errs := Compile(rules, field.NewPath("nameRules"))
+	if len(errs) > 0 {
+		return nil, false, errs.ToAggregate()
+	}
+	return compiled.Derive(name)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package namerule
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	danateamv1 "github.com/matanamar10/namesapcelabel/api/v1"
+)
+
+var conventions = []danateamv1.NameRule{{
+	Pattern: `(?P<team>[a-z0-9]+)-(?P<app>[a-z0-9]+)-(?P<env>dev|stg|prd)`,
+	Labels: []danateamv1.DerivedLabel{
+		{Key: "team", Group: "team"},
+		{Key: "app", Group: "app"},
+		{Key: "environment", Group: "env", Values: map[string]string{"stg": "staging", "prd": "production"}},
+	},
+}, {
+	Pattern: `sandbox-(?P<owner>.+)`,
+	Labels:  []danateamv1.DerivedLabel{{Key: "owner", Group: "owner"}},
+}}
+
+func TestDerive(t *testing.T) {
+	rules, errs := Compile(conventions, field.NewPath("spec", "nameRules"))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	for _, tc := range []struct {
+		name        string
+		want        map[string]string
+		wantMatched bool
+		wantErr     string
+	}{{
+		name:        "payments-api-prd",
+		want:        map[string]string{"team": "payments", "app": "api", "environment": "production"},
+		wantMatched: true,
+	}, {
+		name:        "payments-api-dev",
+		want:        map[string]string{"team": "payments", "app": "api", "environment": "dev"},
+		wantMatched: true,
+	}, {
+		name:        "sandbox-alice",
+		want:        map[string]string{"owner": "alice"},
+		wantMatched: true,
+	}, {
+		// The first pattern must match the whole name.
+		name: "payments-api-prd-old",
+	}, {
+		name: "kube-system",
+	}, {
+		name:        "sandbox--alice",
+		wantMatched: true,
+		wantErr:     `label "owner": derived value "-alice" is invalid`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			labels, matched, err := rules.Derive(tc.name)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if matched != tc.wantMatched || !reflect.DeepEqual(labels, tc.want) {
+				t.Errorf("Derive = %v, %v, want %v, %v", labels, matched, tc.want, tc.wantMatched)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	_, errs := Compile([]danateamv1.NameRule{{
+		Pattern: `(?P<team>[a-z]+)-(?P<env>[a-z]+)`,
+		Labels: []danateamv1.DerivedLabel{
+			{Key: "team", Group: "tema"},
+			{Key: "bad key", Group: "team"},
+			{Key: "environment", Group: "env", Values: map[string]string{"prd": "not valid"}},
+		},
+	}, {
+		Pattern: `(?P<team>[a-z]+`,
+		Labels:  []danateamv1.DerivedLabel{{Key: "team", Group: "team"}},
+	}}, field.NewPath("spec", "nameRules"))
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Field)
+	}
+	want := []string{
+		"spec.nameRules[0].labels[0].group",
+		"spec.nameRules[0].labels[1].key",
+		"spec.nameRules[0].labels[2].values[prd]",
+		"spec.nameRules[1].pattern",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors on %v, want %v", got, want)
+	}
+}